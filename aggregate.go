@@ -0,0 +1,74 @@
+package events
+
+import (
+	"context"
+	"fmt"
+)
+
+// ApplyFunc folds an event into the state of an aggregate, state is the
+// pointer given to Aggregate.Load or Aggregate.Fold
+type ApplyFunc func(state interface{}, event Event) error
+
+// Aggregate rebuilds state by folding the events of a stream through the
+// ApplyFuncs registered for their name and version
+type Aggregate struct {
+	appliers map[eventKey]ApplyFunc
+
+	// IgnoreUnknown skips events without ApplyFunc instead of failing
+	IgnoreUnknown bool
+}
+
+// NewAggregate returns an Aggregate without ApplyFuncs
+func NewAggregate() *Aggregate {
+	return &Aggregate{appliers: map[eventKey]ApplyFunc{}}
+}
+
+// Add adds an ApplyFunc into the Aggregate
+func (a *Aggregate) Add(name string, version int, apply ApplyFunc) {
+	key := eventKey{name, version}
+	a.appliers[key] = apply
+}
+
+// Fold applies the events to state in order
+func (a *Aggregate) Fold(state interface{}, events ...Event) error {
+	for _, event := range events {
+		apply, ok := a.appliers[eventKey{event.Name, event.Version}]
+
+		if !ok {
+			if a.IgnoreUnknown {
+				continue
+			}
+			return fmt.Errorf("events: no apply function for %q version %d", event.Name, event.Version)
+		}
+
+		if err := apply(state, event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Load folds every event of a stream into state and returns the stream
+// version, to be used as the expected version of the next Append
+func (a *Aggregate) Load(ctx context.Context, store EventStore, streamID string, state interface{}) (int, error) {
+	version := 0
+
+	for {
+		recorded, err := store.ReadForward(ctx, streamID, version+1, 1000)
+		if err != nil {
+			return version, err
+		}
+
+		if len(recorded) == 0 {
+			return version, nil
+		}
+
+		for _, r := range recorded {
+			if err := a.Fold(state, r.Event); err != nil {
+				return version, err
+			}
+			version = r.StreamVersion
+		}
+	}
+}
@@ -0,0 +1,664 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetadataBatch is the metadata field of a batch response with its
+// BatchSummary
+const MetadataBatch = "batch"
+
+// batchEventName is the name of the batch events redacted item by item
+const batchEventName = "batch"
+
+// BatchPolicy - What a batch does when a sub-event fails
+type BatchPolicy string
+
+// Batch policies
+const (
+	// BatchContinue runs every sub-event, except the dependents of the
+	// failed ones
+	BatchContinue BatchPolicy = "continue"
+	// BatchStopOnFirstError skips the sub-events not started before the
+	// first failure
+	BatchStopOnFirstError BatchPolicy = "stopOnFirstError"
+	// BatchAtomic stops like BatchStopOnFirstError and compensates the
+	// succeeded sub-events whose handlers are Compensators
+	BatchAtomic BatchPolicy = "atomic"
+)
+
+// BatchStatus - The outcome of a sub-event of a batch
+type BatchStatus string
+
+// Batch statuses
+const (
+	BatchSucceeded          BatchStatus = "succeeded"
+	BatchFailed             BatchStatus = "failed"
+	BatchSkipped            BatchStatus = "skipped"
+	BatchCompensated        BatchStatus = "compensated"
+	BatchCompensationFailed BatchStatus = "compensationFailed"
+)
+
+// Compensator - A Handler able to undo its events. When an atomic batch
+// fails, Compensate is called with the event and response of every
+// succeeded sub-event, in the reverse order they completed.
+type Compensator interface {
+	Compensate(ctx context.Context, event Event, response Event) error
+}
+
+// BatchSummary - The outcome of a batch, in the MetadataBatch field of its
+// response
+type BatchSummary struct {
+	Policy BatchPolicy       `json:"policy"`
+	Items  []BatchItemStatus `json:"items"`
+}
+
+// BatchItemStatus - The outcome of a sub-event, in the batch order
+type BatchItemStatus struct {
+	ID     string      `json:"id,omitempty"`
+	Name   string      `json:"name"`
+	Status BatchStatus `json:"status"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// BatchLimits - The limits of the batches accepted by a Batch handler
+type BatchLimits struct {
+	// MaxEvents is the number of sub-events, zero is unlimited
+	MaxEvents int
+	// MaxPayloadBytes is the size of the batch payload, zero is unlimited
+	MaxPayloadBytes int
+	// MaxDepth is the number of batches allowed inside batches, zero
+	// rejects nested batches and negative values are unlimited
+	MaxDepth int
+}
+
+// DefaultBatchLimits are the limits of Batch
+var DefaultBatchLimits = BatchLimits{
+	MaxEvents:       1000,
+	MaxPayloadBytes: 4 << 20,
+	MaxDepth:        1,
+}
+
+// BatchResponse - An item of the payload of a batch response: the response
+// of a sub-event with the ID, the status and the handler duration of the
+// sub-event
+type BatchResponse struct {
+	Event
+	RequestID  string      `json:"requestId,omitempty"`
+	Status     BatchStatus `json:"status"`
+	DurationMs float64     `json:"durationMs"`
+}
+
+// batchDepthKey is the context key with the nesting depth of sub-events
+type batchDepthKey struct{}
+
+// batchPayload - The payload of a batch event. Sub-events may depend on
+// others by ID, the batch then runs as a DAG: independent events run in
+// parallel and dependent ones wait for their dependencies. Without
+// dependencies the events run in order, or in parallel when Parallel is set.
+type batchPayload struct {
+	Parallel bool        `json:"parallel"`
+	Policy   BatchPolicy `json:"policy"`
+	Events   []batchItem `json:"events"`
+}
+
+type batchItem struct {
+	Event
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+// batchReference matches "${<id>#<JSON Pointer>}" templates, replaced by the
+// field of the response payload of the event <id>. A string made only of a
+// template is replaced by the field itself, keeping its JSON type.
+var batchReference = regexp.MustCompile(`\$\{([^#}]+)#([^}]*)\}`)
+
+type batchNode struct {
+	index      int
+	item       batchItem
+	deps       []int
+	dependents []int
+
+	event    Event
+	response Event
+	status   BatchStatus
+	err      string
+	duration time.Duration
+	done     chan struct{}
+}
+
+type batchRun struct {
+	mux    *Mux
+	flowID string
+	policy BatchPolicy
+	nodes  []*batchNode
+	order  []int
+
+	// mu guards the tracker, shared by the parallel events, and the
+	// progress of the batch
+	mu        sync.Mutex
+	stopped   bool
+	completed []int
+}
+
+// Batch is an event that executes batches of events, within
+// DefaultBatchLimits
+func Batch(mux *Mux) HandlerFunc {
+	return BatchWithLimits(mux, DefaultBatchLimits)
+}
+
+// BatchWithLimits - Returns a Batch handler rejecting the batches over
+// limits with an error event. Sub-events are tracked by the Mux tracker,
+// with a nil ResponseWriter and Request.
+func BatchWithLimits(mux *Mux, limits BatchLimits) HandlerFunc {
+	return func(ctx context.Context, event Event) (Event, error) {
+		depth, _ := ctx.Value(batchDepthKey{}).(int)
+
+		if limits.MaxDepth >= 0 && depth > limits.MaxDepth {
+			err := fmt.Errorf("Batch nested %d levels deep, the limit is %d", depth, limits.MaxDepth)
+			return NewError(event.FlowID, err.Error()), err
+		}
+
+		if limits.MaxPayloadBytes > 0 && len(event.Payload) > limits.MaxPayloadBytes {
+			err := fmt.Errorf("Batch payload has %d bytes, the limit is %d", len(event.Payload), limits.MaxPayloadBytes)
+			return NewError(event.FlowID, err.Error()), err
+		}
+
+		payload := batchPayload{}
+
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			return NewError(event.FlowID, err.Error()), err
+		}
+
+		if limits.MaxEvents > 0 && len(payload.Events) > limits.MaxEvents {
+			err := fmt.Errorf("Batch has %d events, the limit is %d", len(payload.Events), limits.MaxEvents)
+			return NewError(event.FlowID, err.Error()), err
+		}
+
+		switch payload.Policy {
+		case "":
+			payload.Policy = BatchContinue
+		case BatchContinue, BatchStopOnFirstError, BatchAtomic:
+		default:
+			err := fmt.Errorf(`Unknown batch policy "%s"`, payload.Policy)
+			return NewError(event.FlowID, err.Error()), err
+		}
+
+		run, err := newBatchRun(mux, event.FlowID, payload.Events)
+		if err != nil {
+			return NewError(event.FlowID, err.Error()), err
+		}
+		run.policy = payload.Policy
+
+		ctx = context.WithValue(ctx, batchDepthKey{}, depth+1)
+
+		if payload.Parallel || run.hasDependencies() {
+			run.parallel(ctx)
+		} else {
+			run.sequential(ctx)
+		}
+
+		if run.policy == BatchAtomic && run.stopped {
+			run.compensate(ctx)
+		}
+
+		response, err := NewResponse(event, run.responses())
+		if err != nil {
+			return response, err
+		}
+
+		return WithMetadataField(response, MetadataBatch, run.summary()), nil
+	}
+}
+
+// newBatchRun builds the DAG of items, the dependencies include the events
+// referenced by templates. Unknown dependencies and cycles are errors.
+func newBatchRun(mux *Mux, flowID string, items []batchItem) (*batchRun, error) {
+	run := &batchRun{mux: mux, flowID: flowID}
+	ids := map[string]int{}
+
+	for i, item := range items {
+		if item.ID != "" {
+			if _, ok := ids[item.ID]; ok {
+				return nil, fmt.Errorf(`Duplicated event id "%s"`, item.ID)
+			}
+			ids[item.ID] = i
+		}
+
+		run.nodes = append(run.nodes, &batchNode{index: i, item: item, done: make(chan struct{})})
+	}
+
+	for i, node := range run.nodes {
+		dependsOn, err := node.references()
+		if err != nil {
+			return nil, err
+		}
+
+		seen := map[int]bool{}
+
+		for _, id := range append(node.item.DependsOn, dependsOn...) {
+			dep, ok := ids[id]
+			if !ok {
+				return nil, fmt.Errorf(`Event "%s" depends on unknown event "%s"`, node.item.Name, id)
+			}
+			if dep == i {
+				return nil, fmt.Errorf(`Event "%s" depends on itself`, node.item.Name)
+			}
+			if seen[dep] {
+				continue
+			}
+			seen[dep] = true
+
+			node.deps = append(node.deps, dep)
+			run.nodes[dep].dependents = append(run.nodes[dep].dependents, i)
+		}
+	}
+
+	if err := run.sort(); err != nil {
+		return nil, err
+	}
+
+	return run, nil
+}
+
+// sort orders the nodes after their dependencies, keeping the batch order
+// of independent nodes
+func (r *batchRun) sort() error {
+	pending := make([]int, len(r.nodes))
+	for i, node := range r.nodes {
+		pending[i] = len(node.deps)
+	}
+
+	placed := make([]bool, len(r.nodes))
+
+	for len(r.order) < len(r.nodes) {
+		next := -1
+		for i := range r.nodes {
+			if !placed[i] && pending[i] == 0 {
+				next = i
+				break
+			}
+		}
+
+		if next < 0 {
+			return fmt.Errorf("Batch has a dependency cycle")
+		}
+
+		placed[next] = true
+		r.order = append(r.order, next)
+
+		for _, dependent := range r.nodes[next].dependents {
+			pending[dependent]--
+		}
+	}
+
+	return nil
+}
+
+func (r *batchRun) hasDependencies() bool {
+	for _, node := range r.nodes {
+		if len(node.deps) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *batchRun) sequential(ctx context.Context) {
+	for _, i := range r.order {
+		r.execute(ctx, r.nodes[i])
+	}
+}
+
+func (r *batchRun) parallel(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for _, node := range r.nodes {
+		wg.Add(1)
+		go func(node *batchNode) {
+			defer wg.Done()
+
+			for _, dep := range node.deps {
+				<-r.nodes[dep].done
+			}
+
+			r.execute(ctx, node)
+		}(node)
+	}
+
+	wg.Wait()
+}
+
+// execute serves the event of node, or skips it when a dependency failed
+// or the batch stopped. Dependencies are done before it is called.
+func (r *batchRun) execute(ctx context.Context, node *batchNode) {
+	defer close(node.done)
+
+	event := node.item.Event
+
+	for _, dep := range node.deps {
+		if r.nodes[dep].status != BatchSucceeded {
+			node.skip(fmt.Sprintf(
+				`Event "%s" skipped: dependency "%s" failed`,
+				event.Name, r.nodes[dep].item.ID,
+			))
+			return
+		}
+	}
+
+	if r.isStopped() {
+		node.skip(fmt.Sprintf(`Event "%s" skipped: batch stopped after a failure`, event.Name))
+		return
+	}
+
+	payload, err := r.resolve(event.Payload)
+	if err != nil {
+		r.fail(node, NewError(event.FlowID, err.Error()), err.Error())
+		return
+	}
+	event.Payload = payload
+	node.event = event
+
+	h, ok := r.mux.get(event.Name, event.Version)
+	if !ok {
+		message := fmt.Sprintf(`Event "%s" not found`, event.Name)
+
+		r.mu.Lock()
+		r.mux.tracer.NoticeEventError(ctx, event, errors.New(message))
+		r.mu.Unlock()
+
+		r.fail(node, NewError(event.FlowID, message), message)
+		return
+	}
+
+	start := time.Now()
+	response, err := r.serve(ctx, h, event)
+	node.duration = time.Since(start)
+
+	if err != nil {
+		r.fail(node, response, err.Error())
+		return
+	}
+
+	if response.Name == "error" {
+		r.fail(node, response, errorMessage(response))
+		return
+	}
+
+	node.response = response
+	node.status = BatchSucceeded
+
+	r.mu.Lock()
+	r.completed = append(r.completed, node.index)
+	r.mu.Unlock()
+}
+
+// serve calls the handler of a sub-event, tracked when the tracker is an
+// EventTracker. Handler errors are noticed by every tracker.
+func (r *batchRun) serve(ctx context.Context, h Handler, event Event) (Event, error) {
+	r.mu.Lock()
+	ctx, tracked := r.mux.startEvent(ctx, event)
+	r.mu.Unlock()
+
+	response, err := h.Serve(ctx, event)
+
+	r.mu.Lock()
+	if tracked {
+		ctx = r.mux.tracer.End(ctx, event, err)
+	}
+	if err != nil {
+		r.mux.tracer.NoticeEventError(ctx, event, err)
+	}
+	r.mu.Unlock()
+
+	return response, err
+}
+
+// fail marks node as failed, stopping the batch unless its policy is
+// BatchContinue
+func (r *batchRun) fail(node *batchNode, response Event, message string) {
+	node.response = response
+	node.status = BatchFailed
+	node.err = message
+
+	if r.policy != BatchContinue {
+		r.mu.Lock()
+		r.stopped = true
+		r.mu.Unlock()
+	}
+}
+
+func (r *batchRun) isStopped() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.stopped
+}
+
+// compensate undoes the succeeded events in the reverse order they
+// completed, the events whose handlers are not Compensators are kept
+func (r *batchRun) compensate(ctx context.Context) {
+	for i := len(r.completed) - 1; i >= 0; i-- {
+		node := r.nodes[r.completed[i]]
+
+		h := r.mux.events[eventKey{node.event.Name, node.event.Version}]
+		compensator, ok := h.(Compensator)
+		if !ok {
+			continue
+		}
+
+		if err := compensator.Compensate(ctx, node.event, node.response); err != nil {
+			r.mux.tracer.NoticeEventError(ctx, node.event, err)
+			node.status = BatchCompensationFailed
+			node.err = err.Error()
+			continue
+		}
+
+		node.status = BatchCompensated
+	}
+}
+
+func (r *batchRun) responses() []BatchResponse {
+	responses := make([]BatchResponse, 0, len(r.nodes))
+	for _, node := range r.nodes {
+		responses = append(responses, BatchResponse{
+			Event:      node.response,
+			RequestID:  node.item.ID,
+			Status:     node.status,
+			DurationMs: float64(node.duration) / float64(time.Millisecond),
+		})
+	}
+	return responses
+}
+
+func (r *batchRun) summary() BatchSummary {
+	summary := BatchSummary{Policy: r.policy, Items: []BatchItemStatus{}}
+
+	for _, node := range r.nodes {
+		summary.Items = append(summary.Items, BatchItemStatus{
+			ID:     node.item.ID,
+			Name:   node.item.Name,
+			Status: node.status,
+			Error:  node.err,
+		})
+	}
+
+	return summary
+}
+
+func (n *batchNode) skip(message string) {
+	n.response = NewError(n.item.FlowID, message)
+	n.status = BatchSkipped
+	n.err = message
+}
+
+// references returns the IDs of the events referenced by the templates of
+// the payload
+func (n *batchNode) references() ([]string, error) {
+	ids := []string{}
+
+	if len(n.item.Payload) == 0 {
+		return ids, nil
+	}
+
+	value, err := decodeJSON(n.item.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	walkBatchStrings(value, func(s string) interface{} {
+		for _, match := range batchReference.FindAllStringSubmatch(s, -1) {
+			ids = append(ids, match[1])
+		}
+		return s
+	})
+
+	return ids, nil
+}
+
+// resolve replaces the templates of payload by the fields of the responses
+func (r *batchRun) resolve(payload json.RawMessage) (json.RawMessage, error) {
+	if len(payload) == 0 || !batchReference.Match(payload) {
+		return payload, nil
+	}
+
+	value, err := decodeJSON(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var resolveErr error
+
+	value = walkBatchStrings(value, func(s string) interface{} {
+		if match := batchReference.FindStringSubmatch(s); match != nil && match[0] == s {
+			v, err := r.lookup(match[1], match[2])
+			if err != nil {
+				resolveErr = err
+			}
+			return v
+		}
+
+		return batchReference.ReplaceAllStringFunc(s, func(template string) string {
+			match := batchReference.FindStringSubmatch(template)
+
+			v, err := r.lookup(match[1], match[2])
+			if err != nil {
+				resolveErr = err
+				return ""
+			}
+
+			if str, ok := v.(string); ok {
+				return str
+			}
+
+			b, _ := json.Marshal(v)
+			return string(b)
+		})
+	})
+
+	if resolveErr != nil {
+		return nil, resolveErr
+	}
+
+	return json.Marshal(value)
+}
+
+// lookup evaluates a JSON Pointer on the response payload of the event id
+func (r *batchRun) lookup(id, pointer string) (interface{}, error) {
+	for _, node := range r.nodes {
+		if node.item.ID != id {
+			continue
+		}
+
+		value, err := decodeJSON(node.response.Payload)
+		if err != nil {
+			return nil, fmt.Errorf(`Invalid response of event "%s": %s`, id, err)
+		}
+
+		value, err = jsonPointer(value, pointer)
+		if err != nil {
+			return nil, fmt.Errorf(`Reference "%s#%s": %s`, id, pointer, err)
+		}
+
+		return value, nil
+	}
+
+	return nil, fmt.Errorf(`Unknown event "%s"`, id)
+}
+
+func decodeJSON(payload json.RawMessage) (interface{}, error) {
+	if len(payload) == 0 {
+		return nil, nil
+	}
+
+	var value interface{}
+
+	decoder := json.NewDecoder(strings.NewReader(string(payload)))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// walkBatchStrings replaces every string of a decoded JSON value by the
+// result of replace
+func walkBatchStrings(value interface{}, replace func(string) interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return replace(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = walkBatchStrings(v[i], replace)
+		}
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = walkBatchStrings(v[key], replace)
+		}
+	}
+
+	return value
+}
+
+// jsonPointer evaluates a RFC 6901 JSON Pointer on a decoded JSON value
+func jsonPointer(value interface{}, pointer string) (interface{}, error) {
+	if pointer == "" {
+		return value, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("pointer must start with /")
+	}
+
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = unescapePointerToken(token)
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			field, ok := v[token]
+			if !ok {
+				return nil, fmt.Errorf("field %q not found", token)
+			}
+			value = field
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, fmt.Errorf("index %q out of range", token)
+			}
+			value = v[i]
+		default:
+			return nil, fmt.Errorf("field %q not found", token)
+		}
+	}
+
+	return value, nil
+}
@@ -0,0 +1,428 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func runBatch(t *testing.T, mux *Mux, payload string) ([]Event, error) {
+	response, err := Batch(mux)(context.Background(), Event{
+		Name:    "batch",
+		Version: 1,
+		ID:      RandomID(),
+		FlowID:  "flow",
+		Payload: json.RawMessage(payload),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	responses := []Event{}
+	if err := json.Unmarshal(response.Payload, &responses); err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	return responses, nil
+}
+
+func Test_Batch_DependsOn(t *testing.T) {
+	mux := NewMux()
+
+	mux.Add("user.get", 1, HandlerFunc(func(_ context.Context, event Event) (Event, error) {
+		return NewResponse(event, map[string]interface{}{
+			"id":     "u-1",
+			"name":   "Ana",
+			"orders": []int{10, 20},
+		})
+	}))
+	mux.Add("echo", 1, HandlerFunc(mockHandlerFunc))
+
+	responses, err := runBatch(t, mux, `{"events": [
+		{"name": "echo", "version": 1, "id": "greeting", "dependsOn": ["user"],
+		 "payload": {"text": "Hello ${user#/name}", "userId": "${user#/id}", "order": "${user#/orders/1}"}},
+		{"name": "user.get", "version": 1, "id": "user"}
+	]}`)
+	if err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	if len(responses) != 2 {
+		t.Fatalf("len(responses) == %d, wants: %d", len(responses), 2)
+	}
+
+	if responses[1].Name != "user.get:response" {
+		t.Errorf("responses[1].Name == %s, wants: %s", responses[1].Name, "user.get:response")
+	}
+
+	payload := struct {
+		Text   string `json:"text"`
+		UserID string `json:"userId"`
+		Order  int    `json:"order"`
+	}{}
+	json.Unmarshal(responses[0].Payload, &payload)
+
+	if payload.Text != "Hello Ana" || payload.UserID != "u-1" || payload.Order != 20 {
+		t.Errorf("payload == %+v, wants the user fields", payload)
+	}
+}
+
+func Test_Batch_Parallel(t *testing.T) {
+	mux := NewMux()
+
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+
+	mux.Add("slow", 1, HandlerFunc(func(_ context.Context, event Event) (Event, error) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+
+		return NewResponse(event, nil)
+	}))
+
+	// a and b are independent, c waits for both
+	_, err := runBatch(t, mux, `{"events": [
+		{"name": "slow", "version": 1, "id": "a"},
+		{"name": "slow", "version": 1, "id": "b"},
+		{"name": "slow", "version": 1, "id": "c", "dependsOn": ["a", "b"]}
+	]}`)
+	if err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	if maxRunning != 2 {
+		t.Errorf("maxRunning == %d, wants: %d", maxRunning, 2)
+	}
+}
+
+func Test_Batch_SkipsDependents(t *testing.T) {
+	mux := NewMux()
+	mux.Add("fail", 1, HandlerFunc(mockEventError))
+	mux.Add("echo", 1, HandlerFunc(mockHandlerFunc))
+
+	responses, err := runBatch(t, mux, `{"events": [
+		{"name": "fail", "version": 1, "id": "a"},
+		{"name": "echo", "version": 1, "id": "b", "dependsOn": ["a"]},
+		{"name": "echo", "version": 1, "id": "c", "payload": "${b#}"},
+		{"name": "echo", "version": 1, "id": "d"},
+		{"name": "echo", "version": 1, "id": "e", "payload": "${d#/missing}"}
+	]}`)
+	if err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	wants := []string{"fail:error", "error", "error", "echo:response", "error"}
+	for i, name := range wants {
+		if responses[i].Name != name {
+			t.Errorf("responses[%d].Name == %s, wants: %s", i, responses[i].Name, name)
+		}
+	}
+
+	message := errorMessage(responses[2])
+	if message != `Event "echo" skipped: dependency "b" failed` {
+		t.Errorf("message == %s", message)
+	}
+}
+
+func Test_Batch_InvalidGraph(t *testing.T) {
+	mux := NewMux()
+
+	tests := []string{
+		`{"events": [{"name": "a", "id": "a", "dependsOn": ["b"]}, {"name": "b", "id": "b", "dependsOn": ["a"]}]}`,
+		`{"events": [{"name": "a", "id": "a", "dependsOn": ["a"]}]}`,
+		`{"events": [{"name": "a", "id": "a", "dependsOn": ["unknown"]}]}`,
+		`{"events": [{"name": "a", "id": "a"}, {"name": "b", "id": "a"}]}`,
+		`{"events": [{"name": "a", "id": "a", "payload": "${unknown#/id}"}]}`,
+	}
+
+	for _, payload := range tests {
+		if _, err := runBatch(t, mux, payload); err == nil {
+			t.Errorf("Error expected for %s", payload)
+		}
+	}
+}
+
+func Test_jsonPointer(t *testing.T) {
+	var value interface{}
+	json.Unmarshal([]byte(`{"a/b": {"m~n": [1, 2]}}`), &value)
+
+	v, err := jsonPointer(value, "/a~1b/m~0n/1")
+	if err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	if v != 2.0 {
+		t.Errorf("v == %v, wants: %v", v, 2)
+	}
+
+	if _, err := jsonPointer(value, "a"); err == nil {
+		t.Error("Error expected without leading /")
+	}
+
+	if _, err := jsonPointer(value, "/a~1b/m~0n/2"); err == nil {
+		t.Error("Error expected out of range")
+	}
+
+	if _, err := jsonPointer(value, "/x"); err == nil {
+		t.Error("Error expected for a missing field")
+	}
+}
+
+type mockCompensator struct {
+	mu          sync.Mutex
+	compensated []string
+	err         error
+}
+
+func (c *mockCompensator) Serve(_ context.Context, event Event) (Event, error) {
+	return NewResponse(event, event.ID)
+}
+
+func (c *mockCompensator) Compensate(_ context.Context, event Event, response Event) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.compensated = append(c.compensated, event.ID)
+	return c.err
+}
+
+func batchSummary(t *testing.T, mux *Mux, payload string) BatchSummary {
+	response, err := Batch(mux)(context.Background(), Event{
+		Name:    "batch",
+		Version: 1,
+		FlowID:  "flow",
+		Payload: json.RawMessage(payload),
+	})
+	if err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	summary := BatchSummary{}
+	if !MetadataField(response, MetadataBatch, &summary) {
+		t.Fatalf("Batch summary not found in %s", response.Metadata)
+	}
+
+	return summary
+}
+
+func assertBatchStatuses(t *testing.T, summary BatchSummary, wants ...BatchStatus) {
+	if len(summary.Items) != len(wants) {
+		t.Fatalf("len(summary.Items) == %d, wants: %d", len(summary.Items), len(wants))
+	}
+
+	for i, status := range wants {
+		if summary.Items[i].Status != status {
+			t.Errorf("summary.Items[%d].Status == %s, wants: %s", i, summary.Items[i].Status, status)
+		}
+	}
+}
+
+func Test_Batch_Policies(t *testing.T) {
+	compensator := &mockCompensator{}
+
+	mux := NewMux()
+	mux.Add("reserve", 1, compensator)
+	mux.Add("notify", 1, HandlerFunc(mockHandlerFunc))
+	mux.Add("fail", 1, HandlerFunc(mockEventError))
+
+	events := `"events": [
+		{"name": "reserve", "version": 1, "id": "a"},
+		{"name": "notify", "version": 1, "id": "b"},
+		{"name": "reserve", "version": 1, "id": "c"},
+		{"name": "fail", "version": 1, "id": "d"},
+		{"name": "reserve", "version": 1, "id": "e"}
+	]`
+
+	summary := batchSummary(t, mux, `{`+events+`}`)
+	if summary.Policy != BatchContinue {
+		t.Errorf("summary.Policy == %s, wants: %s", summary.Policy, BatchContinue)
+	}
+	assertBatchStatuses(t, summary, BatchSucceeded, BatchSucceeded, BatchSucceeded, BatchFailed, BatchSucceeded)
+
+	if summary.Items[3].Error != "some error" {
+		t.Errorf("summary.Items[3].Error == %s, wants: %s", summary.Items[3].Error, "some error")
+	}
+
+	summary = batchSummary(t, mux, `{"policy": "stopOnFirstError", `+events+`}`)
+	assertBatchStatuses(t, summary, BatchSucceeded, BatchSucceeded, BatchSucceeded, BatchFailed, BatchSkipped)
+
+	if len(compensator.compensated) != 0 {
+		t.Errorf("len(compensated) == %d, wants: %d", len(compensator.compensated), 0)
+	}
+
+	summary = batchSummary(t, mux, `{"policy": "atomic", `+events+`}`)
+	assertBatchStatuses(t, summary, BatchCompensated, BatchSucceeded, BatchCompensated, BatchFailed, BatchSkipped)
+
+	// Reverse completion order
+	if len(compensator.compensated) != 2 || compensator.compensated[0] != "c" || compensator.compensated[1] != "a" {
+		t.Errorf("compensated == %v, wants: [c a]", compensator.compensated)
+	}
+
+	compensator.err = errors.New("already shipped")
+	summary = batchSummary(t, mux, `{"policy": "atomic", `+events+`}`)
+	assertBatchStatuses(t, summary, BatchCompensationFailed, BatchSucceeded, BatchCompensationFailed, BatchFailed, BatchSkipped)
+
+	// Atomic batches without failures are kept
+	compensator.compensated = nil
+	summary = batchSummary(t, mux, `{"policy": "atomic", "events": [{"name": "reserve", "version": 1, "id": "a"}]}`)
+	assertBatchStatuses(t, summary, BatchSucceeded)
+
+	if len(compensator.compensated) != 0 {
+		t.Errorf("len(compensated) == %d, wants: %d", len(compensator.compensated), 0)
+	}
+
+	if _, err := runBatch(t, mux, `{"policy": "sometimes", "events": []}`); err == nil {
+		t.Error("Error expected with an unknown policy")
+	}
+}
+
+func Test_BatchWithLimits(t *testing.T) {
+	mux := NewMux()
+	mux.Add("echo", 1, HandlerFunc(mockHandlerFunc))
+	mux.Add("batch", 1, BatchWithLimits(mux, BatchLimits{MaxEvents: 2, MaxPayloadBytes: 200, MaxDepth: 0}))
+
+	large := `{"events": [{"name": "echo", "version": 1, "payload": "` + strings.Repeat("x", 200) + `"}]}`
+
+	tests := []struct {
+		payload string
+		message string
+	}{
+		{
+			`{"events": [{"name": "echo", "version": 1}, {"name": "echo", "version": 1}, {"name": "echo", "version": 1}]}`,
+			"Batch has 3 events, the limit is 2",
+		},
+		{
+			large,
+			fmt.Sprintf("Batch payload has %d bytes, the limit is 200", len(large)),
+		},
+	}
+
+	for _, test := range tests {
+		response, err := BatchWithLimits(mux, BatchLimits{MaxEvents: 2, MaxPayloadBytes: 200})(context.Background(), Event{
+			Name:    "batch",
+			Version: 1,
+			Payload: json.RawMessage(test.payload),
+		})
+
+		if err == nil {
+			t.Errorf("Error expected for %s", test.payload)
+		}
+
+		if response.Name != "error" || errorMessage(response) != test.message {
+			t.Errorf("response == %s %s, wants error: %s", response.Name, response.Payload, test.message)
+		}
+	}
+
+	// Nested batches are rejected with depth zero
+	summary := batchSummary(t, mux, `{"events": [
+		{"name": "batch", "version": 1, "payload": {"events": [{"name": "echo", "version": 1}]}}
+	]}`)
+	assertBatchStatuses(t, summary, BatchFailed)
+
+	if summary.Items[0].Error != "Batch nested 1 levels deep, the limit is 0" {
+		t.Errorf("summary.Items[0].Error == %s", summary.Items[0].Error)
+	}
+}
+
+func Test_Batch_NestedDepth(t *testing.T) {
+	mux := NewMux()
+	mux.Add("echo", 1, HandlerFunc(mockHandlerFunc))
+	mux.Add("batch", 1, Batch(mux))
+
+	inner := `{"name": "batch", "version": 1, "payload": {"events": [{"name": "echo", "version": 1}]}}`
+
+	summary := batchSummary(t, mux, `{"events": [`+inner+`]}`)
+	assertBatchStatuses(t, summary, BatchSucceeded)
+
+	// The second level batch is rejected by the first level one
+	responses, err := runBatch(t, mux, `{"events": [{"name": "batch", "version": 1, "payload": {"events": [`+inner+`]}}]}`)
+	if err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	nested := BatchSummary{}
+	MetadataField(responses[0], MetadataBatch, &nested)
+	assertBatchStatuses(t, nested, BatchFailed)
+}
+
+type mockEventTracker struct {
+	*MockTracker
+
+	started []string
+}
+
+func (t *mockEventTracker) StartEvent(ctx context.Context, event Event) context.Context {
+	t.started = append(t.started, event.ID)
+	return ctx
+}
+
+func Test_Batch_Tracker(t *testing.T) {
+	ends := map[string]error{}
+	noticed := []string{}
+
+	mockTracker := &MockTracker{
+		NoticeEventErrorFn: func(ctx context.Context, event Event, err error) context.Context {
+			noticed = append(noticed, event.ID)
+			return ctx
+		},
+		EndFn: func(ctx context.Context, event Event, err error) context.Context {
+			ends[event.ID] = err
+			return ctx
+		},
+	}
+
+	batch := `{"parallel": true, "events": [
+		{"name": "echo", "version": 1, "id": "a"},
+		{"name": "fail", "version": 1, "id": "b"}
+	]}`
+
+	// Trackers without StartEvent only notice the errors
+	mux := NewMuxWithTracker(mockTracker)
+	mux.Add("echo", 1, HandlerFunc(mockHandlerFunc))
+	mux.Add("fail", 1, HandlerFunc(mockEventError))
+
+	if _, err := runBatch(t, mux, batch); err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	if mockTracker.StartCount != 0 || mockTracker.EndCount != 0 {
+		t.Errorf("StartCount == %d, EndCount == %d, wants: 0", mockTracker.StartCount, mockTracker.EndCount)
+	}
+
+	if len(noticed) != 1 || noticed[0] != "b" {
+		t.Errorf("noticed == %v, wants: [b]", noticed)
+	}
+
+	eventTracker := &mockEventTracker{MockTracker: mockTracker}
+
+	mux = NewMuxWithTracker(eventTracker)
+	mux.Add("echo", 1, HandlerFunc(mockHandlerFunc))
+	mux.Add("fail", 1, HandlerFunc(mockEventError))
+
+	if _, err := runBatch(t, mux, batch); err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	if len(eventTracker.started) != 2 || mockTracker.StartCount != 0 || mockTracker.EndCount != 2 {
+		t.Errorf("started == %v, StartCount == %d, EndCount == %d, wants: 2, 0, 2", eventTracker.started, mockTracker.StartCount, mockTracker.EndCount)
+	}
+
+	if ends["a"] != nil || ends["b"] == nil {
+		t.Errorf("ends == %v, wants an error for b", ends)
+	}
+}
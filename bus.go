@@ -0,0 +1,327 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Errors reported by the Bus
+var (
+	ErrBusClosed    = errors.New("events: bus closed")
+	ErrEventDropped = errors.New("events: event dropped, subscriber queue is full")
+)
+
+// BackpressurePolicy - What an asynchronous subscription does when its
+// queue is full
+type BackpressurePolicy int
+
+const (
+	// Block makes Publish wait for room in the queue
+	Block BackpressurePolicy = iota
+	// DropNewest discards the event being published
+	DropNewest
+	// DropOldest discards the oldest queued event
+	DropOldest
+)
+
+// AsyncOptions - Options of an asynchronous subscription
+type AsyncOptions struct {
+	QueueSize int
+	Policy    BackpressurePolicy
+}
+
+// Bus - In-process publish/subscribe of events. Every subscriber of an
+// event name receives the published events, responses are ignored.
+type Bus struct {
+	mu            sync.RWMutex
+	subscriptions map[string][]*subscription
+	nextID        int
+	closed        bool
+	done          chan struct{}
+	closeDone     sync.Once
+	workers       sync.WaitGroup
+
+	onError func(Event, error)
+}
+
+type subscription struct {
+	id      int
+	name    string
+	handler Handler
+	queue   chan Event
+	policy  BackpressurePolicy
+	closed  bool
+
+	// done is closed when the subscription ends, queue is never closed so
+	// publishers can send without holding the lock of the bus
+	done chan struct{}
+
+	// sending counts the publishers that passed the checks of enqueue, the
+	// worker waits for them before its last drain of the queue
+	sending sync.WaitGroup
+}
+
+// allEvents is the subscription name used by SubscribeAll
+const allEvents = "*"
+
+// NewBus returns a new Bus ignoring subscriber errors
+func NewBus() *Bus {
+	return NewBusWithErrorHandler(func(Event, error) {})
+}
+
+// NewBusWithErrorHandler returns a new Bus reporting subscriber errors,
+// panics and dropped events to onError
+func NewBusWithErrorHandler(onError func(Event, error)) *Bus {
+	return &Bus{
+		subscriptions: map[string][]*subscription{},
+		done:          make(chan struct{}),
+		onError:       onError,
+	}
+}
+
+// Subscribe adds a synchronous subscriber of name. It runs inside Publish.
+// The returned function removes the subscription.
+func (b *Bus) Subscribe(name string, handler Handler) func() {
+	return b.subscribe(name, handler, nil)
+}
+
+// SubscribeAsync adds a subscriber of name with its own queue and goroutine
+func (b *Bus) SubscribeAsync(name string, handler Handler, options AsyncOptions) func() {
+	return b.subscribe(name, handler, &options)
+}
+
+// SubscribeAll adds a synchronous subscriber of every event
+func (b *Bus) SubscribeAll(handler Handler) func() {
+	return b.subscribe(allEvents, handler, nil)
+}
+
+func (b *Bus) subscribe(name string, handler Handler, options *AsyncOptions) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return func() {}
+	}
+
+	b.nextID++
+	s := &subscription{id: b.nextID, name: name, handler: handler}
+
+	if options != nil {
+		size := options.QueueSize
+		if size <= 0 {
+			size = 1
+		}
+
+		s.queue = make(chan Event, size)
+		s.done = make(chan struct{})
+		s.policy = options.Policy
+
+		b.workers.Add(1)
+		go b.work(s)
+	}
+
+	b.subscriptions[name] = append(b.subscriptions[name], s)
+
+	return func() { b.unsubscribe(s) }
+}
+
+func (b *Bus) unsubscribe(s *subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subscriptions := b.subscriptions[s.name]
+
+	for i, current := range subscriptions {
+		if current.id != s.id {
+			continue
+		}
+
+		b.subscriptions[s.name] = append(subscriptions[:i:i], subscriptions[i+1:]...)
+		s.end()
+		return
+	}
+}
+
+// Publish delivers the event to every subscriber of its name. Synchronous
+// subscribers run before Publish returns, their errors are isolated and
+// reported to the error handler. Publish fails only when the bus is closed
+// or when ctx ends while blocked on a full queue, the event is still
+// delivered to the other subscribers and their errors are joined.
+func (b *Bus) Publish(ctx context.Context, event Event) error {
+	b.mu.RLock()
+
+	if b.closed {
+		b.mu.RUnlock()
+		return ErrBusClosed
+	}
+
+	subscriptions := append(b.subscriptions[event.Name][:0:0], b.subscriptions[event.Name]...)
+	subscriptions = append(subscriptions, b.subscriptions[allEvents]...)
+
+	b.mu.RUnlock()
+
+	errs := []error{}
+
+	for _, s := range subscriptions {
+		if s.queue == nil {
+			b.deliver(ctx, s, event)
+			continue
+		}
+
+		if err := b.enqueue(ctx, s, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// A single error is returned as is, to be compared with the sentinels
+	if len(errs) == 1 {
+		return errs[0]
+	}
+
+	return errors.Join(errs...)
+}
+
+// end stops an asynchronous subscription, its worker delivers the queued
+// events and exits. The bus lock must be held.
+func (s *subscription) end() {
+	if s.queue != nil && !s.closed {
+		s.closed = true
+		close(s.done)
+	}
+}
+
+// enqueue checks the subscription under the read lock and sends without
+// it, a publisher blocked on a full queue must not block Subscribe and
+// unsubscribe, which would in turn block the Publish calls of the worker
+// draining the queue. The worker drains the queue again once the
+// publishers past the checks are done, so their events are not lost.
+func (b *Bus) enqueue(ctx context.Context, s *subscription, event Event) error {
+	b.mu.RLock()
+	closed, ended := b.closed, s.closed
+	if !closed && !ended {
+		s.sending.Add(1)
+	}
+	b.mu.RUnlock()
+
+	if closed {
+		return ErrBusClosed
+	}
+
+	if ended {
+		return nil
+	}
+
+	defer s.sending.Done()
+
+	switch s.policy {
+	case DropNewest:
+		select {
+		case s.queue <- event:
+		default:
+			b.onError(event, ErrEventDropped)
+		}
+	case DropOldest:
+		for {
+			select {
+			case s.queue <- event:
+				return nil
+			default:
+			}
+
+			select {
+			case old := <-s.queue:
+				b.onError(old, ErrEventDropped)
+			default:
+			}
+		}
+	default:
+		select {
+		case s.queue <- event:
+		case <-s.done:
+			// The subscription ended, by Close when done is closed too
+			select {
+			case <-b.done:
+				return ErrBusClosed
+			default:
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-b.done:
+			return ErrBusClosed
+		}
+	}
+
+	return nil
+}
+
+func (b *Bus) work(s *subscription) {
+	defer b.workers.Done()
+
+	for {
+		select {
+		case event := <-s.queue:
+			b.deliver(context.Background(), s, event)
+		case <-s.done:
+			s.sending.Wait()
+
+			for {
+				select {
+				case event := <-s.queue:
+					b.deliver(context.Background(), s, event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (b *Bus) deliver(ctx context.Context, s *subscription, event Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			b.onError(event, fmt.Errorf("events: subscriber panic: %v", r))
+		}
+	}()
+
+	if _, err := s.handler.Serve(ctx, event); err != nil {
+		b.onError(event, err)
+	}
+}
+
+// Close stops accepting events and waits for the asynchronous subscribers
+// to drain their queues or for ctx to end
+func (b *Bus) Close(ctx context.Context) error {
+	// Publishers blocked on full queues return when they see done
+	b.closeDone.Do(func() { close(b.done) })
+
+	b.mu.Lock()
+
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+
+	b.closed = true
+
+	for _, subscriptions := range b.subscriptions {
+		for _, s := range subscriptions {
+			s.end()
+		}
+	}
+	b.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		b.workers.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
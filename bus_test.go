@@ -0,0 +1,334 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockSubscriber struct {
+	mu       sync.Mutex
+	received []Event
+	block    chan struct{}
+	err      error
+}
+
+func (s *mockSubscriber) Serve(_ context.Context, event Event) (Event, error) {
+	if s.block != nil {
+		<-s.block
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.received = append(s.received, event)
+
+	return Event{}, s.err
+}
+
+func (s *mockSubscriber) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.received)
+}
+
+func Test_Bus_Publish(t *testing.T) {
+	errs := []error{}
+	bus := NewBusWithErrorHandler(func(_ Event, err error) { errs = append(errs, err) })
+
+	first := &mockSubscriber{err: errors.New("some error")}
+	second := &mockSubscriber{}
+	all := &mockSubscriber{}
+	other := &mockSubscriber{}
+
+	bus.Subscribe("user.created", first)
+	bus.Subscribe("user.created", second)
+	bus.Subscribe("user.deleted", other)
+	bus.SubscribeAll(all)
+	bus.Subscribe("user.created", HandlerFunc(func(context.Context, Event) (Event, error) {
+		panic("boom")
+	}))
+
+	err := bus.Publish(context.Background(), Event{Name: "user.created", Version: 1})
+
+	if err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+
+	if first.count() != 1 || second.count() != 1 || all.count() != 1 {
+		t.Error("Every subscriber must receive the event, even after failures")
+	}
+
+	if other.count() != 0 {
+		t.Error("Subscribers of other events must not receive the event")
+	}
+
+	if len(errs) != 2 {
+		t.Errorf("len(errs) == %d, wants: %d", len(errs), 2)
+	}
+}
+
+func Test_Bus_unsubscribe(t *testing.T) {
+	bus := NewBus()
+	subscriber := &mockSubscriber{}
+
+	unsubscribe := bus.Subscribe("user.created", subscriber)
+	unsubscribeAsync := bus.SubscribeAsync("user.created", subscriber, AsyncOptions{QueueSize: 1})
+
+	unsubscribe()
+	unsubscribeAsync()
+
+	bus.Publish(context.Background(), Event{Name: "user.created"})
+	bus.Close(context.Background())
+
+	if subscriber.count() != 0 {
+		t.Errorf("subscriber.count() == %d, wants: %d", subscriber.count(), 0)
+	}
+}
+
+func Test_Bus_async_drain(t *testing.T) {
+	bus := NewBus()
+	subscriber := &mockSubscriber{}
+
+	bus.SubscribeAsync("user.created", subscriber, AsyncOptions{QueueSize: 100})
+
+	for i := 0; i < 50; i++ {
+		bus.Publish(context.Background(), Event{Name: "user.created"})
+	}
+
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+
+	if subscriber.count() != 50 {
+		t.Errorf("subscriber.count() == %d, wants: %d", subscriber.count(), 50)
+	}
+
+	if err := bus.Publish(context.Background(), Event{Name: "user.created"}); err != ErrBusClosed {
+		t.Errorf("Expecting ErrBusClosed, got: %v", err)
+	}
+}
+
+func Test_Bus_backpressure(t *testing.T) {
+	cases := []struct {
+		policy   BackpressurePolicy
+		received []string
+	}{
+		{DropNewest, []string{"1", "2"}},
+		{DropOldest, []string{"1", "4"}},
+	}
+
+	for _, c := range cases {
+		var mu sync.Mutex
+		dropped := []string{}
+		bus := NewBusWithErrorHandler(func(event Event, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err == ErrEventDropped {
+				dropped = append(dropped, event.ID)
+			}
+		})
+
+		started := make(chan struct{}, 10)
+		subscriber := &mockSubscriber{block: make(chan struct{})}
+
+		bus.SubscribeAsync("e", HandlerFunc(func(ctx context.Context, event Event) (Event, error) {
+			started <- struct{}{}
+			return subscriber.Serve(ctx, event)
+		}), AsyncOptions{QueueSize: 1, Policy: c.policy})
+
+		bus.Publish(context.Background(), Event{Name: "e", ID: "1"})
+		<-started
+
+		for _, id := range []string{"2", "3", "4"} {
+			bus.Publish(context.Background(), Event{Name: "e", ID: id})
+		}
+
+		close(subscriber.block)
+		bus.Close(context.Background())
+
+		ids := []string{}
+		for _, event := range subscriber.received {
+			ids = append(ids, event.ID)
+		}
+
+		if len(ids) != len(c.received) || ids[0] != c.received[0] || ids[1] != c.received[1] {
+			t.Errorf("policy %d: received == %v, wants: %v", c.policy, ids, c.received)
+		}
+
+		if len(dropped) != 2 {
+			t.Errorf("policy %d: len(dropped) == %d, wants: %d", c.policy, len(dropped), 2)
+		}
+	}
+}
+
+func Test_Bus_block(t *testing.T) {
+	bus := NewBus()
+	subscriber := &mockSubscriber{block: make(chan struct{})}
+
+	bus.SubscribeAsync("e", subscriber, AsyncOptions{QueueSize: 1, Policy: Block})
+
+	bus.Publish(context.Background(), Event{Name: "e"})
+	bus.Publish(context.Background(), Event{Name: "e"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := bus.Publish(ctx, Event{Name: "e"}); err != context.DeadlineExceeded {
+		t.Errorf("Expecting context.DeadlineExceeded, got: %v", err)
+	}
+
+	closeCtx, cancelClose := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelClose()
+
+	if err := bus.Close(closeCtx); err != context.DeadlineExceeded {
+		t.Errorf("Expecting Close to time out while the subscriber is blocked, got: %v", err)
+	}
+
+	close(subscriber.block)
+}
+
+func Test_Bus_Publish_every_subscriber(t *testing.T) {
+	bus := NewBus()
+	blocked := &mockSubscriber{block: make(chan struct{})}
+	all := &mockSubscriber{}
+
+	bus.SubscribeAsync("e", blocked, AsyncOptions{QueueSize: 1, Policy: Block})
+	bus.SubscribeAsync("e", &mockSubscriber{block: blocked.block}, AsyncOptions{QueueSize: 1, Policy: Block})
+	bus.SubscribeAll(all)
+
+	bus.Publish(context.Background(), Event{Name: "e"})
+	bus.Publish(context.Background(), Event{Name: "e"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := bus.Publish(ctx, Event{Name: "e"})
+
+	// The full queues fail, the other subscribers still get the event
+	if !errors.Is(err, context.Canceled) || err == context.Canceled {
+		t.Errorf("err == %v, wants both context.Canceled joined", err)
+	}
+
+	if all.count() != 3 {
+		t.Errorf("all.count() == %d, wants: %d", all.count(), 3)
+	}
+
+	close(blocked.block)
+	bus.Close(context.Background())
+}
+
+func Test_Bus_Close_publishers(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		bus := NewBus()
+		subscriber := &mockSubscriber{}
+
+		bus.SubscribeAsync("e", subscriber, AsyncOptions{QueueSize: 4, Policy: Block})
+
+		var published sync.WaitGroup
+		var mu sync.Mutex
+		accepted := 0
+
+		for p := 0; p < 8; p++ {
+			published.Add(1)
+
+			go func() {
+				defer published.Done()
+
+				for n := 0; n < 20; n++ {
+					if err := bus.Publish(context.Background(), Event{Name: "e"}); err == nil {
+						mu.Lock()
+						accepted++
+						mu.Unlock()
+					}
+				}
+			}()
+		}
+
+		bus.Close(context.Background())
+		published.Wait()
+
+		// Every event Publish accepted is delivered
+		if subscriber.count() != accepted {
+			t.Fatalf("subscriber.count() == %d, wants: %d", subscriber.count(), accepted)
+		}
+	}
+}
+
+func Test_Bus_block_subscribe(t *testing.T) {
+	bus := NewBus()
+	received := &mockSubscriber{}
+	gate := make(chan struct{})
+
+	bus.Subscribe("b", received)
+	bus.SubscribeAsync("a", HandlerFunc(func(ctx context.Context, event Event) (Event, error) {
+		<-gate
+		return Event{}, bus.Publish(ctx, Event{Name: "b"})
+	}), AsyncOptions{QueueSize: 1, Policy: Block})
+
+	ctx := context.Background()
+	bus.Publish(ctx, Event{Name: "a"})
+	bus.Publish(ctx, Event{Name: "a"})
+
+	done := make(chan struct{}, 2)
+
+	// Blocked on the full queue while a Subscribe waits for the lock
+	go func() {
+		bus.Publish(ctx, Event{Name: "a"})
+		done <- struct{}{}
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	go func() {
+		bus.Subscribe("c", &mockSubscriber{})
+		done <- struct{}{}
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	close(gate)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Publishing from a subscriber during a Subscribe must not deadlock")
+		}
+	}
+
+	if err := bus.Close(ctx); err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+
+	if received.count() != 3 {
+		t.Errorf("received.count() == %d, wants: %d", received.count(), 3)
+	}
+}
+
+func Test_Bus_reentrant(t *testing.T) {
+	bus := NewBus()
+	received := &mockSubscriber{}
+
+	bus.Subscribe("user.created", HandlerFunc(func(ctx context.Context, event Event) (Event, error) {
+		bus.Subscribe("welcome.sent", received)
+		return Event{}, bus.Publish(ctx, Event{Name: "welcome.sent"})
+	}))
+
+	done := make(chan error)
+	go func() { done <- bus.Publish(context.Background(), Event{Name: "user.created"}) }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf(`Error not expected: "%s"`, err.Error())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Publishing from a subscriber must not deadlock")
+	}
+
+	if received.count() != 1 {
+		t.Errorf("received.count() == %d, wants: %d", received.count(), 1)
+	}
+}
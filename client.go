@@ -0,0 +1,146 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// Client sends events to a Mux served over HTTP. Responses compressed with
+// gzip or zstd are decoded.
+type Client struct {
+	URL        string
+	HTTPClient *http.Client
+
+	// Encoding compresses the request bodies, EncodingGzip or
+	// EncodingZstd. Empty sends them uncompressed.
+	Encoding string
+
+	// Codec is the wire format of the events, nil sends JSON
+	Codec Codec
+}
+
+// NewClient returns a Client posting events to url
+func NewClient(url string) *Client {
+	return &Client{
+		URL:        url,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// Send posts the event and decodes the response event. Error events are
+// returned as regular responses, only transport failures are errors.
+func (c *Client) Send(ctx context.Context, event Event) (Event, error) {
+	response := Event{}
+
+	contentType := "application/json"
+
+	var body []byte
+	var err error
+
+	if c.Codec != nil {
+		contentType = c.Codec.ContentType()
+		body, err = c.Codec.Marshal(event)
+	} else {
+		body, err = json.Marshal(event)
+	}
+
+	if err != nil {
+		return response, err
+	}
+
+	if c.Encoding != "" {
+		if body, err = compress(c.Encoding, body); err != nil {
+			return response, err
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return response, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", contentType)
+	req.Header.Set("Accept-Encoding", EncodingZstd+", "+EncodingGzip)
+
+	if c.Encoding != "" {
+		req.Header.Set("Content-Encoding", c.Encoding)
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return response, err
+	}
+	defer resp.Body.Close()
+
+	reader, err := decompress(resp.Header.Get("Content-Encoding"), resp.Body, 0)
+	if err != nil {
+		return response, fmt.Errorf("events: %s", err)
+	}
+	defer reader.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(reader)
+		return response, fmt.Errorf("events: %s: %s", resp.Status, bytes.TrimSpace(b))
+	}
+
+	if c.Codec != nil && mediaType(resp.Header.Get("Content-Type")) == mediaType(contentType) {
+		data, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return response, err
+		}
+
+		err = c.Codec.Unmarshal(data, &response)
+		return response, err
+	}
+
+	err = json.NewDecoder(reader).Decode(&response)
+
+	return response, err
+}
+
+// SendBatch posts a batch event and returns its responses keyed by the ID
+// of their sub-events, see BatchResponses
+func (c *Client) SendBatch(ctx context.Context, batch Event) (map[string]BatchResponse, error) {
+	response, err := c.Send(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+
+	return BatchResponses(response)
+}
+
+// BatchResponses returns the responses of a batch response keyed by the ID
+// of their sub-events, the sub-events without ID are left out. A rejected
+// batch is returned as an error.
+func BatchResponses(response Event) (map[string]BatchResponse, error) {
+	if response.Name == "error" {
+		return nil, fmt.Errorf("events: batch failed: %s", errorMessage(response))
+	}
+
+	items := []BatchResponse{}
+	if err := json.Unmarshal(response.Payload, &items); err != nil {
+		return nil, err
+	}
+
+	responses := map[string]BatchResponse{}
+	for _, item := range items {
+		if item.RequestID != "" {
+			responses[item.RequestID] = item
+		}
+	}
+
+	return responses, nil
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
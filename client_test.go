@@ -0,0 +1,114 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_Client_Send(t *testing.T) {
+	mux := NewMux()
+	mux.Add("some event", 1, HandlerFunc(mockHandlerFunc))
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewClient(server.URL)
+
+	request := Event{
+		Name:    "some event",
+		Version: 1,
+		ID:      RandomID(),
+		FlowID:  RandomID(),
+		Payload: json.RawMessage(`{"a":1}`),
+	}
+
+	response, err := client.Send(context.Background(), request)
+
+	if err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+
+	if response.Name != "some event:response" {
+		t.Errorf(`response.Name == "%s", wants: "some event:response"`, response.Name)
+	}
+
+	if response.FlowID != request.FlowID {
+		t.Errorf("response.FlowID == %s, wants: %s", response.FlowID, request.FlowID)
+	}
+
+	request.Name = "unknown"
+	response, err = client.Send(context.Background(), request)
+
+	if err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+
+	if response.Name != "error" {
+		t.Errorf(`response.Name == "%s", wants: "error"`, response.Name)
+	}
+}
+
+func Test_Client_Send_status_error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broken", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	_, err := NewClient(server.URL).Send(context.Background(), Event{Name: "some event", Version: 1})
+
+	if err == nil {
+		t.Error("Expecting an error")
+	}
+}
+
+func Test_Client_SendBatch(t *testing.T) {
+	mux := NewMux()
+	mux.Add("some event", 1, HandlerFunc(mockHandlerFunc))
+	mux.Add("batch", 1, BatchWithLimits(mux, BatchLimits{MaxEvents: 3}))
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewClient(server.URL)
+
+	responses, err := client.SendBatch(context.Background(), Event{
+		Name:    "batch",
+		Version: 1,
+		Payload: json.RawMessage(`{"events": [
+			{"name": "some event", "version": 1, "id": "first", "payload": 1},
+			{"name": "unknown", "version": 1, "id": "second"},
+			{"name": "some event", "version": 1, "payload": 3}
+		]}`),
+	})
+
+	if err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+
+	if len(responses) != 2 {
+		t.Fatalf("len(responses) == %d, wants: %d", len(responses), 2)
+	}
+
+	first := responses["first"]
+	if first.Status != BatchSucceeded || first.Name != "some event:response" || string(first.Payload) != "1" {
+		t.Errorf("first == %+v, wants the succeeded response", first)
+	}
+
+	second := responses["second"]
+	if second.Status != BatchFailed || second.Name != "error" || second.RequestID != "second" {
+		t.Errorf("second == %+v, wants the not found error", second)
+	}
+
+	_, err = client.SendBatch(context.Background(), Event{
+		Name:    "batch",
+		Version: 1,
+		Payload: json.RawMessage(`{"events": [{}, {}, {}, {}]}`),
+	})
+
+	if err == nil || err.Error() != "events: batch failed: Batch has 4 events, the limit is 3" {
+		t.Errorf("err == %v, wants the limit error", err)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// open opens a file, stdin when name is "-", or an http(s) URL
+func open(name string, stdin io.Reader) (io.Reader, func(), error) {
+	switch {
+	case name == "-":
+		return stdin, func() {}, nil
+	case strings.HasPrefix(name, "http://"), strings.HasPrefix(name, "https://"):
+		resp, err := http.Get(name)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			b, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			return nil, nil, fmt.Errorf("%s: %s: %s", name, resp.Status, strings.TrimSpace(string(b)))
+		}
+
+		return resp.Body, func() { resp.Body.Close() }, nil
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return f, func() { f.Close() }, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	events "github.com/GuiaBolso/Go-Events"
+)
+
+func list(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	url := flags.String("url", "", "URL of the schema document (Mux.ServeSchema)")
+	file := flags.String("file", "", "schema document file (- for stdin)")
+	asJSON := flags.Bool("json", false, "print the schema document as JSON")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	source := *file
+	if *url != "" {
+		source = *url
+	}
+
+	if source == "" {
+		return errors.New("-url or -file is required")
+	}
+
+	r, closer, err := open(source, stdin)
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	document := events.SchemaDocument{}
+	if err := json.NewDecoder(r).Decode(&document); err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return printJSON(stdout, document)
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVERSION\tDOC")
+
+	for _, event := range document.Events {
+		doc := strings.Join(strings.Fields(event.Doc), " ")
+		fmt.Fprintf(w, "%s\t%d\t%s\n", event.Name, event.Version, doc)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,62 @@
+// Command events sends, inspects and replays events.
+//
+//	events send -url http://localhost:8080/events -name "user.get" -version 1 -payload '{"id": 42}'
+//	events send -url http://localhost:8080/events -file event.json
+//	events list -url http://localhost:8080/schema
+//	events replay -url http://localhost:8080/events -file events.jsonl -rate 10
+//	events validate -file events.jsonl
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string, stdin io.Reader, stdout, stderr io.Writer) error
+}
+
+var commands = []command{
+	{"send", "build an event from flags or a file and POST it", send},
+	{"list", "list the events of a Mux schema document", list},
+	{"replay", "re-send the events of a JSONL file", replay},
+	{"validate", "check events against the envelope schema", validate},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+
+		if err := cmd.run(args[1:], stdin, stdout, stderr); err != nil {
+			fmt.Fprintf(stderr, "events %s: %s\n", cmd.name, err)
+			return 1
+		}
+		return 0
+	}
+
+	usage(stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: events <command> [flags]")
+	fmt.Fprintln(w)
+
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.usage)
+	}
+}
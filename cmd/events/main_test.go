@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	events "github.com/GuiaBolso/Go-Events"
+)
+
+func mockServer() *httptest.Server {
+	mux := events.NewMux()
+
+	mux.Add("echo", 1, events.HandlerFunc(func(_ context.Context, event events.Event) (events.Event, error) {
+		return events.NewResponse(event, event.Payload)
+	}))
+
+	server := http.NewServeMux()
+	server.Handle("/events", mux)
+	server.HandleFunc("/schema", mux.ServeSchema)
+
+	return httptest.NewServer(server)
+}
+
+func runCommand(stdin string, args ...string) (string, string, int) {
+	var stdout, stderr bytes.Buffer
+
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+
+	return stdout.String(), stderr.String(), code
+}
+
+func Test_send(t *testing.T) {
+	server := mockServer()
+	defer server.Close()
+
+	stdout, stderr, code := runCommand("", "send", "-url", server.URL+"/events", "-name", "echo", "-payload", `{"a":1}`, "-flow-id", "flow-42")
+
+	if code != 0 {
+		t.Fatalf("code == %d, wants: 0. %s", code, stderr)
+	}
+
+	response := events.Event{}
+	json.Unmarshal([]byte(stdout), &response)
+
+	if response.Name != "echo:response" || response.FlowID != "flow-42" {
+		t.Errorf("Unexpected response: %s", stdout)
+	}
+
+	payload := map[string]int{}
+	json.Unmarshal(response.Payload, &payload)
+
+	if payload["a"] != 1 {
+		t.Errorf(`response.Payload == %s, wants: {"a":1}`, string(response.Payload))
+	}
+}
+
+func Test_send_file(t *testing.T) {
+	server := mockServer()
+	defer server.Close()
+
+	stdout, stderr, code := runCommand(`{"name":"echo","version":1,"payload":{"b":2}}`, "send", "-url", server.URL+"/events", "-file", "-")
+
+	if code != 0 {
+		t.Fatalf("code == %d, wants: 0. %s", code, stderr)
+	}
+
+	if !strings.Contains(stdout, `"b": 2`) {
+		t.Errorf("Unexpected response: %s", stdout)
+	}
+}
+
+func Test_send_invalid_payload(t *testing.T) {
+	_, stderr, code := runCommand("", "send", "-url", "http://localhost", "-name", "echo", "-payload", "INVALID")
+
+	if code != 1 || !strings.Contains(stderr, "payload is not valid JSON") {
+		t.Errorf("code == %d, stderr == %s", code, stderr)
+	}
+}
+
+func Test_list(t *testing.T) {
+	server := mockServer()
+	defer server.Close()
+
+	stdout, stderr, code := runCommand("", "list", "-url", server.URL+"/schema")
+
+	if code != 0 {
+		t.Fatalf("code == %d, wants: 0. %s", code, stderr)
+	}
+
+	if !strings.Contains(stdout, "NAME") || !strings.Contains(stdout, "echo") {
+		t.Errorf("Unexpected output: %s", stdout)
+	}
+}
+
+func Test_replay(t *testing.T) {
+	server := mockServer()
+	defer server.Close()
+
+	lines := []string{}
+	for i := 0; i < 3; i++ {
+		lines = append(lines, fmt.Sprintf(`{"name":"echo","version":1,"id":"id-%d","flowId":"flow","payload":{"n":%d}}`, i, i))
+	}
+
+	file := filepath.Join(t.TempDir(), "events.jsonl")
+	ioutil.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+
+	stdout, stderr, code := runCommand("", "replay", "-url", server.URL+"/events", "-file", file, "-rate", "100")
+
+	if code != 0 {
+		t.Fatalf("code == %d, wants: 0. %s", code, stderr)
+	}
+
+	responses := strings.Split(strings.TrimSpace(stdout), "\n")
+
+	if len(responses) != 3 {
+		t.Fatalf("len(responses) == %d, wants: %d", len(responses), 3)
+	}
+
+	response := events.Event{}
+	json.Unmarshal([]byte(responses[2]), &response)
+
+	if string(response.Payload) != `{"n":2}` {
+		t.Errorf(`response.Payload == %s, wants: {"n":2}`, string(response.Payload))
+	}
+}
+
+func Test_replay_unreachable(t *testing.T) {
+	_, _, code := runCommand(`{"name":"echo","version":1}`, "replay", "-url", "http://127.0.0.1:1/events")
+
+	if code != 1 {
+		t.Errorf("code == %d, wants: 1", code)
+	}
+}
+
+func Test_validate(t *testing.T) {
+	valid := `{"name":"echo","version":1,"id":"1","flowId":"2","payload":{}}`
+	stdout, _, code := runCommand(valid+"\n"+valid+"\n", "validate")
+
+	if code != 0 || !strings.Contains(stdout, "2 valid events") {
+		t.Errorf("code == %d, stdout == %s", code, stdout)
+	}
+
+	stdout, _, code = runCommand(`{"name":"echo","version":0,"payload":[],"extra":1}`, "validate")
+
+	if code != 1 {
+		t.Errorf("code == %d, wants: 1", code)
+	}
+
+	expected := []string{
+		`"id" is required`,
+		`"flowId" is required`,
+		`"extra" is not an envelope field`,
+		`"payload" must be an object`,
+		`"version" must be at least 1`,
+	}
+
+	for _, s := range expected {
+		if !strings.Contains(stdout, s) {
+			t.Errorf("Could not find %q in: %s", s, stdout)
+		}
+	}
+}
+
+func Test_run_usage(t *testing.T) {
+	_, stderr, code := runCommand("", "unknown")
+
+	if code != 2 || !strings.Contains(stderr, "usage") {
+		t.Errorf("code == %d, stderr == %s", code, stderr)
+	}
+}
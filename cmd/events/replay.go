@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	events "github.com/GuiaBolso/Go-Events"
+)
+
+func replay(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	url := flags.String("url", "", "Mux endpoint")
+	file := flags.String("file", "-", "JSONL file with one event per line (- for stdin)")
+	rate := flags.Float64("rate", 0, "maximum events per second (0 for no limit)")
+	newIDs := flags.Bool("new-ids", false, "generate new event IDs before sending")
+	timeout := flags.Duration("timeout", 30*time.Second, "timeout of each request")
+	keepGoing := flags.Bool("keep-going", false, "continue after a failed request")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *url == "" {
+		return errors.New("-url is required")
+	}
+
+	r, closer, err := open(*file, stdin)
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	var tick <-chan time.Time
+	if *rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / *rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	client := events.NewClient(*url)
+	out := json.NewEncoder(stdout)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	line, failures := 0, 0
+	for scanner.Scan() {
+		line++
+
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		event := events.Event{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return fmt.Errorf("line %d: %s", line, err)
+		}
+
+		if *newIDs || event.ID == "" {
+			event.ID = events.RandomID()
+		}
+
+		if tick != nil && line > 1 {
+			<-tick
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		response, err := client.Send(ctx, event)
+		cancel()
+
+		if err != nil {
+			if !*keepGoing {
+				return fmt.Errorf("line %d: %s", line, err)
+			}
+
+			failures++
+			fmt.Fprintf(stderr, "line %d: %s\n", line, err)
+			continue
+		}
+
+		if err := out.Encode(response); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("%d events failed", failures)
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"time"
+
+	events "github.com/GuiaBolso/Go-Events"
+)
+
+func send(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("send", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	url := flags.String("url", "", "Mux endpoint")
+	file := flags.String("file", "", "file with the event (- for stdin)")
+	name := flags.String("name", "", "event name")
+	version := flags.Int("version", 1, "event version")
+	payload := flags.String("payload", "{}", "event payload (JSON)")
+	metadata := flags.String("metadata", "", "event metadata (JSON)")
+	flowID := flags.String("flow-id", "", "flow ID (default random)")
+	timeout := flags.Duration("timeout", 30*time.Second, "request timeout")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *url == "" {
+		return errors.New("-url is required")
+	}
+
+	event := events.Event{}
+
+	if *file != "" {
+		r, closer, err := open(*file, stdin)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		if err := json.NewDecoder(r).Decode(&event); err != nil {
+			return err
+		}
+	} else {
+		if *name == "" {
+			return errors.New("-name or -file is required")
+		}
+
+		event = events.Event{
+			Name:    *name,
+			Version: *version,
+			ID:      events.RandomID(),
+			FlowID:  *flowID,
+			Payload: json.RawMessage(*payload),
+		}
+
+		if *metadata != "" {
+			event.Metadata = json.RawMessage(*metadata)
+		}
+	}
+
+	if !json.Valid(event.Payload) {
+		return errors.New("payload is not valid JSON")
+	}
+
+	if len(event.Metadata) > 0 && !json.Valid(event.Metadata) {
+		return errors.New("metadata is not valid JSON")
+	}
+
+	if event.ID == "" {
+		event.ID = events.RandomID()
+	}
+
+	if event.FlowID == "" {
+		event.FlowID = events.RandomID()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	response, err := events.NewClient(*url).Send(ctx, event)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(stdout, response)
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
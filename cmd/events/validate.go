@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+)
+
+// envelopeFields are the properties of the envelope schema in the README
+var envelopeFields = map[string]string{
+	"name":     "string",
+	"version":  "integer",
+	"id":       "string",
+	"flowId":   "string",
+	"payload":  "object",
+	"metadata": "object",
+}
+
+var envelopeRequired = []string{"name", "version", "id", "flowId", "payload"}
+
+func validate(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	file := flags.String("file", "-", "event file, a single JSON event or JSONL (- for stdin)")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	r, closer, err := open(*file, stdin)
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	invalid, total := 0, 0
+
+	report := func(location string, problems []string) {
+		total++
+		if len(problems) == 0 {
+			return
+		}
+
+		invalid++
+		for _, problem := range problems {
+			fmt.Fprintf(stdout, "%s: %s\n", location, problem)
+		}
+	}
+
+	if json.Valid(data) {
+		report(*file, validateEnvelope(data))
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+		line := 0
+		for scanner.Scan() {
+			line++
+
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+
+			report(fmt.Sprintf("%s:%d", *file, line), validateEnvelope(scanner.Bytes()))
+		}
+
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d events are invalid", invalid, total)
+	}
+
+	fmt.Fprintf(stdout, "%d valid events\n", total)
+
+	return nil
+}
+
+// validateEnvelope checks an event against the envelope schema
+func validateEnvelope(data []byte) []string {
+	envelope := map[string]interface{}{}
+
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return []string{fmt.Sprintf("invalid JSON: %s", err)}
+	}
+
+	problems := []string{}
+
+	for _, field := range envelopeRequired {
+		if _, ok := envelope[field]; !ok {
+			problems = append(problems, fmt.Sprintf("%q is required", field))
+		}
+	}
+
+	names := make([]string, 0, len(envelope))
+	for name := range envelope {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		expected, ok := envelopeFields[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%q is not an envelope field", name))
+			continue
+		}
+
+		value := envelope[name]
+
+		switch expected {
+		case "string":
+			if _, ok := value.(string); !ok {
+				problems = append(problems, fmt.Sprintf("%q must be a string", name))
+			}
+		case "integer":
+			n, ok := value.(float64)
+			if !ok || n != float64(int64(n)) {
+				problems = append(problems, fmt.Sprintf("%q must be an integer", name))
+			} else if n < 1 {
+				problems = append(problems, fmt.Sprintf("%q must be at least 1", name))
+			}
+		case "object":
+			if _, ok := value.(map[string]interface{}); !ok {
+				problems = append(problems, fmt.Sprintf("%q must be an object", name))
+			}
+		}
+	}
+
+	return problems
+}
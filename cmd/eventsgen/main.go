@@ -0,0 +1,104 @@
+// Command eventsgen generates typed clients from the schema document served
+// by events.Mux.ServeSchema.
+//
+//	eventsgen -schema http://localhost:8080/schema -lang go -package client -o client/events.go
+//	eventsgen -schema schema.json -lang ts -o src/events.ts
+//
+// Packages that prefer generating from their registration function can call
+// codegen.Go and codegen.TypeScript with Mux.Schema() from a go:generate
+// program instead.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	events "github.com/GuiaBolso/Go-Events"
+	"github.com/GuiaBolso/Go-Events/codegen"
+)
+
+func main() {
+	schema := flag.String("schema", "", "schema document file or http(s) URL (- for stdin)")
+	lang := flag.String("lang", "go", "output language: go or ts")
+	pkg := flag.String("package", "client", "package name of the generated Go code")
+	output := flag.String("o", "", "output file (default stdout)")
+	flag.Parse()
+
+	if *schema == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*schema, *lang, *pkg, *output); err != nil {
+		fmt.Fprintln(os.Stderr, "eventsgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(schema, lang, pkg, output string) error {
+	document, err := readSchema(schema)
+	if err != nil {
+		return err
+	}
+
+	var code []byte
+
+	switch lang {
+	case "go":
+		code, err = codegen.Go(document, pkg)
+	case "ts", "typescript":
+		code, err = codegen.TypeScript(document)
+	default:
+		err = fmt.Errorf("unknown language %q", lang)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		_, err = os.Stdout.Write(code)
+		return err
+	}
+
+	return ioutil.WriteFile(output, code, 0644)
+}
+
+func readSchema(source string) (events.SchemaDocument, error) {
+	document := events.SchemaDocument{}
+
+	var r io.Reader
+
+	switch {
+	case source == "-":
+		r = os.Stdin
+	case strings.HasPrefix(source, "http://"), strings.HasPrefix(source, "https://"):
+		resp, err := http.Get(source)
+		if err != nil {
+			return document, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return document, fmt.Errorf("%s: %s", source, resp.Status)
+		}
+		r = resp.Body
+	default:
+		f, err := os.Open(source)
+		if err != nil {
+			return document, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	err := json.NewDecoder(r).Decode(&document)
+
+	return document, err
+}
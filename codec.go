@@ -0,0 +1,320 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec - A wire format of events. Payload and Metadata are transcoded from
+// and to JSON, so handlers keep receiving JSON whatever the wire format.
+type Codec interface {
+	ContentType() string
+	Marshal(Event) ([]byte, error)
+	Unmarshal([]byte, *Event) error
+}
+
+// wireEvent - The envelope of binary codecs, with the payload and metadata
+// as native values of the format
+type wireEvent struct {
+	Name     string      `msgpack:"name" cbor:"name"`
+	Version  int         `msgpack:"version" cbor:"version"`
+	ID       string      `msgpack:"id" cbor:"id"`
+	FlowID   string      `msgpack:"flowId,omitempty" cbor:"flowId,omitempty"`
+	Payload  interface{} `msgpack:"payload" cbor:"payload"`
+	Metadata interface{} `msgpack:"metadata,omitempty" cbor:"metadata,omitempty"`
+}
+
+func toWire(event Event) (wireEvent, error) {
+	payload, err := fromJSON(event.Payload)
+	if err != nil {
+		return wireEvent{}, fmt.Errorf("payload: %s", err)
+	}
+
+	metadata, err := fromJSON(event.Metadata)
+	if err != nil {
+		return wireEvent{}, fmt.Errorf("metadata: %s", err)
+	}
+
+	return wireEvent{
+		Name:     event.Name,
+		Version:  event.Version,
+		ID:       event.ID,
+		FlowID:   event.FlowID,
+		Payload:  payload,
+		Metadata: metadata,
+	}, nil
+}
+
+func (w wireEvent) event() (Event, error) {
+	event := Event{
+		Name:    w.Name,
+		Version: w.Version,
+		ID:      w.ID,
+		FlowID:  w.FlowID,
+	}
+
+	var err error
+
+	// Events without payload keep it empty
+	if w.Payload != nil {
+		if event.Payload, err = json.Marshal(w.Payload); err != nil {
+			return event, fmt.Errorf("payload: %s", err)
+		}
+	}
+
+	if w.Metadata != nil {
+		if event.Metadata, err = json.Marshal(w.Metadata); err != nil {
+			return event, fmt.Errorf("metadata: %s", err)
+		}
+	}
+
+	return event, nil
+}
+
+// fromJSON decodes JSON keeping integers as int64, or uint64 above the
+// int64 range
+func fromJSON(raw json.RawMessage) (interface{}, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	value, err := decodeJSON(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonNumbers(value)
+}
+
+// jsonNumbers replaces the json.Numbers of value. Integers beyond uint64
+// are errors, as a float64 would change them.
+func jsonNumbers(value interface{}) (interface{}, error) {
+	var err error
+
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+
+		if !strings.ContainsAny(v.String(), ".eE") {
+			u, err := strconv.ParseUint(v.String(), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("integer %s out of range", v)
+			}
+			return u, nil
+		}
+
+		return v.Float64()
+	case []interface{}:
+		for i := range v {
+			if v[i], err = jsonNumbers(v[i]); err != nil {
+				return nil, err
+			}
+		}
+	case map[string]interface{}:
+		for key := range v {
+			if v[key], err = jsonNumbers(v[key]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return value, nil
+}
+
+// MsgPackCodec - The MessagePack Codec
+type MsgPackCodec struct{}
+
+// ContentType implements Codec
+func (MsgPackCodec) ContentType() string {
+	return "application/msgpack"
+}
+
+// Marshal implements Codec
+func (MsgPackCodec) Marshal(event Event) ([]byte, error) {
+	wire, err := toWire(event)
+	if err != nil {
+		return nil, err
+	}
+
+	return msgpack.Marshal(wire)
+}
+
+// Unmarshal implements Codec
+func (MsgPackCodec) Unmarshal(data []byte, event *Event) error {
+	wire := wireEvent{}
+
+	if err := msgpack.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+
+	decoded, err := wire.event()
+	*event = decoded
+
+	return err
+}
+
+// unmarshalStrict implements strictCodec
+func (MsgPackCodec) unmarshalStrict(data []byte, event *Event, options HTTPOptions) error {
+	reader := bytes.NewReader(data)
+
+	decoder := msgpack.NewDecoder(reader)
+	decoder.DisallowUnknownFields(options.DisallowUnknownFields)
+
+	wire := wireEvent{}
+
+	if err := decoder.Decode(&wire); err != nil {
+		// msgpack has no type for the error of DisallowUnknownFields
+		if options.DisallowUnknownFields && strings.HasPrefix(err.Error(), "msgpack: unknown field") {
+			return requestError{code: ErrorCodeUnknownField, message: err.Error()}
+		}
+
+		return err
+	}
+
+	if options.RejectTrailingData && reader.Len() > 0 {
+		return errTrailingData
+	}
+
+	decoded, err := wire.event()
+	*event = decoded
+
+	return err
+}
+
+var cborDecMode, _ = cbor.DecOptions{
+	DefaultMapType: reflect.TypeOf(map[string]interface{}{}),
+}.DecMode()
+
+// cborStrictDecMode rejects the fields unknown to wireEvent
+var cborStrictDecMode, _ = cbor.DecOptions{
+	DefaultMapType:    reflect.TypeOf(map[string]interface{}{}),
+	ExtraReturnErrors: cbor.ExtraDecErrorUnknownField,
+}.DecMode()
+
+// CBORCodec - The CBOR Codec
+type CBORCodec struct{}
+
+// ContentType implements Codec
+func (CBORCodec) ContentType() string {
+	return "application/cbor"
+}
+
+// Marshal implements Codec
+func (CBORCodec) Marshal(event Event) ([]byte, error) {
+	wire, err := toWire(event)
+	if err != nil {
+		return nil, err
+	}
+
+	return cbor.Marshal(wire)
+}
+
+// Unmarshal implements Codec
+func (CBORCodec) Unmarshal(data []byte, event *Event) error {
+	wire := wireEvent{}
+
+	if err := cborDecMode.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+
+	decoded, err := wire.event()
+	*event = decoded
+
+	return err
+}
+
+// unmarshalStrict implements strictCodec
+func (CBORCodec) unmarshalStrict(data []byte, event *Event, options HTTPOptions) error {
+	mode := cborDecMode
+	if options.DisallowUnknownFields {
+		mode = cborStrictDecMode
+	}
+
+	wire := wireEvent{}
+
+	rest, err := mode.UnmarshalFirst(data, &wire)
+	if err != nil {
+		var unknown *cbor.UnknownFieldError
+		if errors.As(err, &unknown) {
+			return requestError{code: ErrorCodeUnknownField, message: err.Error()}
+		}
+
+		return err
+	}
+
+	if options.RejectTrailingData && len(rest) > 0 {
+		return errTrailingData
+	}
+
+	decoded, err := wire.event()
+	*event = decoded
+
+	return err
+}
+
+// strictCodec - A Codec applying the DisallowUnknownFields and
+// RejectTrailingData options of HTTPOptions, the coded errors of the
+// options are requestErrors
+type strictCodec interface {
+	unmarshalStrict(data []byte, event *Event, options HTTPOptions) error
+}
+
+func defaultCodecs() map[string]Codec {
+	codecs := map[string]Codec{}
+
+	for _, codec := range []Codec{MsgPackCodec{}, CBORCodec{}} {
+		codecs[mediaType(codec.ContentType())] = codec
+	}
+
+	return codecs
+}
+
+// AddCodec lets ServeHTTP read and write events in the format of codec,
+// selected by the Content-Type and Accept headers. MsgPackCodec and
+// CBORCodec are added by default, JSON is used for other content types.
+// The DisallowUnknownFields and RejectTrailingData options of HTTPOptions
+// apply to JSON and the default codecs, other codecs decode leniently.
+func (m *Mux) AddCodec(codec Codec) {
+	m.codecs[mediaType(codec.ContentType())] = codec
+}
+
+// requestCodec returns the codec of the request body, nil for JSON
+func (m *Mux) requestCodec(contentType string) Codec {
+	return m.codecs[mediaType(contentType)]
+}
+
+// responseCodec returns the first codec of the Accept header, or the codec
+// of the request body. It returns nil for JSON.
+func (m *Mux) responseCodec(accept, contentType string) Codec {
+	for _, part := range strings.Split(accept, ",") {
+		media := mediaType(part)
+
+		if media == "application/json" {
+			return nil
+		}
+		if codec, ok := m.codecs[media]; ok {
+			return codec
+		}
+	}
+
+	return m.requestCodec(contentType)
+}
+
+func mediaType(contentType string) string {
+	media, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return media
+}
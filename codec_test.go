@@ -0,0 +1,261 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func Test_Codecs(t *testing.T) {
+	event := Event{
+		Name:     "some event",
+		Version:  2,
+		ID:       "id",
+		FlowID:   "flow",
+		Payload:  json.RawMessage(`{"count":3,"price":9.5,"tags":["a","b"],"nested":{"ok":true,"none":null}}`),
+		Metadata: json.RawMessage(`{"origin":"mobile"}`),
+	}
+
+	for _, codec := range []Codec{MsgPackCodec{}, CBORCodec{}} {
+		data, err := codec.Marshal(event)
+		if err != nil {
+			t.Fatalf("%s: Error not expected: \"%s\"", codec.ContentType(), err)
+		}
+
+		jsonData, _ := json.Marshal(event)
+		if len(data) >= len(jsonData) {
+			t.Errorf("%s: len(data) == %d, wants less than %d", codec.ContentType(), len(data), len(jsonData))
+		}
+
+		decoded := Event{}
+		if err := codec.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("%s: Error not expected: \"%s\"", codec.ContentType(), err)
+		}
+
+		if decoded.Name != event.Name || decoded.Version != event.Version || decoded.ID != event.ID || decoded.FlowID != event.FlowID {
+			t.Errorf("%s: decoded == %+v, wants: %+v", codec.ContentType(), decoded, event)
+		}
+
+		if !jsonEqual(decoded.Payload, event.Payload) {
+			t.Errorf("%s: decoded.Payload == %s, wants: %s", codec.ContentType(), decoded.Payload, event.Payload)
+		}
+
+		if !jsonEqual(decoded.Metadata, event.Metadata) {
+			t.Errorf("%s: decoded.Metadata == %s, wants: %s", codec.ContentType(), decoded.Metadata, event.Metadata)
+		}
+
+		if err := codec.Unmarshal([]byte("garbage"), &decoded); err == nil {
+			t.Errorf("%s: Error expected decoding garbage", codec.ContentType())
+		}
+	}
+}
+
+func Test_Codecs_numbers(t *testing.T) {
+	for _, codec := range []Codec{MsgPackCodec{}, CBORCodec{}} {
+		payload := json.RawMessage(`[9223372036854775807,-9223372036854775808,18446744073709551615,1.5]`)
+
+		data, err := codec.Marshal(Event{Name: "numbers", Version: 1, Payload: payload})
+		if err != nil {
+			t.Fatalf("%s: Error not expected: \"%s\"", codec.ContentType(), err)
+		}
+
+		decoded := Event{}
+		codec.Unmarshal(data, &decoded)
+
+		if string(decoded.Payload) != string(payload) {
+			t.Errorf("%s: decoded.Payload == %s, wants: %s", codec.ContentType(), decoded.Payload, payload)
+		}
+
+		if _, err := codec.Marshal(Event{Name: "numbers", Version: 1, Payload: json.RawMessage(`[18446744073709551616]`)}); err == nil {
+			t.Errorf("%s: Error expected with integers beyond uint64", codec.ContentType())
+		}
+
+		data, _ = codec.Marshal(Event{Name: "empty", Version: 1})
+		decoded = Event{}
+		codec.Unmarshal(data, &decoded)
+
+		if len(decoded.Payload) != 0 {
+			t.Errorf("%s: decoded.Payload == %s, wants it empty", codec.ContentType(), decoded.Payload)
+		}
+	}
+}
+
+func jsonEqual(a, b json.RawMessage) bool {
+	var va, vb interface{}
+	json.Unmarshal(a, &va)
+	json.Unmarshal(b, &vb)
+
+	ja, _ := json.Marshal(va)
+	jb, _ := json.Marshal(vb)
+
+	return bytes.Equal(ja, jb)
+}
+
+func Test_ServeHTTP_Codec(t *testing.T) {
+	mux := NewMux()
+	mux.Add("echo", 1, HandlerFunc(func(_ context.Context, event Event) (Event, error) {
+		payload := map[string]int{}
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			return NewError(event.FlowID, err.Error()), err
+		}
+
+		payload["count"]++
+		return NewResponse(event, payload)
+	}))
+
+	codec := MsgPackCodec{}
+	body, _ := codec.Marshal(Event{Name: "echo", Version: 1, Payload: json.RawMessage(`{"count":1}`)})
+
+	serve := func(contentType, accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/events/", bytes.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		r.Header.Set("Accept", accept)
+
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve("application/msgpack", "")
+	if w.Header().Get("Content-Type") != "application/msgpack" {
+		t.Fatalf("Content-Type == %s, wants: %s", w.Header().Get("Content-Type"), "application/msgpack")
+	}
+
+	response := Event{}
+	if err := codec.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	if response.Name != "echo:response" || string(response.Payload) != `{"count":2}` {
+		t.Errorf("response == %s %s, wants: echo:response {\"count\":2}", response.Name, response.Payload)
+	}
+
+	w = serve("application/msgpack", "application/json")
+	if w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Content-Type == %s, wants: %s", w.Header().Get("Content-Type"), "application/json")
+	}
+
+	w = serve("application/msgpack", "application/cbor")
+	if err := (CBORCodec{}).Unmarshal(w.Body.Bytes(), &response); err != nil || response.Name != "echo:response" {
+		t.Errorf("response == %s, err == %v, wants a CBOR echo:response", response.Name, err)
+	}
+
+	// Errors use the codec of the request
+	w = serve("application/cbor", "")
+	if err := (CBORCodec{}).Unmarshal(w.Body.Bytes(), &response); err != nil || response.Name != "error" {
+		t.Errorf("response == %s, err == %v, wants a CBOR error", response.Name, err)
+	}
+}
+
+func Test_ServeHTTP_Codec_Options(t *testing.T) {
+	mux := NewMux()
+	mux.Add("echo", 1, HandlerFunc(mockHandlerFunc))
+
+	serve := func(codec Codec, body []byte) errorPayload {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/events/", bytes.NewReader(body))
+		r.Header.Set("Content-Type", codec.ContentType())
+		r.Header.Set("Accept", "application/json")
+
+		mux.ServeHTTP(w, r)
+
+		response := Event{}
+		json.NewDecoder(w.Body).Decode(&response)
+
+		payload := errorPayload{}
+		if response.Name == "error" {
+			json.Unmarshal(response.Payload, &payload)
+		}
+
+		return payload
+	}
+
+	fields := map[string]interface{}{"name": "echo", "version": 1, "payload": "x", "extra": true}
+
+	bodies := map[Codec][]byte{}
+	bodies[MsgPackCodec{}], _ = msgpack.Marshal(fields)
+	bodies[CBORCodec{}], _ = cbor.Marshal(fields)
+
+	for codec, unknown := range bodies {
+		valid, _ := codec.Marshal(Event{Name: "echo", Version: 1, Payload: json.RawMessage(`"x"`)})
+		trailing := append(append([]byte{}, valid...), valid...)
+
+		// Lenient by default
+		mux.SetHTTPOptions(DefaultHTTPOptions)
+
+		for _, body := range [][]byte{unknown, trailing} {
+			if payload := serve(codec, body); payload.Code != 0 {
+				t.Errorf("%s: payload.Code == %d (%s), wants: %d", codec.ContentType(), payload.Code, payload.Message, 0)
+			}
+		}
+
+		options := DefaultHTTPOptions
+		options.DisallowUnknownFields = true
+		options.RejectTrailingData = true
+		mux.SetHTTPOptions(options)
+
+		if payload := serve(codec, unknown); payload.Code != ErrorCodeUnknownField {
+			t.Errorf("%s: payload.Code == %d (%s), wants: %d", codec.ContentType(), payload.Code, payload.Message, ErrorCodeUnknownField)
+		}
+
+		if payload := serve(codec, trailing); payload.Code != ErrorCodeTrailingData {
+			t.Errorf("%s: payload.Code == %d (%s), wants: %d", codec.ContentType(), payload.Code, payload.Message, ErrorCodeTrailingData)
+		}
+
+		if payload := serve(codec, valid); payload.Code != 0 {
+			t.Errorf("%s: payload.Code == %d (%s), wants: %d", codec.ContentType(), payload.Code, payload.Message, 0)
+		}
+	}
+}
+
+func Test_ServeHTTP_Codec_encoding_error(t *testing.T) {
+	mux := NewMux()
+	mux.Add("big", 1, HandlerFunc(func(_ context.Context, event Event) (Event, error) {
+		return Event{Name: "big:response", Version: 1, FlowID: event.FlowID, Payload: json.RawMessage(`[18446744073709551616]`)}, nil
+	}))
+
+	body, _ := (MsgPackCodec{}).Marshal(Event{Name: "big", Version: 1, FlowID: "flow"})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/events/", bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/msgpack")
+
+	mux.ServeHTTP(w, r)
+
+	if w.Code != http.StatusInternalServerError || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("w.Code == %d, Content-Type == %s, wants: %d, application/json", w.Code, w.Header().Get("Content-Type"), http.StatusInternalServerError)
+	}
+
+	response := Event{}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil || response.Name != "error" || response.FlowID != "flow" {
+		t.Errorf("response == %+v, err == %v, wants a JSON error event", response, err)
+	}
+}
+
+func Test_Client_Codec(t *testing.T) {
+	mux := NewMux()
+	mux.Add("echo", 1, HandlerFunc(mockHandlerFunc))
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	for _, codec := range []Codec{MsgPackCodec{}, CBORCodec{}} {
+		client := NewClient(server.URL)
+		client.Codec = codec
+
+		response, err := client.Send(context.Background(), Event{Name: "echo", Version: 1, Payload: json.RawMessage(`[1,"two"]`)})
+		if err != nil {
+			t.Fatalf("%s: Error not expected: \"%s\"", codec.ContentType(), err)
+		}
+
+		if response.Name != "echo:response" || string(response.Payload) != `[1,"two"]` {
+			t.Errorf("%s: response == %s %s", codec.ContentType(), response.Name, response.Payload)
+		}
+	}
+}
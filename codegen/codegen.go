@@ -0,0 +1,311 @@
+// Package codegen generates typed clients from an events.SchemaDocument
+package codegen
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	events "github.com/GuiaBolso/Go-Events"
+)
+
+// kinds of typeRef
+const (
+	kindAny     = "any"
+	kindString  = "string"
+	kindInteger = "integer"
+	kindNumber  = "number"
+	kindBoolean = "boolean"
+	kindArray   = "array"
+	kindMap     = "map"
+	kindStruct  = "struct"
+)
+
+type typeRef struct {
+	Kind string
+	Name string   // kindStruct
+	Elem *typeRef // kindArray and kindMap
+}
+
+type fieldDef struct {
+	JSONName    string
+	Name        string
+	Description string
+	Type        typeRef
+	Required    bool
+}
+
+type structDef struct {
+	Name        string
+	Description string
+	Fields      []fieldDef
+}
+
+type eventDef struct {
+	Name    string
+	Version int
+	Method  string
+	Doc     string
+	Input   typeRef
+	Output  typeRef
+}
+
+// model is the language independent representation of a SchemaDocument
+type model struct {
+	Events  []eventDef
+	Structs []structDef
+
+	structs   map[string]*structDef
+	canonical map[string]string
+}
+
+type schemaScope struct {
+	prefix      string
+	definitions map[string]map[string]interface{}
+	names       map[string]string
+}
+
+func newModel(document events.SchemaDocument) (*model, error) {
+	m := &model{
+		structs:   map[string]*structDef{},
+		canonical: map[string]string{},
+	}
+
+	methods := map[string]bool{}
+
+	for _, eventSchema := range document.Events {
+		method := fmt.Sprintf("%sV%d", identifier(eventSchema.Name), eventSchema.Version)
+		if methods[method] {
+			return nil, fmt.Errorf("event %q version %d generates a duplicated method %s", eventSchema.Name, eventSchema.Version, method)
+		}
+		methods[method] = true
+
+		input, err := m.eventType(method, "Input", eventSchema.Input)
+		if err != nil {
+			return nil, fmt.Errorf("event %q version %d: input: %s", eventSchema.Name, eventSchema.Version, err)
+		}
+
+		output, err := m.eventType(method, "Output", eventSchema.Output)
+		if err != nil {
+			return nil, fmt.Errorf("event %q version %d: output: %s", eventSchema.Name, eventSchema.Version, err)
+		}
+
+		m.Events = append(m.Events, eventDef{
+			Name:    eventSchema.Name,
+			Version: eventSchema.Version,
+			Method:  method,
+			Doc:     eventSchema.Doc,
+			Input:   input,
+			Output:  output,
+		})
+	}
+
+	names := make([]string, 0, len(m.structs))
+	for name := range m.structs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		m.Structs = append(m.Structs, *m.structs[name])
+	}
+
+	return m, nil
+}
+
+func (m *model) eventType(method, suffix string, raw json.RawMessage) (typeRef, error) {
+	if len(raw) == 0 {
+		return typeRef{Kind: kindAny}, nil
+	}
+
+	root := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &root); err != nil {
+		return typeRef{}, err
+	}
+
+	scope := &schemaScope{
+		prefix:      method,
+		definitions: map[string]map[string]interface{}{},
+		names:       map[string]string{},
+	}
+
+	for _, field := range []string{"definitions", "$defs"} {
+		defs, _ := root[field].(map[string]interface{})
+		for name, def := range defs {
+			if schema, ok := def.(map[string]interface{}); ok {
+				scope.definitions[name] = schema
+			}
+		}
+	}
+
+	return m.typeOf(scope, method+suffix, root), nil
+}
+
+// definition returns the type name of a definition, reusing types with the
+// same name and schema and prefixing the conflicting ones with the event
+func (m *model) definition(scope *schemaScope, name string) typeRef {
+	if typeName, ok := scope.names[name]; ok {
+		return typeRef{Kind: kindStruct, Name: typeName}
+	}
+
+	schema := scope.definitions[name]
+	canonical, _ := json.Marshal(schema)
+
+	typeName := identifier(name)
+	if existing, ok := m.canonical[typeName]; ok && existing != string(canonical) {
+		typeName = scope.prefix + typeName
+	}
+
+	scope.names[name] = typeName
+
+	if _, ok := m.canonical[typeName]; ok {
+		return typeRef{Kind: kindStruct, Name: typeName}
+	}
+
+	m.canonical[typeName] = string(canonical)
+
+	ref := m.typeOf(scope, typeName, schema)
+	if ref.Kind != kindStruct || ref.Name != typeName {
+		// Definitions that are not objects are inlined
+		delete(m.canonical, typeName)
+		delete(scope.names, name)
+	}
+
+	return ref
+}
+
+func (m *model) typeOf(scope *schemaScope, name string, schema map[string]interface{}) typeRef {
+	if ref, ok := schema["$ref"].(string); ok {
+		definition := ref[strings.LastIndex(ref, "/")+1:]
+
+		if _, ok := scope.definitions[definition]; ok {
+			return m.definition(scope, definition)
+		}
+
+		return typeRef{Kind: kindAny}
+	}
+
+	switch schemaType(schema) {
+	case "string":
+		return typeRef{Kind: kindString}
+	case "integer":
+		return typeRef{Kind: kindInteger}
+	case "number":
+		return typeRef{Kind: kindNumber}
+	case "boolean":
+		return typeRef{Kind: kindBoolean}
+	case "array":
+		items, _ := schema["items"].(map[string]interface{})
+		elem := m.typeOf(scope, name+"Item", items)
+		return typeRef{Kind: kindArray, Elem: &elem}
+	case "object":
+		properties, _ := schema["properties"].(map[string]interface{})
+
+		if len(properties) == 0 {
+			elem := typeRef{Kind: kindAny}
+
+			// Map values are additionalProperties, or the ".*" pattern of
+			// the older alecthomas/jsonschema releases
+			values, ok := schema["additionalProperties"].(map[string]interface{})
+			if patterns, isMap := schema["patternProperties"].(map[string]interface{}); !ok && isMap {
+				values, ok = patterns[".*"].(map[string]interface{})
+			}
+
+			if ok {
+				elem = m.typeOf(scope, name+"Value", values)
+			}
+			return typeRef{Kind: kindMap, Elem: &elem}
+		}
+
+		return m.structOf(scope, name, schema, properties)
+	}
+
+	return typeRef{Kind: kindAny}
+}
+
+func (m *model) structOf(scope *schemaScope, name string, schema, properties map[string]interface{}) typeRef {
+	def := &structDef{Name: name}
+	def.Description, _ = schema["description"].(string)
+
+	m.structs[name] = def
+
+	required := map[string]bool{}
+	if list, ok := schema["required"].([]interface{}); ok {
+		for _, item := range list {
+			required[fmt.Sprint(item)] = true
+		}
+	}
+
+	jsonNames := make([]string, 0, len(properties))
+	for jsonName := range properties {
+		jsonNames = append(jsonNames, jsonName)
+	}
+	sort.Strings(jsonNames)
+
+	for _, jsonName := range jsonNames {
+		property, _ := properties[jsonName].(map[string]interface{})
+		fieldName := identifier(jsonName)
+
+		field := fieldDef{
+			JSONName: jsonName,
+			Name:     fieldName,
+			Type:     m.typeOf(scope, name+fieldName, property),
+			Required: required[jsonName],
+		}
+		field.Description, _ = property["description"].(string)
+
+		def.Fields = append(def.Fields, field)
+	}
+
+	return typeRef{Kind: kindStruct, Name: name}
+}
+
+func schemaType(schema map[string]interface{}) string {
+	switch t := schema["type"].(type) {
+	case string:
+		return t
+	case []interface{}:
+		for _, item := range t {
+			if s := fmt.Sprint(item); s != "null" {
+				return s
+			}
+		}
+	}
+
+	if _, ok := schema["properties"]; ok {
+		return "object"
+	}
+
+	return ""
+}
+
+// identifier converts names like "user.created" or "some_field" into
+// exported identifiers like "UserCreated" and "SomeField"
+func identifier(name string) string {
+	var b strings.Builder
+
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+
+		b.WriteRune(r)
+	}
+
+	id := b.String()
+
+	if id == "" || !unicode.IsLetter([]rune(id)[0]) {
+		id = "Event" + id
+	}
+
+	return id
+}
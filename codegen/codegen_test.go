@@ -0,0 +1,164 @@
+package codegen
+
+import (
+	"context"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	events "github.com/GuiaBolso/Go-Events"
+)
+
+type mockAddress struct {
+	Street string `json:"street"`
+	Number int    `json:"number,omitempty"`
+}
+
+type mockUserInput struct {
+	Name      string            `json:"name"`
+	Age       int64             `json:"age"`
+	Score     float64           `json:"score,omitempty"`
+	Active    bool              `json:"active"`
+	Tags      []string          `json:"tags,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Addresses []mockAddress     `json:"addresses"`
+}
+
+type mockUserOutput struct {
+	ID string `json:"id"`
+}
+
+type mockUserEvent struct{}
+
+func (h *mockUserEvent) Serve(context.Context, events.Event) (events.Event, error) {
+	return events.Event{}, nil
+}
+
+func (h *mockUserEvent) Example() (interface{}, interface{}) {
+	return mockUserInput{Name: "John"}, mockUserOutput{ID: "42"}
+}
+
+func (h *mockUserEvent) Input() interface{}  { return mockUserInput{} }
+func (h *mockUserEvent) Output() interface{} { return mockUserOutput{} }
+func (h *mockUserEvent) Doc() string         { return "Creates a user" }
+
+func mockDocument() events.SchemaDocument {
+	mux := events.NewMux()
+
+	mux.Add("user.created", 1, &mockUserEvent{})
+	mux.Add("user.created", 2, &mockUserEvent{})
+	mux.Add("ping", 1, events.HandlerFunc(func(_ context.Context, e events.Event) (events.Event, error) {
+		return e, nil
+	}))
+
+	return mux.Schema()
+}
+
+func Test_Go(t *testing.T) {
+	code, err := Go(mockDocument(), "client")
+
+	if err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+
+	if _, err := parser.ParseFile(token.NewFileSet(), "client.go", code, 0); err != nil {
+		t.Fatalf("Generated code does not parse: %s\n%s", err, code)
+	}
+
+	src := strings.Join(strings.Fields(string(code)), " ")
+
+	expected := []string{
+		"package client",
+		"func (c *Client) UserCreatedV1(ctx context.Context, flowID string, input MockUserInput) (MockUserOutput, error)",
+		"func (c *Client) UserCreatedV2(ctx context.Context, flowID string, input MockUserInput) (MockUserOutput, error)",
+		"func (c *Client) PingV1(ctx context.Context, flowID string, input json.RawMessage) (json.RawMessage, error)",
+		"// Creates a user",
+		"type MockAddress struct",
+		"Addresses []MockAddress `json:\"addresses\"`",
+		"Labels map[string]string `json:\"labels,omitempty\"`",
+		"Age int64",
+		"Score float64",
+	}
+
+	for _, s := range expected {
+		if !strings.Contains(src, s) {
+			t.Errorf("Could not find %q in the generated code:\n%s", s, code)
+		}
+	}
+
+	if strings.Count(src, "type MockUserInput struct") != 1 {
+		t.Error("Types shared by several events must be generated once")
+	}
+}
+
+func Test_TypeScript(t *testing.T) {
+	code, err := TypeScript(mockDocument())
+
+	if err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+
+	src := string(code)
+
+	expected := []string{
+		"export interface MockUserInput {",
+		`"addresses": MockAddress[];`,
+		`"score"?: number;`,
+		`"labels"?: { [key: string]: string };`,
+		`export const UserCreatedV1 = { name: "user.created", version: 1 } as const;`,
+		"export type UserCreatedV1Request = Event<MockUserInput>;",
+		"export type PingV1Response = Event<unknown>;",
+	}
+
+	for _, s := range expected {
+		if !strings.Contains(src, s) {
+			t.Errorf("Could not find %q in the generated code:\n%s", s, src)
+		}
+	}
+}
+
+func Test_duplicated_method(t *testing.T) {
+	document := events.SchemaDocument{Events: []events.EventSchema{
+		{Name: "user.created", Version: 1},
+		{Name: "user_created", Version: 1},
+	}}
+
+	if _, err := Go(document, "client"); err == nil {
+		t.Error("Expecting an error for events generating the same method")
+	}
+}
+
+func Test_Go_patternProperties(t *testing.T) {
+	document := events.SchemaDocument{Events: []events.EventSchema{{
+		Name:    "tag",
+		Version: 1,
+		Input:   []byte(`{"$ref":"#/definitions/tags","definitions":{"tags":{"properties":{"labels":{"patternProperties":{".*":{"type":"string"}},"type":"object"}},"type":"object"}}}`),
+	}}}
+
+	code, err := Go(document, "client")
+	if err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	if !strings.Contains(string(code), "map[string]string") {
+		t.Errorf("Could not find %q in the generated code:\n%s", "map[string]string", code)
+	}
+}
+
+func Test_identifier(t *testing.T) {
+	cases := map[string]string{
+		"user.created": "UserCreated",
+		"some event":   "SomeEvent",
+		"Mock-0":       "Mock0",
+		"flowId":       "FlowId",
+		"42":           "Event42",
+		"":             "Event",
+	}
+
+	for name, expected := range cases {
+		if id := identifier(name); id != expected {
+			t.Errorf(`identifier("%s") == "%s", wants: "%s"`, name, id, expected)
+		}
+	}
+}
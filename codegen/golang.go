@@ -0,0 +1,159 @@
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+	"text/template"
+
+	events "github.com/GuiaBolso/Go-Events"
+)
+
+// Go generates a typed Go client for the events of document. Every event
+// and version becomes a method of Client receiving the input type and
+// returning the output type.
+func Go(document events.SchemaDocument, pkg string) ([]byte, error) {
+	m, err := newModel(document)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+
+	err = goTemplate.Execute(&b, struct {
+		Package string
+		*model
+	}{pkg, m})
+	if err != nil {
+		return nil, err
+	}
+
+	return format.Source(b.Bytes())
+}
+
+func goType(t typeRef) string {
+	switch t.Kind {
+	case kindString:
+		return "string"
+	case kindInteger:
+		return "int64"
+	case kindNumber:
+		return "float64"
+	case kindBoolean:
+		return "bool"
+	case kindArray:
+		return "[]" + goType(*t.Elem)
+	case kindMap:
+		return "map[string]" + goType(*t.Elem)
+	case kindStruct:
+		return t.Name
+	}
+	return "json.RawMessage"
+}
+
+func goTag(f fieldDef) string {
+	if f.Required {
+		return fmt.Sprintf("`json:\"%s\"`", f.JSONName)
+	}
+	return fmt.Sprintf("`json:\"%s,omitempty\"`", f.JSONName)
+}
+
+func goComment(indent, text string) string {
+	if text == "" {
+		return ""
+	}
+
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for i, line := range lines {
+		lines[i] = indent + "// " + strings.TrimSpace(line)
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+var goTemplate = template.Must(template.New("go").Funcs(template.FuncMap{
+	"type":    goType,
+	"tag":     goTag,
+	"comment": goComment,
+}).Parse(`// Code generated by eventsgen. DO NOT EDIT.
+
+package {{ .Package }}
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	events "github.com/GuiaBolso/Go-Events"
+)
+
+// Sender delivers an event and returns its response event
+type Sender interface {
+	Send(context.Context, events.Event) (events.Event, error)
+}
+
+// Error is returned when the response is an "error" event
+type Error struct {
+	Event   events.Event
+	Message string ` + "`json:\"message\"`" + `
+	Code    int    ` + "`json:\"code,omitempty\"`" + `
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Event.Name, e.Message)
+}
+
+// Client is a typed client for the documented events
+type Client struct {
+	Sender Sender
+}
+
+// NewClient returns a Client sending events through sender
+func NewClient(sender Sender) *Client {
+	return &Client{Sender: sender}
+}
+
+func (c *Client) call(ctx context.Context, name string, version int, flowID string, input, output interface{}) error {
+	payload, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+
+	if flowID == "" {
+		flowID = events.RandomID()
+	}
+
+	response, err := c.Sender.Send(ctx, events.Event{
+		Name:    name,
+		Version: version,
+		ID:      events.RandomID(),
+		FlowID:  flowID,
+		Payload: payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	if response.Name == "error" {
+		eventErr := &Error{Event: response}
+		json.Unmarshal(response.Payload, eventErr)
+		return eventErr
+	}
+
+	return json.Unmarshal(response.Payload, output)
+}
+{{ range .Events }}
+// {{ .Method }} sends "{{ .Name }}" version {{ .Version }}
+{{ comment "" .Doc }}func (c *Client) {{ .Method }}(ctx context.Context, flowID string, input {{ type .Input }}) ({{ type .Output }}, error) {
+	var output {{ type .Output }}
+	err := c.call(ctx, {{ printf "%q" .Name }}, {{ .Version }}, flowID, input, &output)
+	return output, err
+}
+{{ end }}
+{{ range .Structs }}
+{{ if .Description }}{{ comment "" .Description }}{{ else }}// {{ .Name }} generated from the event schemas
+{{ end }}type {{ .Name }} struct {
+{{ range .Fields }}{{ comment "	" .Description }}	{{ .Name }} {{ type .Type }} {{ tag . }}
+{{ end }}}
+{{ end }}`))
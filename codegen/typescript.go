@@ -0,0 +1,92 @@
+package codegen
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	events "github.com/GuiaBolso/Go-Events"
+)
+
+// TypeScript generates the TypeScript types of the events of document:
+// one interface per schema type plus request and response aliases of the
+// Event envelope for every event and version
+func TypeScript(document events.SchemaDocument) ([]byte, error) {
+	m, err := newModel(document)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+
+	if err := tsTemplate.Execute(&b, m); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+func tsType(t typeRef) string {
+	switch t.Kind {
+	case kindString:
+		return "string"
+	case kindInteger, kindNumber:
+		return "number"
+	case kindBoolean:
+		return "boolean"
+	case kindArray:
+		elem := tsType(*t.Elem)
+		if strings.ContainsAny(elem, " |") {
+			elem = "(" + elem + ")"
+		}
+		return elem + "[]"
+	case kindMap:
+		return "{ [key: string]: " + tsType(*t.Elem) + " }"
+	case kindStruct:
+		return t.Name
+	}
+	return "unknown"
+}
+
+func tsComment(indent, text string) string {
+	if text == "" {
+		return ""
+	}
+
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for i, line := range lines {
+		lines[i] = indent + " * " + strings.TrimSpace(line)
+	}
+
+	return indent + "/**\n" + strings.Join(lines, "\n") + "\n" + indent + " */\n"
+}
+
+var tsTemplate = template.Must(template.New("ts").Funcs(template.FuncMap{
+	"type":    tsType,
+	"comment": tsComment,
+}).Parse(`// Code generated by eventsgen. DO NOT EDIT.
+
+export interface Event<P = unknown> {
+  name: string;
+  version: number;
+  id: string;
+  flowId?: string;
+  payload: P;
+  metadata?: unknown;
+}
+
+export interface ErrorPayload {
+  message: string;
+  code?: number;
+}
+
+export type ErrorEvent = Event<ErrorPayload>;
+{{ range .Structs }}
+{{ comment "" .Description }}export interface {{ .Name }} {
+{{ range .Fields }}{{ comment "  " .Description }}  {{ printf "%q" .JSONName }}{{ if not .Required }}?{{ end }}: {{ type .Type }};
+{{ end }}}
+{{ end }}{{ range .Events }}
+{{ comment "" .Doc }}export const {{ .Method }} = { name: {{ printf "%q" .Name }}, version: {{ .Version }} } as const;
+export type {{ .Method }}Request = Event<{{ type .Input }}>;
+export type {{ .Method }}Response = Event<{{ type .Output }}>;
+{{ end }}`))
@@ -0,0 +1,186 @@
+package events
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content encodings supported by Mux and Client
+const (
+	EncodingGzip = "gzip"
+	EncodingZstd = "zstd"
+)
+
+type unsupportedEncodingError string
+
+func (e unsupportedEncodingError) Error() string {
+	return fmt.Sprintf("Unsupported content encoding %q", string(e))
+}
+
+// zstdEncoder is safe for concurrent EncodeAll calls
+var zstdEncoder, _ = zstd.NewWriter(nil)
+
+func compress(encoding string, data []byte) ([]byte, error) {
+	switch encoding {
+	case EncodingZstd:
+		return zstdEncoder.EncodeAll(data, nil), nil
+	case EncodingGzip:
+		buf := &bytes.Buffer{}
+		writer := gzip.NewWriter(buf)
+
+		if _, err := writer.Write(data); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+	}
+
+	return nil, unsupportedEncodingError(encoding)
+}
+
+// decompress returns a reader of the decoded body, the encoding is the value
+// of a Content-Encoding header. With maxBytes > 0 the zstd decoder does not
+// allocate windows or frames larger than maxBytes.
+func decompress(encoding string, body io.Reader, maxBytes int64) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return ioutil.NopCloser(body), nil
+	case EncodingGzip, "x-gzip":
+		return gzip.NewReader(body)
+	case EncodingZstd:
+		options := []zstd.DOption{zstd.WithDecoderConcurrency(1)}
+
+		if maxBytes > 0 {
+			window := uint64(maxBytes)
+			if window < zstd.MinWindowSize {
+				window = zstd.MinWindowSize
+			}
+
+			options = append(options, zstd.WithDecoderMaxMemory(uint64(maxBytes)), zstd.WithDecoderMaxWindow(window))
+		}
+
+		decoder, err := zstd.NewReader(body, options...)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	}
+
+	return nil, unsupportedEncodingError(encoding)
+}
+
+// negotiateEncoding picks the encoding of a response from an
+// Accept-Encoding header, zstd is preferred over gzip with the same quality.
+// It returns "" when neither is accepted.
+func negotiateEncoding(acceptEncoding string) string {
+	best, bestQuality := "", 0.0
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(fields[0]))
+		quality := 1.0
+
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					quality = q
+				}
+			}
+		}
+
+		candidates := []string{coding}
+		if coding == "*" {
+			candidates = []string{EncodingZstd, EncodingGzip}
+		}
+
+		for _, candidate := range candidates {
+			if candidate != EncodingZstd && candidate != EncodingGzip {
+				continue
+			}
+
+			if quality > bestQuality || (quality == bestQuality && candidate == EncodingZstd) {
+				best, bestQuality = candidate, quality
+			}
+		}
+	}
+
+	if bestQuality <= 0 {
+		return ""
+	}
+
+	return best
+}
+
+// writeEvent writes event in the format negotiated by the request,
+// compressed with the encoding accepted by the client when it reaches the
+// CompressionThreshold. When event can not be encoded the client gets a
+// JSON error event with status 500 and the error is returned.
+func (m *Mux) writeEvent(w http.ResponseWriter, r *http.Request, event Event) error {
+	body, err := m.encodeEvent(w, r, event)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Del("Content-Encoding")
+		w.WriteHeader(http.StatusInternalServerError)
+
+		body, _ = json.Marshal(NewError(event.FlowID, fmt.Sprintf("Could not encode the response: %s", err)))
+		w.Write(append(body, '\n'))
+
+		return err
+	}
+
+	_, err = w.Write(body)
+	return err
+}
+
+// encodeEvent returns the body of writeEvent, setting its headers
+func (m *Mux) encodeEvent(w http.ResponseWriter, r *http.Request, event Event) ([]byte, error) {
+	var body []byte
+	var err error
+
+	if codec := m.responseCodec(r.Header.Get("Accept"), r.Header.Get("Content-Type")); codec != nil {
+		body, err = codec.Marshal(event)
+		w.Header().Set("Content-Type", codec.ContentType())
+	} else {
+		body, err = json.Marshal(event)
+		body = append(body, '\n')
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	threshold := m.httpOptions.CompressionThreshold
+
+	if threshold > 0 {
+		// The response depends on Accept-Encoding even when it is too small
+		// to be compressed
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
+
+	if threshold > 0 && len(body) >= threshold {
+		if encoding := negotiateEncoding(r.Header.Get("Accept-Encoding")); encoding != "" {
+			compressed, err := compress(encoding, body)
+			if err != nil {
+				return nil, err
+			}
+
+			w.Header().Set("Content-Encoding", encoding)
+			body = compressed
+		}
+	}
+
+	return body, nil
+}
@@ -0,0 +1,162 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_negotiateEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":                       "",
+		"identity":               "",
+		"gzip":                   EncodingGzip,
+		"gzip, deflate, br":      EncodingGzip,
+		"zstd, gzip":             EncodingZstd,
+		"gzip, zstd":             EncodingZstd,
+		"gzip;q=1.0, zstd;q=0.5": EncodingGzip,
+		"zstd;q=0, gzip;q=0.1":   EncodingGzip,
+		"*":                      EncodingZstd,
+		"*, zstd;q=0":            EncodingZstd,
+		"gzip;q=0":               "",
+	}
+
+	for accept, wants := range tests {
+		if encoding := negotiateEncoding(accept); encoding != wants {
+			t.Errorf("negotiateEncoding(%q) == %q, wants: %q", accept, encoding, wants)
+		}
+	}
+}
+
+func Test_compress(t *testing.T) {
+	data := []byte(strings.Repeat(`{"a": 1}`, 100))
+
+	for _, encoding := range []string{EncodingGzip, EncodingZstd} {
+		compressed, err := compress(encoding, data)
+		if err != nil {
+			t.Fatalf("Error not expected: \"%s\"", err)
+		}
+
+		if len(compressed) >= len(data) {
+			t.Errorf("%s: len(compressed) == %d, wants less than %d", encoding, len(compressed), len(data))
+		}
+
+		reader, err := decompress(encoding, bytes.NewReader(compressed), 0)
+		if err != nil {
+			t.Fatalf("Error not expected: \"%s\"", err)
+		}
+
+		buf := &bytes.Buffer{}
+		buf.ReadFrom(reader)
+		reader.Close()
+
+		if buf.String() != string(data) {
+			t.Errorf("%s: decompressed data differs", encoding)
+		}
+	}
+
+	if _, err := decompress("br", nil, 0); err == nil {
+		t.Error("Error expected with an unsupported encoding")
+	}
+}
+
+func Test_ServeHTTP_Compression(t *testing.T) {
+	mux := NewMux()
+	mux.Add("echo", 1, HandlerFunc(mockHandlerFunc))
+
+	small := `{"name": "echo", "version": 1, "payload": "small"}`
+	large := `{"name": "echo", "version": 1, "payload": "` + strings.Repeat("x", 2048) + `"}`
+
+	serve := func(body []byte, contentEncoding, acceptEncoding string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/events/", bytes.NewReader(body))
+		r.Header.Set("Content-Encoding", contentEncoding)
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := serve([]byte(small), "", "gzip"); w.Header().Get("Content-Encoding") != "" || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Content-Encoding == %s, Vary == %s, wants none below the threshold and Accept-Encoding", w.Header().Get("Content-Encoding"), w.Header().Get("Vary"))
+	}
+
+	if w := serve([]byte(large), "", ""); w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("Content-Encoding == %s, wants none when not accepted", w.Header().Get("Content-Encoding"))
+	}
+
+	for _, encoding := range []string{EncodingGzip, EncodingZstd} {
+		body, _ := compress(encoding, []byte(large))
+		w := serve(body, encoding, encoding)
+
+		if w.Header().Get("Content-Encoding") != encoding {
+			t.Fatalf("Content-Encoding == %s, wants: %s", w.Header().Get("Content-Encoding"), encoding)
+		}
+
+		reader, _ := decompress(encoding, w.Body, 0)
+		response := Event{}
+		json.NewDecoder(reader).Decode(&response)
+		reader.Close()
+
+		if response.Name != "echo:response" || len(response.Payload) != 2050 {
+			t.Errorf("%s: response == %s (%d bytes)", encoding, response.Name, len(response.Payload))
+		}
+	}
+
+	w := serve([]byte(small), "br", "")
+	response := Event{}
+	json.NewDecoder(w.Body).Decode(&response)
+
+	if !strings.Contains(string(response.Payload), `"code":6`) {
+		t.Errorf("response.Payload == %s, wants code %d", response.Payload, ErrorCodeUnsupportedEncoding)
+	}
+
+	// The limit applies to the decoded body
+	mux.SetHTTPOptions(HTTPOptions{MaxBodyBytes: 1024})
+	body, _ := compress(EncodingGzip, []byte(large))
+	w = serve(body, EncodingGzip, "")
+	json.NewDecoder(w.Body).Decode(&response)
+
+	if !strings.Contains(string(response.Payload), `"code":3`) {
+		t.Errorf("response.Payload == %s, wants code %d", response.Payload, ErrorCodeBodyTooLarge)
+	}
+
+	// zstd frames larger than the limit are not decoded
+	bomb := `{"name": "echo", "version": 1, "payload": "` + strings.Repeat("x", 16<<20) + `"}`
+	body, _ = compress(EncodingZstd, []byte(bomb))
+	w = serve(body, EncodingZstd, "")
+	response = Event{}
+	json.NewDecoder(w.Body).Decode(&response)
+
+	if !strings.Contains(string(response.Payload), `"code":3`) {
+		t.Errorf("response.Payload == %s, wants code %d", response.Payload, ErrorCodeBodyTooLarge)
+	}
+}
+
+func Test_Client_Compression(t *testing.T) {
+	mux := NewMux()
+	mux.Add("echo", 1, HandlerFunc(mockHandlerFunc))
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	payload, _ := json.Marshal(strings.Repeat("x", 4096))
+
+	for _, encoding := range []string{"", EncodingGzip, EncodingZstd} {
+		client := NewClient(server.URL)
+		client.Encoding = encoding
+
+		response, err := client.Send(context.Background(), Event{Name: "echo", Version: 1, Payload: payload})
+		if err != nil {
+			t.Fatalf("Error not expected: \"%s\"", err)
+		}
+
+		if response.Name != "echo:response" || string(response.Payload) != string(payload) {
+			t.Errorf("%q: response == %s (%d bytes)", encoding, response.Name, len(response.Payload))
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrDeadLetterNotFound is returned for unknown dead letter IDs
+var ErrDeadLetterNotFound = errors.New("events: dead letter not found")
+
+// DeadLetter - An event that failed every attempt of a Consumer
+type DeadLetter struct {
+	ID       string    `json:"id"`
+	Topic    string    `json:"topic"`
+	Event    Event     `json:"event"`
+	Error    string    `json:"error"`
+	Attempts int       `json:"attempts"`
+	FailedAt time.Time `json:"failedAt"`
+}
+
+// DeadLetterStore - Where a Consumer puts the events it gave up on
+type DeadLetterStore interface {
+	Add(context.Context, DeadLetter) error
+	List(context.Context) ([]DeadLetter, error)
+	Remove(ctx context.Context, id string) error
+}
+
+// NewDeadLetter returns a DeadLetter of event
+func NewDeadLetter(topic string, event Event, err error, attempts int) DeadLetter {
+	return DeadLetter{
+		ID:       RandomID(),
+		Topic:    topic,
+		Event:    event,
+		Error:    err.Error(),
+		Attempts: attempts,
+		FailedAt: time.Now().UTC(),
+	}
+}
+
+// ListDeadLetters returns the dead letters of store with their events
+// redacted by Mux.Redact, to be shown or exported. The store keeps the
+// original events for Redrive.
+func ListDeadLetters(ctx context.Context, mux *Mux, store DeadLetterStore) ([]DeadLetter, error) {
+	deadLetters, err := store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range deadLetters {
+		deadLetters[i].Event = mux.Redact(deadLetters[i].Event)
+	}
+
+	return deadLetters, nil
+}
+
+// RedriveResult - The outcome of re-dispatching a DeadLetter, its
+// DeadLetter redacted by Mux.Redact
+type RedriveResult struct {
+	DeadLetter DeadLetter
+	Response   Event
+	Err        error
+}
+
+// Redrive dispatches dead letters back through mux with Mux.Dispatch, their
+// attempts metadata reset. The ones whose handlers succeed are removed from
+// the store. Without ids every dead letter is redriven.
+func Redrive(ctx context.Context, mux *Mux, store DeadLetterStore, ids ...string) ([]RedriveResult, error) {
+	deadLetters, err := store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := map[string]bool{}
+	for _, id := range ids {
+		selected[id] = true
+	}
+
+	results := []RedriveResult{}
+
+	for _, deadLetter := range deadLetters {
+		if len(ids) > 0 && !selected[deadLetter.ID] {
+			continue
+		}
+		delete(selected, deadLetter.ID)
+
+		event := withoutMetadataField(deadLetter.Event, MetadataAttempts)
+
+		result := RedriveResult{DeadLetter: deadLetter}
+		result.DeadLetter.Event = mux.Redact(deadLetter.Event)
+		result.Response, result.Err = mux.Dispatch(ctx, event)
+
+		if result.Err == nil {
+			if err := store.Remove(ctx, deadLetter.ID); err != nil {
+				return results, err
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	for id := range selected {
+		return results, fmt.Errorf("%s: %s", ErrDeadLetterNotFound, id)
+	}
+
+	return results, nil
+}
+
+// MemoryDeadLetterStore - A DeadLetterStore kept in memory
+type MemoryDeadLetterStore struct {
+	mu          sync.Mutex
+	deadLetters []DeadLetter
+}
+
+// NewMemoryDeadLetterStore returns an empty MemoryDeadLetterStore
+func NewMemoryDeadLetterStore() *MemoryDeadLetterStore {
+	return &MemoryDeadLetterStore{}
+}
+
+// Add implements DeadLetterStore
+func (s *MemoryDeadLetterStore) Add(_ context.Context, deadLetter DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deadLetters = append(s.deadLetters, deadLetter)
+	return nil
+}
+
+// List implements DeadLetterStore
+func (s *MemoryDeadLetterStore) List(context.Context) ([]DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]DeadLetter{}, s.deadLetters...), nil
+}
+
+// Remove implements DeadLetterStore
+func (s *MemoryDeadLetterStore) Remove(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, deadLetter := range s.deadLetters {
+		if deadLetter.ID == id {
+			s.deadLetters = append(s.deadLetters[:i:i], s.deadLetters[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrDeadLetterNotFound
+}
+
+// FileDeadLetterStore - A DeadLetterStore in a JSONL file, one dead letter
+// per line. Removing rewrites the file.
+type FileDeadLetterStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileDeadLetterStore returns a FileDeadLetterStore using path, the file
+// is created on the first Add
+func NewFileDeadLetterStore(path string) *FileDeadLetterStore {
+	return &FileDeadLetterStore{path: path}
+}
+
+// Add implements DeadLetterStore
+func (s *FileDeadLetterStore) Add(_ context.Context, deadLetter DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	line, err := json.Marshal(deadLetter)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// List implements DeadLetterStore
+func (s *FileDeadLetterStore) List(context.Context) ([]DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.read()
+}
+
+func (s *FileDeadLetterStore) read() ([]DeadLetter, error) {
+	deadLetters := []DeadLetter{}
+
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return deadLetters, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		deadLetter := DeadLetter{}
+		if err := json.Unmarshal(scanner.Bytes(), &deadLetter); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", s.path, line, err)
+		}
+
+		deadLetters = append(deadLetters, deadLetter)
+	}
+
+	return deadLetters, scanner.Err()
+}
+
+// Remove implements DeadLetterStore
+func (s *FileDeadLetterStore) Remove(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deadLetters, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.Create(s.path + ".tmp")
+	if err != nil {
+		return err
+	}
+
+	found := false
+	enc := json.NewEncoder(tmp)
+
+	for _, deadLetter := range deadLetters {
+		if deadLetter.ID == id {
+			found = true
+			continue
+		}
+
+		if err := enc.Encode(deadLetter); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return err
+		}
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if !found {
+		os.Remove(tmp.Name())
+		return ErrDeadLetterNotFound
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
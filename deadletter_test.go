@@ -0,0 +1,60 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func testDeadLetterStore(t *testing.T, store DeadLetterStore) {
+	ctx := context.Background()
+
+	list, err := store.List(ctx)
+	if err != nil || len(list) != 0 {
+		t.Fatalf("Expecting an empty store, got: %v, %v", list, err)
+	}
+
+	for _, id := range []string{"a", "b", "c"} {
+		deadLetter := NewDeadLetter("topic", Event{Name: "some event", ID: id}, errors.New("failed"), 2)
+		deadLetter.ID = id
+
+		if err := store.Add(ctx, deadLetter); err != nil {
+			t.Fatalf(`Error not expected: "%s"`, err.Error())
+		}
+	}
+
+	if err := store.Remove(ctx, "b"); err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+
+	if err := store.Remove(ctx, "b"); err != ErrDeadLetterNotFound {
+		t.Errorf("Expecting ErrDeadLetterNotFound, got: %v", err)
+	}
+
+	list, _ = store.List(ctx)
+
+	if len(list) != 2 || list[0].ID != "a" || list[1].ID != "c" {
+		t.Fatalf("Unexpected dead letters: %#v", list)
+	}
+
+	if list[1].Error != "failed" || list[1].Attempts != 2 || list[1].Event.ID != "c" {
+		t.Errorf("Unexpected dead letter: %#v", list[1])
+	}
+}
+
+func Test_MemoryDeadLetterStore(t *testing.T) {
+	testDeadLetterStore(t, NewMemoryDeadLetterStore())
+}
+
+func Test_FileDeadLetterStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead-letters.jsonl")
+
+	testDeadLetterStore(t, NewFileDeadLetterStore(path))
+
+	list, _ := NewFileDeadLetterStore(path).List(context.Background())
+
+	if len(list) != 2 {
+		t.Errorf("Dead letters must be persisted, len(list) == %d", len(list))
+	}
+}
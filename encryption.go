@@ -0,0 +1,399 @@
+package events
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// MetadataEncryption is the metadata field with the encryption envelope of
+// an event with encrypted payload fields
+const MetadataEncryption = "encryption"
+
+// ErrorCodeDecryptionFailed is the code of the error events of events
+// DecryptPayloads cannot decrypt
+const ErrorCodeDecryptionFailed = 8
+
+// Encryption errors
+var (
+	ErrUnknownEncryptionKey = errors.New("events: unknown encryption key")
+	ErrDecryptionFailed     = errors.New("events: decryption failed")
+	ErrPlaintextField       = errors.New("events: encrypted field in plain text")
+)
+
+// EncryptionKey - An AES key of 16, 24 or 32 bytes wrapping the data keys
+// of encrypted events
+type EncryptionKey struct {
+	ID  string
+	Key []byte
+}
+
+// EncryptionKeyring - The EncryptionKeys of encrypted events, by key ID.
+// The primary key wraps the data keys of new events, every key unwraps.
+// Keys are rotated by adding a new primary key, rewrapping the stored
+// events with RewrapFields and then removing the old key.
+type EncryptionKeyring struct {
+	mu      sync.RWMutex
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// NewEncryptionKeyring returns an EncryptionKeyring with primary as its
+// primary key and others for decryption only
+func NewEncryptionKeyring(primary EncryptionKey, others ...EncryptionKey) (*EncryptionKeyring, error) {
+	keyring := &EncryptionKeyring{keys: map[string]cipher.AEAD{}}
+
+	for _, key := range append([]EncryptionKey{primary}, others...) {
+		if err := keyring.Add(key); err != nil {
+			return nil, err
+		}
+	}
+
+	keyring.primary = primary.ID
+
+	return keyring, nil
+}
+
+// Add adds key for decryption, replacing the key with the same ID
+func (k *EncryptionKeyring) Add(key EncryptionKey) error {
+	aead, err := newAEAD(key.Key)
+	if err != nil {
+		return fmt.Errorf("events: encryption key %q: %s", key.ID, err)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.keys[key.ID] = aead
+	return nil
+}
+
+// Rotate adds key and makes it the primary key
+func (k *EncryptionKeyring) Rotate(key EncryptionKey) error {
+	if err := k.Add(key); err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.primary = key.ID
+	return nil
+}
+
+// Remove removes the key with the ID, the primary key cannot be removed
+func (k *EncryptionKeyring) Remove(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if id == k.primary {
+		return fmt.Errorf("events: encryption key %q is the primary key", id)
+	}
+
+	delete(k.keys, id)
+	return nil
+}
+
+// Primary returns the ID of the primary key
+func (k *EncryptionKeyring) Primary() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.primary
+}
+
+func (k *EncryptionKeyring) key(id string) (cipher.AEAD, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	aead, ok := k.keys[id]
+	return aead, ok
+}
+
+// encryptionEnvelope - The metadata of an event with encrypted fields: the
+// data key wrapped by KeyID and the JSON pointers of the fields
+type encryptionEnvelope struct {
+	KeyID  string   `json:"kid"`
+	Key    []byte   `json:"key"`
+	Fields []string `json:"fields"`
+}
+
+// EncryptedFields returns the JSON pointers of the fields of input tagged
+// `events:"encrypted"`, "*" standing for every element of slices and maps
+func EncryptedFields(input interface{}) []string {
+	return taggedPaths(input, "encrypted")
+}
+
+// EncryptFields returns a copy of event with the payload fields tagged
+// `events:"encrypted"` in input, a value of the Input type of the event,
+// encrypted with AES-GCM. Each event has a new data key, wrapped by the
+// primary key of keys and stored in the MetadataEncryption field.
+func EncryptFields(event Event, input interface{}, keys *EncryptionKeyring) (Event, error) {
+	paths := EncryptedFields(input)
+	if len(paths) == 0 {
+		return event, nil
+	}
+
+	payload, err := decodeJSON(event.Payload)
+	if err != nil {
+		return event, err
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return event, err
+	}
+
+	data, _ := newAEAD(dataKey)
+	envelope := encryptionEnvelope{Fields: []string{}}
+
+	for _, path := range paths {
+		payload, err = replacePath(payload, path, true, func(pointer string, value interface{}) (interface{}, error) {
+			if value == nil {
+				return nil, nil
+			}
+
+			plaintext, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+
+			ciphertext, err := seal(data, plaintext, []byte(pointer))
+			if err != nil {
+				return nil, err
+			}
+
+			envelope.Fields = append(envelope.Fields, pointer)
+			return base64.StdEncoding.EncodeToString(ciphertext), nil
+		})
+
+		if err != nil {
+			return event, err
+		}
+	}
+
+	if len(envelope.Fields) == 0 {
+		return event, nil
+	}
+
+	if envelope.KeyID, envelope.Key, err = wrapDataKey(keys, dataKey); err != nil {
+		return event, err
+	}
+
+	if event.Payload, err = json.Marshal(payload); err != nil {
+		return event, err
+	}
+
+	return WithMetadataField(event, MetadataEncryption, envelope), nil
+}
+
+// DecryptFields returns a copy of event with the fields encrypted by
+// EncryptFields decrypted and without the MetadataEncryption field. The
+// fields tagged `events:"encrypted"` in input, a value of the Input type of
+// the event, must be encrypted, ErrPlaintextField is returned otherwise. A
+// nil input accepts plain text fields and events without encryption.
+func DecryptFields(event Event, input interface{}, keys *EncryptionKeyring) (Event, error) {
+	envelope := encryptionEnvelope{}
+	if !MetadataField(event, MetadataEncryption, &envelope) {
+		return event, checkEncryptedFields(event, input, nil)
+	}
+
+	if err := checkEncryptedFields(event, input, envelope.Fields); err != nil {
+		return event, err
+	}
+
+	dataKey, err := unwrapDataKey(keys, envelope)
+	if err != nil {
+		return event, err
+	}
+
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return event, ErrDecryptionFailed
+	}
+
+	payload, err := decodeJSON(event.Payload)
+	if err != nil {
+		return event, err
+	}
+
+	for _, field := range envelope.Fields {
+		found := false
+
+		payload, err = replacePath(payload, field, false, func(pointer string, value interface{}) (interface{}, error) {
+			found = true
+
+			encoded, _ := value.(string)
+			ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, ErrDecryptionFailed
+			}
+
+			plaintext, err := unseal(data, ciphertext, []byte(pointer))
+			if err != nil {
+				return nil, ErrDecryptionFailed
+			}
+
+			return decodeJSON(plaintext)
+		})
+
+		if err != nil {
+			return event, err
+		}
+
+		if !found {
+			return event, ErrDecryptionFailed
+		}
+	}
+
+	if event.Payload, err = json.Marshal(payload); err != nil {
+		return event, err
+	}
+
+	return withoutMetadataField(event, MetadataEncryption), nil
+}
+
+// checkEncryptedFields returns ErrPlaintextField when a value of the fields
+// of input tagged `events:"encrypted"` is not in encrypted. The keys of the
+// payload match the tags in any case, as json.Unmarshal fills the fields.
+func checkEncryptedFields(event Event, input interface{}, encrypted []string) error {
+	paths := EncryptedFields(input)
+	if len(paths) == 0 || len(event.Payload) == 0 {
+		return nil
+	}
+
+	payload, err := decodeJSON(event.Payload)
+	if err != nil {
+		return err
+	}
+
+	fields := map[string]bool{}
+	for _, field := range encrypted {
+		fields[field] = true
+	}
+
+	for _, path := range paths {
+		_, err := replacePath(payload, path, true, func(pointer string, value interface{}) (interface{}, error) {
+			if value != nil && !fields[pointer] {
+				return value, ErrPlaintextField
+			}
+
+			return value, nil
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RewrapFields returns a copy of event with its data key wrapped by the
+// primary key of keys, without decrypting the fields
+func RewrapFields(event Event, keys *EncryptionKeyring) (Event, error) {
+	envelope := encryptionEnvelope{}
+	if !MetadataField(event, MetadataEncryption, &envelope) {
+		return event, nil
+	}
+
+	dataKey, err := unwrapDataKey(keys, envelope)
+	if err != nil {
+		return event, err
+	}
+
+	if envelope.KeyID, envelope.Key, err = wrapDataKey(keys, dataKey); err != nil {
+		return event, err
+	}
+
+	return WithMetadataField(event, MetadataEncryption, envelope), nil
+}
+
+// DecryptPayloads is a Middleware decrypting the fields encrypted by
+// EncryptFields before the handlers of mux run. The fields tagged
+// `events:"encrypted"` in the Input() of EventDoc handlers must be
+// encrypted. Events that cannot be decrypted or with those fields in plain
+// text get an ErrorCodeDecryptionFailed error event.
+func DecryptPayloads(mux *Mux, keys *EncryptionKeyring) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, event Event) (Event, error) {
+			var input interface{}
+			if eventWithDoc, ok := mux.events[eventKey{event.Name, event.Version}].(EventDoc); ok {
+				input = eventWithDoc.Input()
+			}
+
+			decrypted, err := DecryptFields(event, input, keys)
+			if err != nil {
+				return NewErrorWithCode(event.FlowID, "Could not decrypt the event", ErrorCodeDecryptionFailed), err
+			}
+
+			return next.Serve(ctx, decrypted)
+		})
+	}
+}
+
+func wrapDataKey(keys *EncryptionKeyring, dataKey []byte) (string, []byte, error) {
+	id := keys.Primary()
+
+	aead, ok := keys.key(id)
+	if !ok {
+		return "", nil, ErrUnknownEncryptionKey
+	}
+
+	wrapped, err := seal(aead, dataKey, []byte(id))
+	if err != nil {
+		return "", nil, err
+	}
+
+	return id, wrapped, nil
+}
+
+func unwrapDataKey(keys *EncryptionKeyring, envelope encryptionEnvelope) ([]byte, error) {
+	aead, ok := keys.key(envelope.KeyID)
+	if !ok {
+		return nil, ErrUnknownEncryptionKey
+	}
+
+	dataKey, err := unseal(aead, envelope.Key, []byte(envelope.KeyID))
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+
+	return dataKey, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext, the random nonce is prepended to the result
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func unseal(aead cipher.AEAD, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, ErrDecryptionFailed
+	}
+
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
@@ -0,0 +1,257 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type mockCustomer struct {
+	Name     string            `json:"name"`
+	CPF      string            `json:"cpf" events:"encrypted"`
+	Contacts []mockContact     `json:"contacts"`
+	Notes    map[string]string `json:"notes,omitempty"`
+	Card     *mockCard         `json:"card,omitempty"`
+}
+
+type mockContact struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value" events:"encrypted"`
+}
+
+type mockCard struct {
+	Number string `json:"number" events:"encrypted"`
+	Expiry int    `json:"expiry"`
+}
+
+func (h *mockCustomer) Serve(_ context.Context, event Event) (Event, error) {
+	customer := mockCustomer{}
+	json.Unmarshal(event.Payload, &customer)
+
+	return NewResponse(event, customer)
+}
+
+func (h *mockCustomer) Example() (interface{}, interface{}) {
+	return mockCustomer{}, mockCustomer{}
+}
+
+func (h *mockCustomer) Input() interface{} {
+	return mockCustomer{}
+}
+
+func (h *mockCustomer) Output() interface{} {
+	return mockCustomer{}
+}
+
+func (h *mockCustomer) Doc() string {
+	return ""
+}
+
+var mockCustomerPayload = json.RawMessage(`{"name":"Maria","cpf":"123.456.789-00","contacts":[{"kind":"email","value":"maria@example.com"},{"kind":"phone","value":"+55 11 99999-0000"}],"card":{"number":"4111111111111111","expiry":2030}}`)
+
+func newMockKeyring(t *testing.T, id string) *EncryptionKeyring {
+	keys, err := NewEncryptionKeyring(EncryptionKey{ID: id, Key: bytes.Repeat([]byte(id[:1]), 32)})
+	if err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	return keys
+}
+
+func Test_EncryptedFields(t *testing.T) {
+	fields := EncryptedFields(&mockCustomer{})
+	wants := []string{"/card/number", "/contacts/*/value", "/cpf"}
+
+	if !reflect.DeepEqual(fields, wants) {
+		t.Errorf("fields == %v, wants: %v", fields, wants)
+	}
+}
+
+func Test_EncryptFields(t *testing.T) {
+	keys := newMockKeyring(t, "a")
+	event := Event{Name: "customer", Version: 1, ID: "id", Payload: mockCustomerPayload}
+
+	encrypted, err := EncryptFields(event, mockCustomer{}, keys)
+	if err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	for _, secret := range []string{"123.456.789-00", "maria@example.com", "4111111111111111"} {
+		if strings.Contains(string(encrypted.Payload), secret) {
+			t.Errorf("Payload == %s, wants %s encrypted", encrypted.Payload, secret)
+		}
+	}
+
+	if !strings.Contains(string(encrypted.Payload), `"name":"Maria"`) {
+		t.Errorf("Payload == %s, wants the name in plain text", encrypted.Payload)
+	}
+
+	decrypted, err := DecryptFields(encrypted, mockCustomer{}, keys)
+	if err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	if !jsonEqual(decrypted.Payload, event.Payload) {
+		t.Errorf("Payload == %s, wants: %s", decrypted.Payload, event.Payload)
+	}
+
+	if MetadataField(decrypted, MetadataEncryption, &encryptionEnvelope{}) {
+		t.Error("Decrypted events must not have the encryption metadata")
+	}
+
+	// Encrypted values are bound to their field
+	payload := map[string]interface{}{}
+	json.Unmarshal(encrypted.Payload, &payload)
+	payload["cpf"] = payload["card"].(map[string]interface{})["number"]
+
+	swapped := encrypted
+	swapped.Payload, _ = json.Marshal(payload)
+
+	if _, err := DecryptFields(swapped, mockCustomer{}, keys); err != ErrDecryptionFailed {
+		t.Errorf("err == %v, wants: %v", err, ErrDecryptionFailed)
+	}
+
+	if _, err := DecryptFields(encrypted, mockCustomer{}, newMockKeyring(t, "b")); err != ErrUnknownEncryptionKey {
+		t.Errorf("err == %v, wants: %v", err, ErrUnknownEncryptionKey)
+	}
+}
+
+func Test_EncryptionKeyring_rotation(t *testing.T) {
+	keys := newMockKeyring(t, "a")
+	event := Event{Name: "customer", Version: 1, ID: "id", Payload: mockCustomerPayload}
+
+	old, _ := EncryptFields(event, mockCustomer{}, keys)
+
+	if err := keys.Rotate(EncryptionKey{ID: "b", Key: bytes.Repeat([]byte("b"), 32)}); err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	if err := keys.Remove("b"); err == nil {
+		t.Error("Error expected removing the primary key")
+	}
+
+	rewrapped, err := RewrapFields(old, keys)
+	if err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	if err := keys.Remove("a"); err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	if _, err := DecryptFields(old, mockCustomer{}, keys); err != ErrUnknownEncryptionKey {
+		t.Errorf("err == %v, wants: %v", err, ErrUnknownEncryptionKey)
+	}
+
+	decrypted, err := DecryptFields(rewrapped, mockCustomer{}, keys)
+	if err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	if !jsonEqual(decrypted.Payload, event.Payload) {
+		t.Errorf("Payload == %s, wants: %s", decrypted.Payload, event.Payload)
+	}
+
+	if _, err := NewEncryptionKeyring(EncryptionKey{ID: "short", Key: []byte("short")}); err == nil {
+		t.Error("Error expected with an invalid AES key")
+	}
+}
+
+func Test_DecryptPayloads(t *testing.T) {
+	keys := newMockKeyring(t, "a")
+
+	mux := NewMux()
+	mux.Add("customer", 1, &mockCustomer{})
+	mux.Use(DecryptPayloads(mux, keys))
+
+	encrypted, _ := EncryptFields(Event{Name: "customer", Version: 1, Payload: mockCustomerPayload}, mockCustomer{}, keys)
+
+	response, err := mux.Dispatch(context.Background(), encrypted)
+	if err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	if !jsonEqual(response.Payload, mockCustomerPayload) {
+		t.Errorf("Payload == %s, wants: %s", response.Payload, mockCustomerPayload)
+	}
+
+	original := encryptionEnvelope{}
+	MetadataField(encrypted, MetadataEncryption, &original)
+
+	tampered := WithMetadataField(encrypted, MetadataEncryption, encryptionEnvelope{KeyID: "a", Key: []byte("garbage"), Fields: original.Fields})
+
+	response, err = mux.Dispatch(context.Background(), tampered)
+	if err != ErrDecryptionFailed || response.Name != "error" {
+		t.Errorf("response.Name == %s, err == %v, wants: error, %v", response.Name, err, ErrDecryptionFailed)
+	}
+
+	// Plain text values of encrypted fields are rejected
+	plaintext := Event{Name: "customer", Version: 1, Payload: mockCustomerPayload}
+
+	if _, err := mux.Dispatch(context.Background(), plaintext); err != ErrPlaintextField {
+		t.Errorf("err == %v, wants: %v", err, ErrPlaintextField)
+	}
+
+	if _, err := DecryptFields(plaintext, nil, keys); err != nil {
+		t.Errorf("Error not expected with a nil input: \"%s\"", err)
+	}
+
+	envelope := original
+	envelope.Fields = envelope.Fields[1:]
+
+	stripped := WithMetadataField(encrypted, MetadataEncryption, envelope)
+
+	if _, err := mux.Dispatch(context.Background(), stripped); err != ErrPlaintextField {
+		t.Errorf("err == %v, wants: %v", err, ErrPlaintextField)
+	}
+
+	// Keys of any case are decoded into the encrypted fields
+	payload := map[string]json.RawMessage{}
+	json.Unmarshal(encrypted.Payload, &payload)
+	payload["CPF"] = json.RawMessage(`"123.456.789-00"`)
+
+	mixedCase := encrypted
+	mixedCase.Payload, _ = json.Marshal(payload)
+
+	if _, err := mux.Dispatch(context.Background(), mixedCase); err != ErrPlaintextField {
+		t.Errorf("err == %v, wants: %v", err, ErrPlaintextField)
+	}
+
+	if _, err := DecryptFields(Event{Name: "customer", Version: 1, Payload: json.RawMessage(`{"Contacts":[{"VALUE":"maria@example.com"}]}`)}, mockCustomer{}, keys); err != ErrPlaintextField {
+		t.Errorf("err == %v, wants: %v", err, ErrPlaintextField)
+	}
+
+	schema := mux.Schema()
+	if !reflect.DeepEqual(schema.Events[0].Encrypted, EncryptedFields(mockCustomer{})) {
+		t.Errorf("Encrypted == %v, wants: %v", schema.Events[0].Encrypted, EncryptedFields(mockCustomer{}))
+	}
+}
+
+type mockFailingReader struct{}
+
+func (mockFailingReader) Read([]byte) (int, error) {
+	return 0, errors.New("no entropy")
+}
+
+func Test_EncryptFields_rand_error(t *testing.T) {
+	keys := newMockKeyring(t, "a")
+
+	reader := rand.Reader
+	rand.Reader = mockFailingReader{}
+	defer func() { rand.Reader = reader }()
+
+	if _, err := EncryptFields(Event{Name: "customer", Version: 1, Payload: mockCustomerPayload}, mockCustomer{}, keys); err == nil {
+		t.Error("Error expected without random bytes")
+	}
+
+	data, _ := newAEAD(bytes.Repeat([]byte("d"), 32))
+
+	if _, err := seal(data, []byte("plaintext"), nil); err == nil {
+		t.Error("Error expected without a random nonce")
+	}
+}
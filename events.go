@@ -1,7 +1,6 @@
 package events
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 )
@@ -66,39 +65,3 @@ func NewResponse(request Event, payload interface{}) (Event, error) {
 		Payload: response,
 	}, err
 }
-
-// Batch is an event that executes batches of events
-func Batch(mux *Mux) HandlerFunc {
-	return func(ctx context.Context, event Event) (Event, error) {
-		payload := struct {
-			Parallel bool    `json:"parallel"`
-			Events   []Event `json:"events"`
-		}{}
-
-		responses := []Event{}
-
-		if err := json.Unmarshal(event.Payload, &payload); err != nil {
-			return NewError(event.FlowID, err.Error()), err
-		}
-
-		for _, ev := range payload.Events {
-			if h, ok := mux.get(ev.Name, ev.Version); ok {
-				resp, err := h.Serve(ctx, ev)
-
-				responses = append(responses, resp)
-
-				if err != nil {
-					ctx = mux.tracer.NoticeEventError(ctx, ev, err)
-				}
-
-			} else {
-				responses = append(responses, NewError(
-					ev.FlowID,
-					fmt.Sprintf(`Event "%s" not found`, ev.Name),
-				))
-			}
-		}
-
-		return NewResponse(event, responses)
-	}
-}
@@ -144,3 +144,32 @@ func Test_NewErrorWithMetadata(t *testing.T) {
 			42)
 	}
 }
+
+func Test_MetadataField(t *testing.T) {
+	event := Event{Metadata: json.RawMessage(`{"origin":"test"}`)}
+
+	event = WithMetadataField(event, "attempt", 3)
+
+	attempt := 0
+	if !MetadataField(event, "attempt", &attempt) || attempt != 3 {
+		t.Errorf("attempt == %d, wants: %d", attempt, 3)
+	}
+
+	origin := ""
+	if !MetadataField(event, "origin", &origin) || origin != "test" {
+		t.Errorf(`origin == "%s", wants: "test"`, origin)
+	}
+
+	if MetadataField(event, "missing", &origin) {
+		t.Error("Not expecting a missing field")
+	}
+
+	if MetadataField(Event{}, "attempt", &attempt) {
+		t.Error("Not expecting fields on empty metadata")
+	}
+
+	event = WithMetadataField(Event{Metadata: json.RawMessage(`[1, 2]`)}, "attempt", 1)
+	if string(event.Metadata) != `{"attempt":1}` {
+		t.Errorf(`event.Metadata == %s, wants: {"attempt":1}`, string(event.Metadata))
+	}
+}
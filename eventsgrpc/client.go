@@ -0,0 +1,180 @@
+package eventsgrpc
+
+import (
+	"context"
+
+	events "github.com/GuiaBolso/Go-Events"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// BatchEvent - A sub-event of a batch, see events.Batch
+type BatchEvent struct {
+	events.Event
+	DependsOn []string
+}
+
+// BatchRequest - The events of a batch and how they run, see events.Batch
+type BatchRequest struct {
+	FlowID   string
+	Parallel bool
+	Policy   events.BatchPolicy
+	Events   []BatchEvent
+}
+
+// Client calls the Events service
+type Client struct {
+	conn grpc.ClientConnInterface
+}
+
+// NewClient returns a Client using conn
+func NewClient(conn grpc.ClientConnInterface) *Client {
+	return &Client{conn: conn}
+}
+
+// Send dispatches event and returns its response. Error events are
+// returned as regular responses, only gRPC failures are errors.
+func (c *Client) Send(ctx context.Context, event events.Event, opts ...grpc.CallOption) (events.Event, error) {
+	response := newEvent()
+
+	if err := c.conn.Invoke(ctx, SendMethod, toProto(event), response, opts...); err != nil {
+		return events.Event{}, err
+	}
+
+	return fromProto(response), nil
+}
+
+// Batch dispatches the events of request and returns their responses in
+// the same order
+func (c *Client) Batch(ctx context.Context, request BatchRequest, opts ...grpc.CallOption) ([]events.BatchResponse, error) {
+	response := dynamicpb.NewMessage(batchResponseDesc)
+
+	if err := c.conn.Invoke(ctx, BatchMethod, batchRequestToProto(request), response, opts...); err != nil {
+		return nil, err
+	}
+
+	return batchResponseFromProto(response), nil
+}
+
+// Stream opens a stream of events, each event sent gets a response in the
+// same order
+func (c *Client) Stream(ctx context.Context, opts ...grpc.CallOption) (*Stream, error) {
+	stream, err := c.conn.NewStream(ctx, &serviceDesc.Streams[0], StreamMethod, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Stream{stream: stream}, nil
+}
+
+// Stream - An open Stream call
+type Stream struct {
+	stream grpc.ClientStream
+}
+
+// Send sends an event
+func (s *Stream) Send(event events.Event) error {
+	return s.stream.SendMsg(toProto(event))
+}
+
+// Recv receives the next response, io.EOF after the server closed the
+// stream
+func (s *Stream) Recv() (events.Event, error) {
+	response := newEvent()
+
+	if err := s.stream.RecvMsg(response); err != nil {
+		return events.Event{}, err
+	}
+
+	return fromProto(response), nil
+}
+
+// CloseSend tells the server no more events are sent
+func (s *Stream) CloseSend() error {
+	return s.stream.CloseSend()
+}
+
+func batchRequestToProto(request BatchRequest) *dynamicpb.Message {
+	m := dynamicpb.NewMessage(batchRequestDesc)
+
+	set(m, "flow_id", protoreflect.ValueOfString(request.FlowID))
+	set(m, "parallel", protoreflect.ValueOfBool(request.Parallel))
+	set(m, "policy", protoreflect.ValueOfString(string(request.Policy)))
+
+	list := m.Mutable(batchRequestDesc.Fields().ByName("events")).List()
+
+	for _, event := range request.Events {
+		item := dynamicpb.NewMessage(batchEventDesc)
+		set(item, "event", protoreflect.ValueOfMessage(toProto(event.Event)))
+
+		dependsOn := item.Mutable(batchEventDesc.Fields().ByName("depends_on")).List()
+		for _, id := range event.DependsOn {
+			dependsOn.Append(protoreflect.ValueOfString(id))
+		}
+
+		list.Append(protoreflect.ValueOfMessage(item))
+	}
+
+	return m
+}
+
+func batchRequestFromProto(m protoreflect.Message) BatchRequest {
+	request := BatchRequest{
+		FlowID:   get(m, "flow_id").String(),
+		Parallel: get(m, "parallel").Bool(),
+		Policy:   events.BatchPolicy(get(m, "policy").String()),
+	}
+
+	list := get(m, "events").List()
+
+	for i := 0; i < list.Len(); i++ {
+		item := list.Get(i).Message()
+		event := BatchEvent{Event: fromProto(get(item, "event").Message())}
+
+		dependsOn := get(item, "depends_on").List()
+		for j := 0; j < dependsOn.Len(); j++ {
+			event.DependsOn = append(event.DependsOn, dependsOn.Get(j).String())
+		}
+
+		request.Events = append(request.Events, event)
+	}
+
+	return request
+}
+
+func batchResponseToProto(responses []events.BatchResponse) *dynamicpb.Message {
+	m := dynamicpb.NewMessage(batchResponseDesc)
+	list := m.Mutable(batchResponseDesc.Fields().ByName("items")).List()
+
+	for _, response := range responses {
+		item := dynamicpb.NewMessage(batchItemDesc)
+
+		set(item, "response", protoreflect.ValueOfMessage(toProto(response.Event)))
+		set(item, "request_id", protoreflect.ValueOfString(response.RequestID))
+		set(item, "status", protoreflect.ValueOfString(string(response.Status)))
+		set(item, "duration_ms", protoreflect.ValueOfFloat64(response.DurationMs))
+
+		list.Append(protoreflect.ValueOfMessage(item))
+	}
+
+	return m
+}
+
+func batchResponseFromProto(m protoreflect.Message) []events.BatchResponse {
+	responses := []events.BatchResponse{}
+	list := get(m, "items").List()
+
+	for i := 0; i < list.Len(); i++ {
+		item := list.Get(i).Message()
+
+		responses = append(responses, events.BatchResponse{
+			Event:      fromProto(get(item, "response").Message()),
+			RequestID:  get(item, "request_id").String(),
+			Status:     events.BatchStatus(get(item, "status").String()),
+			DurationMs: get(item, "duration_ms").Float(),
+		})
+	}
+
+	return responses
+}
@@ -0,0 +1,201 @@
+package eventsgrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	events "github.com/GuiaBolso/Go-Events"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestClient(t *testing.T) *Client {
+	return newMuxClient(t, newTestMux())
+}
+
+func newTestMux() *events.Mux {
+	mux := events.NewMux()
+
+	mux.Add("echo", 1, events.HandlerFunc(func(_ context.Context, event events.Event) (events.Event, error) {
+		return events.NewResponse(event, event.Payload)
+	}))
+
+	mux.Add("fail", 1, events.HandlerFunc(func(_ context.Context, event events.Event) (events.Event, error) {
+		err := errors.New("failed")
+		return events.NewError(event.FlowID, err.Error()), err
+	}))
+
+	mux.Add("batch", 1, events.BatchWithLimits(mux, events.DefaultBatchLimits))
+
+	return mux
+}
+
+func newMuxClient(t *testing.T, mux *events.Mux) *Client {
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	NewServer(mux).Register(server)
+
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return NewClient(conn)
+}
+
+func Test_Send(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	event := events.Event{
+		Name:     "echo",
+		Version:  1,
+		ID:       "id",
+		FlowID:   "flow",
+		Payload:  json.RawMessage(`{"count":1}`),
+		Metadata: json.RawMessage(`{"origin":"grpc"}`),
+	}
+
+	response, err := client.Send(ctx, event)
+	if err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	if response.Name != "echo:response" || response.FlowID != "flow" || string(response.Payload) != `{"count":1}` {
+		t.Errorf("response == %s %s %s, wants: echo:response flow {\"count\":1}", response.Name, response.FlowID, response.Payload)
+	}
+
+	response, err = client.Send(ctx, events.Event{Name: "fail", Version: 1, FlowID: "flow"})
+	if err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	if response.Name != "error" {
+		t.Errorf("response.Name == %s, wants: error", response.Name)
+	}
+
+	response, _ = client.Send(ctx, events.Event{Name: "unknown", Version: 1})
+	if response.Name != "error" {
+		t.Errorf("response.Name == %s, wants: error", response.Name)
+	}
+}
+
+func Test_Stream(t *testing.T) {
+	client := newTestClient(t)
+
+	stream, err := client.Stream(context.Background())
+	if err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	names := []string{"echo", "fail", "echo"}
+	for i, name := range names {
+		payload, _ := json.Marshal(i)
+		if err := stream.Send(events.Event{Name: name, Version: 1, Payload: payload}); err != nil {
+			t.Fatalf("Error not expected: \"%s\"", err)
+		}
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	wants := []string{"echo:response", "error", "echo:response"}
+	for i, want := range wants {
+		response, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Error not expected: \"%s\"", err)
+		}
+
+		if response.Name != want {
+			t.Errorf("response[%d].Name == %s, wants: %s", i, response.Name, want)
+		}
+	}
+
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("err == %v, wants: %v", err, io.EOF)
+	}
+}
+
+func Test_Batch(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	responses, err := client.Batch(ctx, BatchRequest{
+		FlowID: "flow",
+		Events: []BatchEvent{
+			{Event: events.Event{Name: "echo", Version: 1, ID: "first", Payload: json.RawMessage(`{"value":7}`)}},
+			{Event: events.Event{Name: "echo", Version: 1, ID: "second", Payload: json.RawMessage(`"${first#/value}"`)}, DependsOn: []string{"first"}},
+			{Event: events.Event{Name: "fail", Version: 1, ID: "third"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	if len(responses) != 3 {
+		t.Fatalf("len(responses) == %d, wants: %d", len(responses), 3)
+	}
+
+	if responses[1].RequestID != "second" || string(responses[1].Payload) != `7` {
+		t.Errorf("responses[1] == %s %s, wants: second 7", responses[1].RequestID, responses[1].Payload)
+	}
+
+	if responses[2].Name != "error" || responses[2].Status != events.BatchFailed {
+		t.Errorf("responses[2] == %s %s, wants: error %s", responses[2].Name, responses[2].Status, events.BatchFailed)
+	}
+
+	_, err = client.Batch(ctx, BatchRequest{
+		Events: []BatchEvent{
+			{Event: events.Event{Name: "echo", Version: 1, ID: "a"}, DependsOn: []string{"b"}},
+			{Event: events.Event{Name: "echo", Version: 1, ID: "b"}, DependsOn: []string{"a"}},
+		},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("status.Code(err) == %s, wants: %s", status.Code(err), codes.InvalidArgument)
+	}
+}
+
+func Test_Batch_Mux(t *testing.T) {
+	ctx := context.Background()
+	request := BatchRequest{Events: []BatchEvent{{Event: events.Event{Name: "echo", Version: 1, ID: "a"}}}}
+
+	mux := newTestMux()
+	seen := make(chan string, 10)
+
+	mux.Use(func(next events.Handler) events.Handler {
+		return events.HandlerFunc(func(ctx context.Context, event events.Event) (events.Event, error) {
+			seen <- event.Name
+			return next.Serve(ctx, event)
+		})
+	})
+
+	if _, err := newMuxClient(t, mux).Batch(ctx, request); err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	if len(seen) == 0 || <-seen != "batch" {
+		t.Error("The batch event must go through the middlewares of the Mux")
+	}
+
+	_, err := newMuxClient(t, events.NewMux()).Batch(ctx, request)
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("status.Code(err) == %s, wants: %s", status.Code(err), codes.Unimplemented)
+	}
+}
@@ -0,0 +1,161 @@
+package eventsgrpc
+
+import (
+	events "github.com/GuiaBolso/Go-Events"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// file is the descriptor of events.proto, built at runtime since the
+// messages are handled with dynamicpb
+var file = buildFile()
+
+var (
+	eventDesc         = file.Messages().ByName("Event")
+	batchEventDesc    = file.Messages().ByName("BatchEvent")
+	batchRequestDesc  = file.Messages().ByName("BatchRequest")
+	batchItemDesc     = file.Messages().ByName("BatchItem")
+	batchResponseDesc = file.Messages().ByName("BatchResponse")
+)
+
+func field(name string, number int32, kind descriptorpb.FieldDescriptorProto_Type, typeName string, repeated bool) *descriptorpb.FieldDescriptorProto {
+	label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	if repeated {
+		label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	}
+
+	f := &descriptorpb.FieldDescriptorProto{
+		Name:   proto.String(name),
+		Number: proto.Int32(number),
+		Label:  label.Enum(),
+		Type:   kind.Enum(),
+	}
+
+	if typeName != "" {
+		f.TypeName = proto.String(".guiabolso.events.v1." + typeName)
+	}
+
+	return f
+}
+
+func message(name string, fields ...*descriptorpb.FieldDescriptorProto) *descriptorpb.DescriptorProto {
+	return &descriptorpb.DescriptorProto{Name: proto.String(name), Field: fields}
+}
+
+func buildFile() protoreflect.FileDescriptor {
+	const (
+		typeString  = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		typeInt32   = descriptorpb.FieldDescriptorProto_TYPE_INT32
+		typeBytes   = descriptorpb.FieldDescriptorProto_TYPE_BYTES
+		typeBool    = descriptorpb.FieldDescriptorProto_TYPE_BOOL
+		typeDouble  = descriptorpb.FieldDescriptorProto_TYPE_DOUBLE
+		typeMessage = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	)
+
+	method := func(name, input, output string, streaming bool) *descriptorpb.MethodDescriptorProto {
+		return &descriptorpb.MethodDescriptorProto{
+			Name:            proto.String(name),
+			InputType:       proto.String(".guiabolso.events.v1." + input),
+			OutputType:      proto.String(".guiabolso.events.v1." + output),
+			ClientStreaming: proto.Bool(streaming),
+			ServerStreaming: proto.Bool(streaming),
+		}
+	}
+
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("events.proto"),
+		Package: proto.String("guiabolso.events.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			message("Event",
+				field("name", 1, typeString, "", false),
+				field("version", 2, typeInt32, "", false),
+				field("id", 3, typeString, "", false),
+				field("flow_id", 4, typeString, "", false),
+				field("payload", 5, typeBytes, "", false),
+				field("metadata", 6, typeBytes, "", false),
+			),
+			message("BatchEvent",
+				field("event", 1, typeMessage, "Event", false),
+				field("depends_on", 2, typeString, "", true),
+			),
+			message("BatchRequest",
+				field("events", 1, typeMessage, "BatchEvent", true),
+				field("parallel", 2, typeBool, "", false),
+				field("policy", 3, typeString, "", false),
+				field("flow_id", 4, typeString, "", false),
+			),
+			message("BatchItem",
+				field("response", 1, typeMessage, "Event", false),
+				field("request_id", 2, typeString, "", false),
+				field("status", 3, typeString, "", false),
+				field("duration_ms", 4, typeDouble, "", false),
+			),
+			message("BatchResponse",
+				field("items", 1, typeMessage, "BatchItem", true),
+			),
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Events"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				method("Send", "Event", "Event", false),
+				method("Stream", "Event", "Event", true),
+				method("Batch", "BatchRequest", "BatchResponse", false),
+			},
+		}},
+	}
+
+	fd, err := protodesc.NewFile(fdp, nil)
+	if err != nil {
+		panic("eventsgrpc: invalid descriptor: " + err.Error())
+	}
+
+	return fd
+}
+
+func get(m protoreflect.Message, name protoreflect.Name) protoreflect.Value {
+	return m.Get(m.Descriptor().Fields().ByName(name))
+}
+
+func set(m protoreflect.Message, name protoreflect.Name, v protoreflect.Value) {
+	m.Set(m.Descriptor().Fields().ByName(name), v)
+}
+
+func newEvent() *dynamicpb.Message {
+	return dynamicpb.NewMessage(eventDesc)
+}
+
+func toProto(event events.Event) *dynamicpb.Message {
+	m := newEvent()
+
+	set(m, "name", protoreflect.ValueOfString(event.Name))
+	set(m, "version", protoreflect.ValueOfInt32(int32(event.Version)))
+	set(m, "id", protoreflect.ValueOfString(event.ID))
+	set(m, "flow_id", protoreflect.ValueOfString(event.FlowID))
+	set(m, "payload", protoreflect.ValueOfBytes(event.Payload))
+	set(m, "metadata", protoreflect.ValueOfBytes(event.Metadata))
+
+	return m
+}
+
+func fromProto(m protoreflect.Message) events.Event {
+	event := events.Event{
+		Name:    get(m, "name").String(),
+		Version: int(get(m, "version").Int()),
+		ID:      get(m, "id").String(),
+		FlowID:  get(m, "flow_id").String(),
+	}
+
+	if payload := get(m, "payload").Bytes(); len(payload) > 0 {
+		event.Payload = payload
+	}
+
+	if metadata := get(m, "metadata").Bytes(); len(metadata) > 0 {
+		event.Metadata = metadata
+	}
+
+	return event
+}
@@ -0,0 +1,86 @@
+package eventsgrpc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var (
+	protoMessage = regexp.MustCompile(`(?s)message (\w+) \{(.*?)\n\}`)
+	protoField   = regexp.MustCompile(`(repeated )?(\w+) (\w+) = (\d+);`)
+	protoRPC     = regexp.MustCompile(`rpc (\w+)\((stream )?(\w+)\) returns \((stream )?(\w+)\);`)
+)
+
+// describeField is the line of a field in events.proto
+func describeField(f protoreflect.FieldDescriptor) string {
+	kind := f.Kind().String()
+	if f.Kind() == protoreflect.MessageKind {
+		kind = string(f.Message().Name())
+	}
+
+	repeated := ""
+	if f.Cardinality() == protoreflect.Repeated {
+		repeated = "repeated "
+	}
+
+	return fmt.Sprintf("%s%s %s = %d;", repeated, kind, f.Name(), f.Number())
+}
+
+func Test_events_proto(t *testing.T) {
+	data, err := ioutil.ReadFile("events.proto")
+	if err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	if !strings.Contains(string(data), "package "+string(file.Package())+";") {
+		t.Errorf("events.proto must declare the package %s", file.Package())
+	}
+
+	proto := []string{}
+	for _, m := range protoMessage.FindAllStringSubmatch(string(data), -1) {
+		for _, f := range protoField.FindAllString(m[2], -1) {
+			proto = append(proto, m[1]+": "+f)
+		}
+	}
+
+	for _, r := range protoRPC.FindAllStringSubmatch(string(data), -1) {
+		proto = append(proto, fmt.Sprintf("rpc %s(%s%s) returns (%s%s)", r[1], r[2], r[3], r[4], r[5]))
+	}
+
+	runtime := []string{}
+	for i := 0; i < file.Messages().Len(); i++ {
+		m := file.Messages().Get(i)
+
+		for j := 0; j < m.Fields().Len(); j++ {
+			runtime = append(runtime, string(m.Name())+": "+describeField(m.Fields().Get(j)))
+		}
+	}
+
+	methods := file.Services().ByName("Events").Methods()
+	for i := 0; i < methods.Len(); i++ {
+		m := methods.Get(i)
+
+		input, output := "", ""
+		if m.IsStreamingClient() {
+			input = "stream "
+		}
+		if m.IsStreamingServer() {
+			output = "stream "
+		}
+
+		runtime = append(runtime, fmt.Sprintf("rpc %s(%s%s) returns (%s%s)", m.Name(), input, m.Input().Name(), output, m.Output().Name()))
+	}
+
+	sort.Strings(proto)
+	sort.Strings(runtime)
+
+	if strings.Join(proto, "\n") != strings.Join(runtime, "\n") {
+		t.Errorf("events.proto and the runtime descriptors differ\nevents.proto:\n%s\n\nruntime:\n%s", strings.Join(proto, "\n"), strings.Join(runtime, "\n"))
+	}
+}
@@ -0,0 +1,182 @@
+// Package eventsgrpc exposes a Mux as the gRPC service of events.proto and
+// provides its client. Payload and metadata travel as JSON bytes, so
+// handlers are the same as over HTTP.
+package eventsgrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+
+	events "github.com/GuiaBolso/Go-Events"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Full names of the methods of the Events service
+const (
+	SendMethod   = "/guiabolso.events.v1.Events/Send"
+	StreamMethod = "/guiabolso.events.v1.Events/Stream"
+	BatchMethod  = "/guiabolso.events.v1.Events/Batch"
+)
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "guiabolso.events.v1.Events",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Send", Handler: sendHandler},
+		{MethodName: "Batch", Handler: batchHandler},
+	},
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Stream",
+		Handler:       streamHandler,
+		ServerStreams: true,
+		ClientStreams: true,
+	}},
+	Metadata: "events.proto",
+}
+
+// Server - The Events service backed by a Mux. Batch needs the Mux to
+// serve the batch event, e.g. with events.BatchWithLimits.
+type Server struct {
+	Mux *events.Mux
+}
+
+// NewServer returns a Server of mux
+func NewServer(mux *events.Mux) *Server {
+	return &Server{Mux: mux}
+}
+
+// Register adds the Events service to a gRPC server
+func (s *Server) Register(registrar grpc.ServiceRegistrar) {
+	registrar.RegisterService(&serviceDesc, s)
+}
+
+// Send dispatches the event, error events are responses like over HTTP
+func (s *Server) Send(ctx context.Context, event events.Event) events.Event {
+	response, _ := s.Mux.Dispatch(ctx, event)
+	return response
+}
+
+// Batch dispatches the events as a batch event through the Mux, so its
+// middlewares and tracker see it. Rejected batches are InvalidArgument
+// errors, Muxes without the batch event Unimplemented.
+func (s *Server) Batch(ctx context.Context, request BatchRequest) ([]events.BatchResponse, error) {
+	items := make([]batchItem, 0, len(request.Events))
+	for _, event := range request.Events {
+		items = append(items, batchItem(event))
+	}
+
+	payload, err := json.Marshal(batchPayload{
+		Parallel: request.Parallel,
+		Policy:   request.Policy,
+		Events:   items,
+	})
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	response, err := s.Mux.Dispatch(ctx, events.Event{
+		Name:    "batch",
+		Version: 1,
+		ID:      events.RandomID(),
+		FlowID:  request.FlowID,
+		Payload: payload,
+	})
+
+	if errors.Is(err, events.ErrEventNotFound) {
+		return nil, status.Error(codes.Unimplemented, "the batch event is not served")
+	}
+
+	if response.Name == "error" {
+		message := struct {
+			Message string `json:"message"`
+		}{}
+		json.Unmarshal(response.Payload, &message)
+
+		return nil, status.Error(codes.InvalidArgument, message.Message)
+	}
+
+	responses := []events.BatchResponse{}
+	if err := json.Unmarshal(response.Payload, &responses); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return responses, nil
+}
+
+// batchItem - The JSON of a sub-event in the payload of a batch event
+type batchItem struct {
+	events.Event
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+type batchPayload struct {
+	Parallel bool               `json:"parallel"`
+	Policy   events.BatchPolicy `json:"policy,omitempty"`
+	Events   []batchItem        `json:"events"`
+}
+
+func unary(srv interface{}, ctx context.Context, in protoreflect.ProtoMessage, method string, interceptor grpc.UnaryServerInterceptor, handle func(context.Context, protoreflect.ProtoMessage) (interface{}, error)) (interface{}, error) {
+	if interceptor == nil {
+		return handle(ctx, in)
+	}
+
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: method}
+
+	return interceptor(ctx, in, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return handle(ctx, req.(protoreflect.ProtoMessage))
+	})
+}
+
+func sendHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := newEvent()
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+
+	return unary(srv, ctx, in, SendMethod, interceptor, func(ctx context.Context, req protoreflect.ProtoMessage) (interface{}, error) {
+		response := srv.(*Server).Send(ctx, fromProto(req.ProtoReflect()))
+		return toProto(response), nil
+	})
+}
+
+func batchHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := dynamicpb.NewMessage(batchRequestDesc)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+
+	return unary(srv, ctx, in, BatchMethod, interceptor, func(ctx context.Context, req protoreflect.ProtoMessage) (interface{}, error) {
+		responses, err := srv.(*Server).Batch(ctx, batchRequestFromProto(req.ProtoReflect()))
+		if err != nil {
+			return nil, err
+		}
+
+		return batchResponseToProto(responses), nil
+	})
+}
+
+func streamHandler(srv interface{}, stream grpc.ServerStream) error {
+	server := srv.(*Server)
+
+	for {
+		in := newEvent()
+
+		if err := stream.RecvMsg(in); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		response := server.Send(stream.Context(), fromProto(in))
+
+		if err := stream.SendMsg(toProto(response)); err != nil {
+			return err
+		}
+	}
+}
@@ -3,6 +3,8 @@ package events
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"html/template"
 	"net/http"
 	"sort"
@@ -10,6 +12,9 @@ import (
 	"github.com/alecthomas/jsonschema"
 )
 
+// ErrEventNotFound is returned by Mux.Dispatch for events without handler
+var ErrEventNotFound = errors.New("events: event not found")
+
 // Handler Interface for event handler
 type Handler interface {
 	Serve(context.Context, Event) (Event, error)
@@ -32,10 +37,27 @@ func (h HandlerFunc) Serve(ctx context.Context, event Event) (Event, error) {
 	return h(ctx, event)
 }
 
+// Middleware wraps the handlers of a Mux, see Mux.Use
+type Middleware func(Handler) Handler
+
 // Mux Events mux
 type Mux struct {
-	events map[eventKey]Handler
-	tracer HTTPTracker
+	events      map[eventKey]Handler
+	streams     map[eventKey]StreamHandler
+	middlewares []Middleware
+
+	// chains are the handlers and streams wrapped with the middlewares
+	chains       map[eventKey]Handler
+	streamChains map[eventKey]Handler
+
+	tracer   HTTPTracker
+	redactor *Redactor
+
+	playground         bool
+	playgroundEndpoint string
+
+	httpOptions HTTPOptions
+	codecs      map[string]Codec
 }
 
 type eventKey struct {
@@ -43,31 +65,138 @@ type eventKey struct {
 	Version int
 }
 
-// NewMux returns a new events Mux
+// NewMux returns a new events Mux. The events passed to tracer are
+// redacted by the Redactor of the Mux.
 func NewMuxWithTracker(tracer HTTPTracker) *Mux {
+	redactor := NewRedactor()
+
 	return &Mux{
-		events: map[eventKey]Handler{},
-		tracer: tracer,
+		events:  map[eventKey]Handler{},
+		streams: map[eventKey]StreamHandler{},
+
+		chains:       map[eventKey]Handler{},
+		streamChains: map[eventKey]Handler{},
+
+		tracer:   NewRedactingTracker(tracer, redactor),
+		redactor: redactor,
+
+		httpOptions: DefaultHTTPOptions,
+		codecs:      defaultCodecs(),
 	}
 }
 
 // NewMuxNoOpTracker returns a new events Mux
 func NewMux() *Mux {
-	return &Mux{
-		events: map[eventKey]Handler{},
-		tracer: NewNoOpTracker(),
-	}
+	return NewMuxWithTracker(NewNoOpTracker())
 }
 
-// Add adds a HandlerFunc into the Mux
+// Add adds a HandlerFunc into the Mux. The fields of the Input() of
+// EventDoc handlers tagged `events:"redact"` are redacted by the Redactor
+// of the Mux.
 func (m *Mux) Add(name string, version int, handler Handler) {
 	key := eventKey{name, version}
 	m.events[key] = handler
+	m.chains[key] = m.chain(handler)
+
+	var input interface{}
+	if eventWithDoc, ok := handler.(EventDoc); ok {
+		input = eventWithDoc.Input()
+	}
+
+	m.redactor.SetInput(name, version, input)
+}
+
+// Redactor returns the redaction rules of the events of the Mux
+func (m *Mux) Redactor() *Redactor {
+	return m.redactor
+}
+
+// Redact returns a copy of event with the fields of the Redactor rules
+// redacted, for trackers, logs and dead letters
+func (m *Mux) Redact(event Event) Event {
+	return m.redactor.Redact(event)
+}
+
+// Use wraps every handler of the Mux, including the ones already added,
+// with the middlewares. The first middleware is the outermost. Stream
+// handlers are wrapped too: the stream starts when the innermost handler
+// is reached, the responses of middlewares stopping before are written as
+// regular events.
+func (m *Mux) Use(middlewares ...Middleware) {
+	m.middlewares = append(m.middlewares, middlewares...)
+
+	for key, handler := range m.events {
+		m.chains[key] = m.chain(handler)
+	}
+
+	for key, handler := range m.streams {
+		m.streamChains[key] = m.chain(streamChainHandler(handler))
+	}
+}
+
+// chain wraps handler with the middlewares of the Mux
+func (m *Mux) chain(handler Handler) Handler {
+	for i := len(m.middlewares) - 1; i >= 0; i-- {
+		handler = m.middlewares[i](handler)
+	}
+
+	return handler
+}
+
+// EnablePlayground turns on the "try it" forms of ServeDoc. The forms POST
+// events to endpoint, which must be the path where this Mux is served.
+// It is disabled by default and should be kept off in production.
+func (m *Mux) EnablePlayground(endpoint string) {
+	m.playground = true
+	m.playgroundEndpoint = endpoint
+}
+
+// DisablePlayground removes the "try it" forms from ServeDoc
+func (m *Mux) DisablePlayground() {
+	m.playground = false
+	m.playgroundEndpoint = ""
+}
+
+// Dispatch serves event with its handler outside of HTTP, tracked when the
+// tracker is an EventTracker. Unknown events get the "Event not Found"
+// error event and an ErrEventNotFound error.
+func (m *Mux) Dispatch(ctx context.Context, event Event) (Event, error) {
+	handler, ok := m.get(event.Name, event.Version)
+	if !ok {
+		err := fmt.Errorf("%w: %q version %d", ErrEventNotFound, event.Name, event.Version)
+		m.tracer.NoticeEventError(ctx, event, err)
+
+		return NewError(event.FlowID, "Event not Found"), err
+	}
+
+	ctx, tracked := m.startEvent(ctx, event)
+	response, err := handler.Serve(ctx, event)
+
+	if tracked {
+		ctx = m.tracer.End(ctx, event, err)
+	}
+
+	if err != nil {
+		m.tracer.NoticeEventError(ctx, event, err)
+	}
+
+	return response, err
+}
+
+// startEvent starts tracking an event served outside of an HTTP request,
+// reporting whether the tracker is an EventTracker. Only then End must be
+// called.
+func (m *Mux) startEvent(ctx context.Context, event Event) (context.Context, bool) {
+	tracker, ok := m.tracer.(EventTracker)
+	if !ok {
+		return ctx, false
+	}
+
+	return tracker.StartEvent(ctx, event), true
 }
 
 func (m *Mux) get(name string, version int) (Handler, bool) {
-	key := eventKey{name, version}
-	h, ok := m.events[key]
+	h, ok := m.chains[eventKey{name, version}]
 	return h, ok
 }
 
@@ -76,18 +205,23 @@ func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 
-	event := Event{}
-
-	err := json.NewDecoder(r.Body).Decode(&event)
+	event, err := m.decodeRequest(w, r)
 
 	if err != nil {
 		ctx = m.tracer.NoticeError(ctx, err)
 
-		json.NewEncoder(w).Encode(
-			NewError(
-				"",
-				err.Error(),
-			))
+		if err := m.writeEvent(w, r, NewErrorWithCode(
+			"",
+			err.Error(),
+			err.(requestError).code,
+		)); err != nil {
+			m.tracer.NoticeError(ctx, err)
+		}
+		return
+	}
+
+	if stream, ok := m.streamChains[eventKey{event.Name, event.Version}]; ok {
+		m.serveStream(ctx, w, r, event, stream)
 		return
 	}
 
@@ -96,11 +230,12 @@ func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		ctx = m.tracer.NoticeEventError(ctx, event, err)
 
-		json.NewEncoder(w).Encode(
-			NewError(
-				event.FlowID,
-				"Event not Found",
-			))
+		if err := m.writeEvent(w, r, NewError(
+			event.FlowID,
+			"Event not Found",
+		)); err != nil {
+			m.tracer.NoticeError(ctx, err)
+		}
 		return
 	}
 
@@ -108,13 +243,8 @@ func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	response, err := handler.Serve(ctx, event)
 	ctx = m.tracer.End(ctx, event, err)
 
-	err = json.NewEncoder(w).Encode(response)
-
-	if err != nil {
+	if err := m.writeEvent(w, r, response); err != nil {
 		m.tracer.NoticeEventError(ctx, event, err)
-
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(http.StatusText(http.StatusInternalServerError)))
 	}
 }
 
@@ -125,10 +255,21 @@ type doc struct {
 	InputSchema  string
 	OutputSchema string
 
+	EncryptedFields []string
+
 	InputExample  string
 	OutputExample string
 
 	HaveExtendedDoc bool
+
+	RequestExample string
+}
+
+type docPage struct {
+	Events []doc
+
+	Playground bool
+	Endpoint   string
 }
 
 // ServeDoc - Serves all documentation
@@ -148,6 +289,7 @@ func (m *Mux) ServeDoc(w http.ResponseWriter, r *http.Request) {
 
 			input, _ := json.MarshalIndent(jsonschema.Reflect(eventWithDoc.Input()), "", "  ")
 			eventDoc.InputSchema = string(input)
+			eventDoc.EncryptedFields = EncryptedFields(eventWithDoc.Input())
 
 			output, _ := json.MarshalIndent(jsonschema.Reflect(eventWithDoc.Output()), "", "  ")
 			eventDoc.OutputSchema = string(output)
@@ -162,14 +304,49 @@ func (m *Mux) ServeDoc(w http.ResponseWriter, r *http.Request) {
 
 			eventDoc.DocString = template.HTML(eventWithDoc.Doc())
 		}
+
+		if m.playground {
+			eventDoc.RequestExample = playgroundRequest(key, handler)
+		}
+
 		docs = append(docs, eventDoc)
 	}
 
 	sort.Slice(docs, func(i, j int) bool {
+		if docs[i].Name == docs[j].Name {
+			return docs[i].Version < docs[j].Version
+		}
 		return docs[i].Name < docs[j].Name
 	})
 
-	tpl.Execute(w, docs)
+	tpl.Execute(w, docPage{
+		Events:     docs,
+		Playground: m.playground,
+		Endpoint:   m.playgroundEndpoint,
+	})
+}
+
+// playgroundRequest builds the event used to pre-fill the playground form
+func playgroundRequest(key eventKey, handler Handler) string {
+	payload := json.RawMessage("{}")
+
+	if eventWithDoc, ok := handler.(EventDoc); ok {
+		inputExample, _ := eventWithDoc.Example()
+
+		if b, err := json.Marshal(inputExample); err == nil {
+			payload = b
+		}
+	}
+
+	request, _ := json.MarshalIndent(Event{
+		Name:    key.Name,
+		Version: key.Version,
+		ID:      RandomID(),
+		FlowID:  RandomID(),
+		Payload: payload,
+	}, "", "  ")
+
+	return string(request)
 }
 
 var htmlTemplate = `
@@ -216,13 +393,19 @@ var htmlTemplate = `
             border: 1px solid #cfcfcf;
             border-radius: 3px;
         }
+
+        .playground textarea {
+            width: 100%;
+            min-height: 15em;
+            font-family: 'Inconsolata', 'Droid Sans Mono', 'Courier New', monospace;
+        }
     </style>
 </head>
 <body>
 <h1 class="title">Events</h1>
 <div>
     <ul>
-        {{ range . }}        
+        {{ range .Events }}
             {{ if .HaveExtendedDoc }}
                 <li><a href="#{{ .Name }}">{{ .Name }}</a></li>
             {{ else }}
@@ -230,7 +413,7 @@ var htmlTemplate = `
             {{ end }}
         {{ end }}
     </ul>
-    {{ range . }}
+    {{ range $i, $event := .Events }}
     <div class="event" id="{{ .Name }}">
         <div class="event__title">{{ .Name }} (Version: {{ .Version }})</div>
 
@@ -245,6 +428,16 @@ var htmlTemplate = `
 
         <pre>{{ .InputSchema }}</pre>
 
+        {{ if .EncryptedFields }}
+        <h2>Encrypted Fields</h2>
+
+        <ul>
+            {{ range .EncryptedFields }}
+            <li><code>{{ . }}</code></li>
+            {{ end }}
+        </ul>
+        {{ end }}
+
         <h2>Output Example</h2>
 
         <pre>{{ .OutputExample }}</pre>
@@ -253,8 +446,55 @@ var htmlTemplate = `
 
         <pre>{{ .OutputSchema }}</pre>
         {{ end }}
+
+        {{ if $.Playground }}
+        <div class="playground">
+            <h2>Try it</h2>
+
+            <form onsubmit="return sendEvent({{ $i }})">
+                <textarea id="request-{{ $i }}">{{ .RequestExample }}</textarea>
+                <button type="submit">Send</button>
+            </form>
+
+            <p id="timing-{{ $i }}"></p>
+            <pre id="response-{{ $i }}"></pre>
+        </div>
+        {{ end }}
     </div>
     {{ end }}
 </div>
+{{ if .Playground }}
+<script>
+    function sendEvent(i) {
+        var request = document.getElementById("request-" + i).value;
+        var timing = document.getElementById("timing-" + i);
+        var response = document.getElementById("response-" + i);
+        var start = performance.now();
+
+        timing.textContent = "Sending...";
+        response.textContent = "";
+
+        fetch({{ .Endpoint }}, {
+            method: "POST",
+            headers: {"Content-Type": "application/json"},
+            body: request
+        }).then(function (res) {
+            return res.text();
+        }).then(function (body) {
+            timing.textContent = "Response in " + (performance.now() - start).toFixed(1) + " ms";
+            try {
+                response.textContent = JSON.stringify(JSON.parse(body), null, 2);
+            } catch (e) {
+                response.textContent = body;
+            }
+        }).catch(function (err) {
+            timing.textContent = "Request failed after " + (performance.now() - start).toFixed(1) + " ms";
+            response.textContent = err.toString();
+        });
+
+        return false;
+    }
+</script>
+{{ end }}
 </body>
 </html>`
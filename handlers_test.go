@@ -250,6 +250,175 @@ func Test_ServeDoc(t *testing.T) {
 	}
 }
 
+func Test_ServeDoc_playground(t *testing.T) {
+	ev := &mockEventStruct{}
+
+	mux := NewMux()
+	mux.Add("TestEvent", 42, ev)
+	mux.Add("NoDocEvent", 1, HandlerFunc(mockHandlerFunc))
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/doc", nil)
+	mux.ServeDoc(w, r)
+
+	if strings.Contains(w.Body.String(), "sendEvent") {
+		t.Error("Playground must be disabled by default")
+	}
+
+	mux.EnablePlayground("/events/")
+
+	w = httptest.NewRecorder()
+	mux.ServeDoc(w, r)
+	bodyStr := w.Body.String()
+
+	if !strings.Contains(bodyStr, "sendEvent") {
+		t.Error("Could not find the playground form")
+	}
+
+	if !strings.Contains(bodyStr, "/events/") {
+		t.Error("Could not find the playground endpoint")
+	}
+
+	if !strings.Contains(bodyStr, "some string") {
+		t.Error("Playground request must be pre-filled with the input example")
+	}
+
+	mux.DisablePlayground()
+
+	w = httptest.NewRecorder()
+	mux.ServeDoc(w, r)
+
+	if strings.Contains(w.Body.String(), "sendEvent") {
+		t.Error("Playground must be disabled")
+	}
+}
+
+func Test_playgroundRequest(t *testing.T) {
+	request := Event{}
+	json.Unmarshal([]byte(playgroundRequest(eventKey{"TestEvent", 42}, &mockEventStruct{})), &request)
+
+	if request.Name != "TestEvent" || request.Version != 42 {
+		t.Errorf("request = %s v%d, wants: TestEvent v42", request.Name, request.Version)
+	}
+
+	input := mockEventStructInput{}
+	json.Unmarshal(request.Payload, &input)
+
+	if input.Field1 != "some string" {
+		t.Errorf(`input.Field1 == "%s", wants "some string"`, input.Field1)
+	}
+
+	json.Unmarshal([]byte(playgroundRequest(eventKey{"NoDoc", 1}, HandlerFunc(mockHandlerFunc))), &request)
+
+	if string(request.Payload) != "{}" {
+		t.Errorf(`request.Payload == %s, wants {}`, string(request.Payload))
+	}
+}
+
+func Test_ServeSchema(t *testing.T) {
+	mux := NewMux()
+
+	mux.Add("TestEvent", 42, &mockEventStruct{})
+	mux.Add("TestEvent", 1, &mockEventStruct{})
+	mux.Add("NoDocEvent", 1, HandlerFunc(mockHandlerFunc))
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/schema", nil)
+	mux.ServeSchema(w, r)
+
+	document := SchemaDocument{}
+	json.NewDecoder(w.Body).Decode(&document)
+
+	if len(document.Events) != 3 {
+		t.Fatalf("len(document.Events) == %d, wants: %d", len(document.Events), 3)
+	}
+
+	if document.Events[0].Name != "NoDocEvent" || document.Events[0].Input != nil {
+		t.Errorf("Expecting NoDocEvent without schemas, got: %#v", document.Events[0])
+	}
+
+	if document.Events[1].Version != 1 || document.Events[2].Version != 42 {
+		t.Error("Events must be sorted by name and version")
+	}
+
+	if document.Events[2].Doc != "Mock documentation" {
+		t.Errorf(`Doc == "%s", wants: "Mock documentation"`, document.Events[2].Doc)
+	}
+
+	if !strings.Contains(string(document.Events[2].Input), "someNumber") {
+		t.Error("Could not find the input schema")
+	}
+
+	if !strings.Contains(string(document.Events[2].OutputExample), "another string") {
+		t.Error("Could not find the output example")
+	}
+}
+
+func Test_Dispatch(t *testing.T) {
+	mux := NewMux()
+	mux.Add("echo", 1, HandlerFunc(mockHandlerFunc))
+
+	response, err := mux.Dispatch(context.Background(), Event{Name: "echo", Version: 1, FlowID: "flow", Payload: json.RawMessage(`"hi"`)})
+	if err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	if response.Name != "echo:response" || response.FlowID != "flow" || string(response.Payload) != `"hi"` {
+		t.Errorf("response == %+v, wants the echo:response of flow", response)
+	}
+
+	response, err = mux.Dispatch(context.Background(), Event{Name: "echo", Version: 2, FlowID: "flow"})
+	if err == nil {
+		t.Error("Error expected for an unknown event")
+	}
+
+	if response.Name != "error" || response.FlowID != "flow" {
+		t.Errorf("response.Name == %s, wants: error", response.Name)
+	}
+}
+
+func Test_Use(t *testing.T) {
+	mux := NewMux()
+	mux.Add("echo", 1, HandlerFunc(mockHandlerFunc))
+
+	order := []string{}
+	middleware := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return HandlerFunc(func(ctx context.Context, event Event) (Event, error) {
+				order = append(order, name)
+				return next.Serve(ctx, event)
+			})
+		}
+	}
+
+	mux.Use(middleware("first"), middleware("second"))
+
+	response, err := mux.Dispatch(context.Background(), Event{Name: "echo", Version: 1})
+	if err != nil || response.Name != "echo:response" {
+		t.Errorf("response.Name == %s, err == %v, wants: echo:response", response.Name, err)
+	}
+
+	if strings.Join(order, ",") != "first,second" {
+		t.Errorf("order == %v, wants: [first second]", order)
+	}
+
+	// Handlers are wrapped by Use and Add, not on every event
+	wrapped := 0
+	mux.Use(func(next Handler) Handler {
+		wrapped++
+		return next
+	})
+	mux.Add("other", 1, HandlerFunc(mockHandlerFunc))
+
+	for i := 0; i < 3; i++ {
+		mux.Dispatch(context.Background(), Event{Name: "echo", Version: 1})
+	}
+
+	if wrapped != 2 {
+		t.Errorf("wrapped == %d, wants: %d", wrapped, 2)
+	}
+}
+
 type mockEventStruct struct{}
 
 type mockEventStructInput struct {
@@ -0,0 +1,486 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// MetadataRequestID is the metadata field with the ID of the event a line
+// response answers
+const MetadataRequestID = "requestId"
+
+// ErrLineServerClosed is returned by the LineServer methods after Shutdown
+var ErrLineServerClosed = errors.New("events: line server closed")
+
+// LineServer serves a Mux over newline-delimited JSON, one event per line.
+// The events of a connection are dispatched concurrently and each response
+// is written as a line with the request ID in MetadataRequestID, in the
+// order they finish.
+type LineServer struct {
+	Mux *Mux
+
+	// MaxLineBytes is the size limit of a line, the connection is closed
+	// after a longer one
+	MaxLineBytes int
+
+	// MaxInFlight is the number of events of a connection served at once,
+	// the connection is not read while they run. Zero is unlimited.
+	MaxInFlight int
+
+	mu        sync.Mutex
+	closed    bool
+	done      chan struct{}
+	listeners map[net.Listener]struct{}
+	conns     sync.WaitGroup
+}
+
+// DefaultLineMaxInFlight is the MaxInFlight of NewLineServer
+const DefaultLineMaxInFlight = 64
+
+// NewLineServer returns a LineServer of mux accepting lines up to
+// DefaultHTTPOptions.MaxBodyBytes and serving DefaultLineMaxInFlight events
+// of a connection at once
+func NewLineServer(mux *Mux) *LineServer {
+	return &LineServer{
+		Mux:          mux,
+		MaxLineBytes: int(DefaultHTTPOptions.MaxBodyBytes),
+		MaxInFlight:  DefaultLineMaxInFlight,
+		done:         make(chan struct{}),
+		listeners:    map[net.Listener]struct{}{},
+	}
+}
+
+// ListenAndServeUnix serves the connections of a Unix domain socket at
+// path until Shutdown. A stale socket file at path is replaced.
+func (s *LineServer) ListenAndServeUnix(path string) error {
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(listener)
+}
+
+// Serve accepts connections on listener until Shutdown, each connection is
+// served by ServeConn. The listener is closed on return.
+func (s *LineServer) Serve(listener net.Listener) error {
+	if !s.track(listener) {
+		listener.Close()
+		return ErrLineServerClosed
+	}
+	defer s.untrack(listener)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrLineServerClosed
+			}
+
+			return err
+		}
+
+		go func() {
+			defer conn.Close()
+			s.ServeConn(context.Background(), conn, conn)
+		}()
+	}
+}
+
+// ServeStdio serves the events of the standard input, writing the
+// responses to the standard output
+func (s *LineServer) ServeStdio(ctx context.Context) error {
+	return s.ServeConn(ctx, os.Stdin, os.Stdout)
+}
+
+// ServeConn serves the events read from r, writing the responses to w,
+// until r ends, ctx ends or Shutdown. It returns after every event read has
+// been answered; ctx is the context of the handlers.
+func (s *LineServer) ServeConn(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrLineServerClosed
+	}
+	s.conns.Add(1)
+	s.mu.Unlock()
+	defer s.conns.Done()
+
+	lines := make(chan []byte)
+	stop := make(chan struct{})
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- s.read(r, lines, stop)
+	}()
+
+	var mu sync.Mutex
+	var inFlight sync.WaitGroup
+
+	write := func(event Event) {
+		data, _ := json.Marshal(event)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		w.Write(append(data, '\n'))
+	}
+
+	// slots holds a value per event in flight, lines are received only
+	// with a slot held
+	var slots chan struct{}
+	if s.MaxInFlight > 0 {
+		slots = make(chan struct{}, s.MaxInFlight)
+	}
+
+	held := slots == nil
+
+	var err error
+
+loop:
+	for {
+		var input <-chan []byte
+		var acquire chan<- struct{}
+
+		if held {
+			input = lines
+		} else {
+			acquire = slots
+		}
+
+		select {
+		case acquire <- struct{}{}:
+			held = true
+		case line := <-input:
+			inFlight.Add(1)
+			held = slots == nil
+
+			go func() {
+				defer inFlight.Done()
+				write(s.serveLine(ctx, line))
+
+				if slots != nil {
+					<-slots
+				}
+			}()
+		case err = <-errs:
+			if err == bufio.ErrTooLong {
+				write(NewErrorWithCode("", "Event too large", ErrorCodeBodyTooLarge))
+			}
+
+			break loop
+		case <-ctx.Done():
+			err = ctx.Err()
+			break loop
+		case <-s.done:
+			err = ErrLineServerClosed
+			break loop
+		}
+	}
+
+	close(stop)
+
+	if deadline, ok := r.(interface{ SetReadDeadline(time.Time) error }); ok {
+		deadline.SetReadDeadline(time.Now())
+	}
+
+	inFlight.Wait()
+
+	return err
+}
+
+func (s *LineServer) read(r io.Reader, lines chan<- []byte, stop <-chan struct{}) error {
+	scanner := newLineScanner(r, s.MaxLineBytes)
+
+	for scanner.Scan() {
+		line := append([]byte(nil), scanner.Bytes()...)
+		if len(line) == 0 {
+			continue
+		}
+
+		select {
+		case lines <- line:
+		case <-stop:
+			return nil
+		}
+	}
+
+	return scanner.Err()
+}
+
+func (s *LineServer) serveLine(ctx context.Context, line []byte) Event {
+	event := Event{}
+
+	if err := json.Unmarshal(line, &event); err != nil {
+		response := NewErrorWithCode("", "Invalid event", ErrorCodeMalformedEvent)
+
+		// The client waits for the response of the ID it sent
+		if id, ok := lineEventID(line); ok {
+			response = WithMetadataField(response, MetadataRequestID, id)
+		}
+
+		return response
+	}
+
+	response, _ := s.Mux.Dispatch(ctx, event)
+
+	return WithMetadataField(response, MetadataRequestID, event.ID)
+}
+
+// newLineScanner returns a Scanner of the lines of r up to maxBytes, the
+// bufio default when maxBytes < 1
+func newLineScanner(r io.Reader, maxBytes int) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+
+	if maxBytes > 0 {
+		size := 64 * 1024
+		if maxBytes < size {
+			size = maxBytes
+		}
+
+		scanner.Buffer(make([]byte, 0, size), maxBytes)
+	}
+
+	return scanner
+}
+
+// lineEventIDPattern matches the id field of a line that is not valid JSON
+var lineEventIDPattern = regexp.MustCompile(`"id"\s*:\s*("(?:[^"\\]|\\.)*")`)
+
+// lineEventID recovers the ID of a malformed event line
+func lineEventID(line []byte) (string, bool) {
+	probe := struct {
+		ID string `json:"id"`
+	}{}
+
+	// Type errors of other fields still decode the ID
+	json.Unmarshal(line, &probe)
+	if probe.ID != "" {
+		return probe.ID, true
+	}
+
+	match := lineEventIDPattern.FindSubmatch(line)
+	if match == nil {
+		return "", false
+	}
+
+	id := ""
+	if err := json.Unmarshal(match[1], &id); err != nil || id == "" {
+		return "", false
+	}
+
+	return id, true
+}
+
+// Shutdown stops accepting connections and reading events, then waits for
+// the events already read to be answered or ctx to end
+func (s *LineServer) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+
+		for listener := range s.listeners {
+			listener.Close()
+		}
+	}
+	s.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		s.conns.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *LineServer) track(listener net.Listener) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+
+	s.listeners[listener] = struct{}{}
+	return true
+}
+
+func (s *LineServer) untrack(listener net.Listener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.listeners, listener)
+	listener.Close()
+}
+
+func (s *LineServer) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closed
+}
+
+// LineClient sends events to a LineServer over a connection. Concurrent
+// calls to Send share the connection, responses are matched by the ID of
+// their event.
+type LineClient struct {
+	// MaxLineBytes is the size limit of a response line, the connection
+	// fails after a longer one. It must be set before the first Send.
+	MaxLineBytes int
+
+	conn io.ReadWriter
+
+	reading sync.Once
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[string]chan Event
+	closed  bool
+	err     error
+	done    chan struct{}
+}
+
+// DialUnix connects a LineClient to the Unix domain socket at path
+func DialUnix(path string) (*LineClient, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLineClient(conn), nil
+}
+
+// NewLineClient returns a LineClient reading the responses from conn and
+// writing the events to it, e.g. the pipes of a plugin process served by
+// ServeStdio
+func NewLineClient(conn io.ReadWriter) *LineClient {
+	return &LineClient{
+		MaxLineBytes: int(DefaultHTTPOptions.MaxBodyBytes),
+		conn:         conn,
+		pending:      map[string]chan Event{},
+		done:         make(chan struct{}),
+	}
+}
+
+// Send writes the event and waits for its response. Events without an ID
+// get a random one. Error events are returned as regular responses, only
+// connection failures are errors.
+func (c *LineClient) Send(ctx context.Context, event Event) (Event, error) {
+	if event.ID == "" {
+		event.ID = RandomID()
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return Event{}, err
+	}
+
+	c.reading.Do(func() {
+		go c.read()
+	})
+
+	response := make(chan Event, 1)
+
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return Event{}, c.err
+	}
+
+	if _, ok := c.pending[event.ID]; ok {
+		c.mu.Unlock()
+		return Event{}, fmt.Errorf("events: an event with ID %q is already pending", event.ID)
+	}
+
+	c.pending[event.ID] = response
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, event.ID)
+		c.mu.Unlock()
+	}()
+
+	c.writeMu.Lock()
+	_, err = c.conn.Write(append(data, '\n'))
+	c.writeMu.Unlock()
+
+	if err != nil {
+		return Event{}, err
+	}
+
+	select {
+	case event := <-response:
+		return event, nil
+	case <-c.done:
+		return Event{}, c.err
+	case <-ctx.Done():
+		return Event{}, ctx.Err()
+	}
+}
+
+func (c *LineClient) read() {
+	scanner := newLineScanner(c.conn, c.MaxLineBytes)
+
+	for scanner.Scan() {
+		response := Event{}
+		if err := json.Unmarshal(scanner.Bytes(), &response); err != nil {
+			continue
+		}
+
+		var id string
+		if !MetadataField(response, MetadataRequestID, &id) {
+			continue
+		}
+
+		c.mu.Lock()
+		if pending, ok := c.pending[id]; ok {
+			pending <- response
+			delete(c.pending, id)
+		}
+		c.mu.Unlock()
+	}
+
+	c.mu.Lock()
+	c.err = scanner.Err()
+	if c.err == nil || c.closed {
+		c.err = ErrTransportClosed
+	}
+	c.mu.Unlock()
+
+	close(c.done)
+}
+
+// Close closes the connection when it is an io.Closer, pending calls to
+// Send fail
+func (c *LineClient) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+
+	if closer, ok := c.conn.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,275 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_LineServer_ServeConn(t *testing.T) {
+	mux := NewMux()
+	mux.Add("echo", 1, HandlerFunc(mockHandlerFunc))
+
+	input := strings.Join([]string{
+		`{"name":"echo","version":1,"id":"first","payload":1}`,
+		``,
+		`not an event`,
+		`{"name":"unknown","version":1,"id":"second"}`,
+		`{"name":"echo","version":1,"id":"third","payload":3}`,
+		`{"name":"echo","version":"1","id":"fourth"}`,
+		`{"id":"fifth","name":"echo","version":1,"payload":{`,
+	}, "\n")
+
+	output := &bytes.Buffer{}
+	if err := NewLineServer(mux).ServeConn(context.Background(), strings.NewReader(input), output); err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	responses := map[string]Event{}
+	malformed := 0
+
+	scanner := bufio.NewScanner(output)
+	for scanner.Scan() {
+		response := Event{}
+		if err := json.Unmarshal(scanner.Bytes(), &response); err != nil {
+			t.Fatalf("Error not expected: \"%s\"", err)
+		}
+
+		var id string
+		if MetadataField(response, MetadataRequestID, &id) {
+			responses[id] = response
+		} else if response.Name == "error" {
+			malformed++
+		}
+	}
+
+	if len(responses) != 5 || malformed != 1 {
+		t.Fatalf("len(responses) == %d, malformed == %d, wants: 5, 1", len(responses), malformed)
+	}
+
+	// Malformed lines with an ID are answered to it
+	for _, id := range []string{"fourth", "fifth"} {
+		if !strings.Contains(string(responses[id].Payload), `"code":1`) {
+			t.Errorf("responses[%s].Payload == %s, wants code %d", id, responses[id].Payload, ErrorCodeMalformedEvent)
+		}
+	}
+
+	if responses["first"].Name != "echo:response" || string(responses["third"].Payload) != "3" {
+		t.Errorf("responses == %+v, wants the echo:response of first and third", responses)
+	}
+
+	if responses["second"].Name != "error" {
+		t.Errorf("responses[second].Name == %s, wants: error", responses["second"].Name)
+	}
+}
+
+func Test_LineServer_Unix(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	mux := NewMux()
+	mux.Add("echo", 1, HandlerFunc(mockHandlerFunc))
+	mux.Add("slow", 1, HandlerFunc(func(ctx context.Context, event Event) (Event, error) {
+		close(started)
+		<-release
+		return mockHandlerFunc(ctx, event)
+	}))
+
+	path := filepath.Join(t.TempDir(), "events.sock")
+	server := NewLineServer(mux)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- server.ListenAndServeUnix(path)
+	}()
+
+	var client *LineClient
+	var err error
+
+	for i := 0; i < 100; i++ {
+		if client, err = DialUnix(path); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	var slow Event
+	var slowErr error
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		slow, slowErr = client.Send(ctx, Event{Name: "slow", Version: 1, ID: "slow", Payload: json.RawMessage(`"slow"`)})
+	}()
+
+	<-started
+
+	// Responses are matched by ID, the fast event is not blocked by the slow one
+	for i := 0; i < 10; i++ {
+		payload, _ := json.Marshal(i)
+
+		response, err := client.Send(ctx, Event{Name: "echo", Version: 1, Payload: payload})
+		if err != nil {
+			t.Fatalf("Error not expected: \"%s\"", err)
+		}
+
+		if string(response.Payload) != string(payload) {
+			t.Errorf("response.Payload == %s, wants: %s", response.Payload, payload)
+		}
+	}
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- server.Shutdown(ctx)
+	}()
+
+	select {
+	case <-shutdown:
+		t.Fatal("Shutdown returned with an event in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	wg.Wait()
+
+	if slowErr != nil || string(slow.Payload) != `"slow"` {
+		t.Errorf("slow == %s, err == %v, wants: \"slow\"", slow.Payload, slowErr)
+	}
+
+	if err := <-shutdown; err != nil {
+		t.Errorf("Error not expected: \"%s\"", err)
+	}
+
+	if err := <-served; err != ErrLineServerClosed {
+		t.Errorf("err == %v, wants: %v", err, ErrLineServerClosed)
+	}
+
+	if _, err := client.Send(ctx, Event{Name: "echo", Version: 1}); err == nil {
+		t.Error("Error expected sending after Shutdown")
+	}
+}
+
+func Test_LineServer_MaxInFlight(t *testing.T) {
+	var mu sync.Mutex
+	running, peak := 0, 0
+	release := make(chan struct{})
+
+	mux := NewMux()
+	mux.Add("slow", 1, HandlerFunc(func(_ context.Context, event Event) (Event, error) {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+
+		<-release
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+
+		return NewResponse(event, nil)
+	}))
+
+	server := NewLineServer(mux)
+	server.MaxInFlight = 2
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	done := make(chan error)
+	go func() { done <- server.ServeConn(context.Background(), serverConn, serverConn) }()
+
+	// Writes block on the pipe once the server stops reading
+	written := make(chan int, 5)
+	go func() {
+		for i := 0; i < 5; i++ {
+			if _, err := clientConn.Write([]byte(`{"name":"slow","version":1,"id":"` + RandomID() + `"}` + "\n")); err != nil {
+				return
+			}
+			written <- i
+		}
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	if running != 2 {
+		t.Errorf("running == %d, wants: %d", running, 2)
+	}
+	mu.Unlock()
+
+	if len(written) > 4 {
+		t.Errorf("len(written) == %d, wants the server to stop reading", len(written))
+	}
+
+	go func() {
+		scanner := bufio.NewScanner(clientConn)
+		for scanner.Scan() {
+		}
+	}()
+
+	close(release)
+
+	for i := 0; i < 5; i++ {
+		select {
+		case <-written:
+		case <-time.After(time.Second):
+			t.Fatal("The server must read again when the events finish")
+		}
+	}
+
+	serverConn.Close()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if peak != 2 {
+		t.Errorf("peak == %d, wants: %d", peak, 2)
+	}
+}
+
+func Test_LineClient_MaxLineBytes(t *testing.T) {
+	mux := NewMux()
+	mux.Add("echo", 1, HandlerFunc(mockHandlerFunc))
+
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+
+	go NewLineServer(mux).ServeConn(context.Background(), serverConn, serverConn)
+
+	client := NewLineClient(clientConn)
+	client.MaxLineBytes = 256
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	response, err := client.Send(ctx, Event{Name: "echo", Version: 1, Payload: json.RawMessage(`"small"`)})
+	if err != nil || response.Name != "echo:response" {
+		t.Fatalf("response.Name == %s, err == %v, wants: echo:response, nil", response.Name, err)
+	}
+
+	large, _ := json.Marshal(strings.Repeat("x", 1024))
+
+	if _, err := client.Send(ctx, Event{Name: "echo", Version: 1, Payload: large}); err != bufio.ErrTooLong {
+		t.Errorf("err == %v, wants: %v", err, bufio.ErrTooLong)
+	}
+}
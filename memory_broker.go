@@ -0,0 +1,206 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryBroker - An in-memory Transport, meant for tests and for wiring
+// consumers inside a single process
+type MemoryBroker struct {
+	mu     sync.Mutex
+	topics map[string]*memoryTopic
+	closed bool
+}
+
+type memoryTopic struct {
+	pending []Event
+	notify  chan struct{}
+}
+
+// NewMemoryBroker returns an empty MemoryBroker
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{topics: map[string]*memoryTopic{}}
+}
+
+func (b *MemoryBroker) topic(name string) *memoryTopic {
+	t, ok := b.topics[name]
+	if !ok {
+		t = &memoryTopic{notify: make(chan struct{})}
+		b.topics[name] = t
+	}
+	return t
+}
+
+// push adds events to a topic and wakes up its receivers. Must be called
+// with the lock held.
+func (b *MemoryBroker) push(name string, front bool, events ...Event) {
+	t := b.topic(name)
+
+	if front {
+		t.pending = append(append([]Event{}, events...), t.pending...)
+	} else {
+		t.pending = append(t.pending, events...)
+	}
+
+	close(t.notify)
+	t.notify = make(chan struct{})
+}
+
+// Publish adds the event to the end of topic
+func (b *MemoryBroker) Publish(ctx context.Context, topic string, event Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrTransportClosed
+	}
+
+	b.push(topic, false, event)
+
+	return nil
+}
+
+// Pending returns the events of topic waiting for a subscriber
+func (b *MemoryBroker) Pending(topic string) []Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]Event{}, b.topic(topic).pending...)
+}
+
+// Subscribe returns a subscription competing for the events of topic
+func (b *MemoryBroker) Subscribe(ctx context.Context, topic string) (Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrTransportClosed
+	}
+
+	return &memorySubscription{
+		broker:   b,
+		topic:    topic,
+		inFlight: map[*memoryDelivery]bool{},
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Close closes the broker, pending events are discarded
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil
+	}
+
+	b.closed = true
+
+	for _, t := range b.topics {
+		close(t.notify)
+		t.notify = make(chan struct{})
+	}
+
+	return nil
+}
+
+type memorySubscription struct {
+	broker   *MemoryBroker
+	topic    string
+	inFlight map[*memoryDelivery]bool
+	closed   bool
+	done     chan struct{}
+}
+
+func (s *memorySubscription) Receive(ctx context.Context) (Delivery, error) {
+	for {
+		s.broker.mu.Lock()
+
+		if s.closed || s.broker.closed {
+			s.broker.mu.Unlock()
+			return nil, ErrTransportClosed
+		}
+
+		t := s.broker.topic(s.topic)
+
+		if len(t.pending) > 0 {
+			event := t.pending[0]
+			t.pending = t.pending[1:]
+
+			delivery := &memoryDelivery{subscription: s, event: event}
+			s.inFlight[delivery] = true
+
+			s.broker.mu.Unlock()
+			return delivery, nil
+		}
+
+		notify := t.notify
+		s.broker.mu.Unlock()
+
+		select {
+		case <-notify:
+		case <-s.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (s *memorySubscription) Close() error {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+
+	s.closed = true
+	close(s.done)
+
+	for delivery := range s.inFlight {
+		delete(s.inFlight, delivery)
+		if !s.broker.closed {
+			s.broker.push(s.topic, true, delivery.event)
+		}
+	}
+
+	return nil
+}
+
+type memoryDelivery struct {
+	subscription *memorySubscription
+	event        Event
+}
+
+func (d *memoryDelivery) Event() Event {
+	return d.event
+}
+
+func (d *memoryDelivery) Ack() error {
+	return d.settle(false)
+}
+
+func (d *memoryDelivery) Nack() error {
+	return d.settle(true)
+}
+
+func (d *memoryDelivery) settle(requeue bool) error {
+	s := d.subscription
+
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+
+	if !s.inFlight[d] {
+		// Already settled, or returned to the topic by Close
+		return nil
+	}
+
+	delete(s.inFlight, d)
+
+	if requeue && !s.broker.closed {
+		s.broker.push(s.topic, true, d.event)
+	}
+
+	return nil
+}
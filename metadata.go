@@ -0,0 +1,59 @@
+package events
+
+import "encoding/json"
+
+// MetadataField reads the field key of the event metadata into v. It
+// returns false when the metadata is not an object or the field is missing
+func MetadataField(event Event, key string, v interface{}) bool {
+	fields := map[string]json.RawMessage{}
+
+	if err := json.Unmarshal(event.Metadata, &fields); err != nil {
+		return false
+	}
+
+	raw, ok := fields[key]
+	if !ok {
+		return false
+	}
+
+	return json.Unmarshal(raw, v) == nil
+}
+
+// WithMetadataField returns a copy of event with the field key of its
+// metadata set to value. Metadata that is not an object is replaced.
+func WithMetadataField(event Event, key string, value interface{}) Event {
+	fields := map[string]json.RawMessage{}
+	json.Unmarshal(event.Metadata, &fields)
+
+	if fields == nil {
+		fields = map[string]json.RawMessage{}
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return event
+	}
+
+	fields[key] = raw
+	event.Metadata, _ = json.Marshal(fields)
+
+	return event
+}
+
+// withoutMetadataField returns a copy of event without the field key in
+// its metadata
+func withoutMetadataField(event Event, key string) Event {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(event.Metadata, &fields); err != nil {
+		return event
+	}
+
+	if _, ok := fields[key]; !ok {
+		return event
+	}
+
+	delete(fields, key)
+	event.Metadata, _ = json.Marshal(fields)
+
+	return event
+}
@@ -0,0 +1,222 @@
+// Package outbox implements the transactional outbox: handlers add events to
+// the outbox table in the same database/sql transaction of their writes and
+// a Relay publishes them, so events are not lost when the process crashes
+// between the commit and the publication.
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	events "github.com/GuiaBolso/Go-Events"
+)
+
+// Dialect - The SQL differences between databases
+type Dialect struct {
+	// Placeholder returns the bind parameter number i, starting at 1
+	Placeholder func(i int) string
+	// CreateTable is the statement creating the table, %s is its name
+	CreateTable string
+}
+
+// SQLite dialect
+var SQLite = Dialect{
+	Placeholder: func(int) string { return "?" },
+	CreateTable: `CREATE TABLE IF NOT EXISTS %s (
+	position     INTEGER PRIMARY KEY AUTOINCREMENT,
+	event_id     TEXT NOT NULL,
+	flow_id      TEXT NOT NULL,
+	event        TEXT NOT NULL,
+	created_at   INTEGER NOT NULL,
+	attempts     INTEGER NOT NULL DEFAULT 0,
+	last_error   TEXT,
+	published_at INTEGER
+)`,
+}
+
+// Postgres dialect
+var Postgres = Dialect{
+	Placeholder: func(i int) string { return fmt.Sprintf("$%d", i) },
+	CreateTable: `CREATE TABLE IF NOT EXISTS %s (
+	position     BIGSERIAL PRIMARY KEY,
+	event_id     TEXT NOT NULL,
+	flow_id      TEXT NOT NULL,
+	event        TEXT NOT NULL,
+	created_at   BIGINT NOT NULL,
+	attempts     INTEGER NOT NULL DEFAULT 0,
+	last_error   TEXT,
+	published_at BIGINT
+)`,
+}
+
+// Outbox - The table of events waiting to be published
+type Outbox struct {
+	db      *sql.DB
+	table   string
+	dialect Dialect
+}
+
+// Entry - A row of the outbox
+type Entry struct {
+	Position    int64
+	Event       events.Event
+	CreatedAt   time.Time
+	Attempts    int
+	LastError   string
+	PublishedAt *time.Time
+}
+
+// New returns the Outbox stored in table
+func New(db *sql.DB, table string, dialect Dialect) *Outbox {
+	return &Outbox{db: db, table: table, dialect: dialect}
+}
+
+// CreateTable creates the outbox table when it does not exist
+func (o *Outbox) CreateTable(ctx context.Context) error {
+	_, err := o.db.ExecContext(ctx, fmt.Sprintf(o.dialect.CreateTable, o.table))
+	return err
+}
+
+// sql returns the statement with the table name and the dialect placeholders
+func (o *Outbox) sql(format string) string {
+	statement := fmt.Sprintf(format, o.table)
+
+	var b strings.Builder
+	n := 0
+
+	for _, r := range statement {
+		if r == '?' {
+			n++
+			b.WriteString(o.dialect.Placeholder(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// Add inserts the event in the outbox as part of tx. The event is published
+// by the Relay only if tx commits.
+func (o *Outbox) Add(ctx context.Context, tx *sql.Tx, event events.Event) error {
+	if event.ID == "" {
+		event.ID = events.RandomID()
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		o.sql("INSERT INTO %s (event_id, flow_id, event, created_at) VALUES (?, ?, ?, ?)"),
+		event.ID, event.FlowID, string(data), time.Now().UnixNano(),
+	)
+
+	return err
+}
+
+// Pending returns up to limit unpublished entries in insertion order
+func (o *Outbox) Pending(ctx context.Context, limit int) ([]Entry, error) {
+	return o.query(ctx,
+		"SELECT position, event, created_at, attempts, last_error FROM %s WHERE published_at IS NULL ORDER BY position LIMIT ?",
+		limit,
+	)
+}
+
+// pending returns up to limit unpublished entries with less than
+// maxAttempts attempts, every unpublished entry when maxAttempts < 1
+func (o *Outbox) pending(ctx context.Context, limit, maxAttempts int) ([]Entry, error) {
+	if maxAttempts < 1 {
+		return o.Pending(ctx, limit)
+	}
+
+	return o.query(ctx,
+		"SELECT position, event, created_at, attempts, last_error FROM %s WHERE published_at IS NULL AND attempts < ? ORDER BY position LIMIT ?",
+		maxAttempts, limit,
+	)
+}
+
+// Failed returns the unpublished entries with at least attempts attempts,
+// the ones a Relay with that MaxAttempts gave up on, in insertion order
+func (o *Outbox) Failed(ctx context.Context, attempts int) ([]Entry, error) {
+	return o.query(ctx,
+		"SELECT position, event, created_at, attempts, last_error FROM %s WHERE published_at IS NULL AND attempts >= ? ORDER BY position",
+		attempts,
+	)
+}
+
+// Retry resets the attempts of the unpublished entry at position, so a
+// Relay publishes it again
+func (o *Outbox) Retry(ctx context.Context, position int64) error {
+	_, err := o.db.ExecContext(ctx,
+		o.sql("UPDATE %s SET attempts = 0 WHERE position = ? AND published_at IS NULL"),
+		position,
+	)
+	return err
+}
+
+func (o *Outbox) query(ctx context.Context, query string, args ...interface{}) ([]Entry, error) {
+	rows, err := o.db.QueryContext(ctx, o.sql(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+
+	for rows.Next() {
+		var entry Entry
+		var data string
+		var createdAt int64
+		var lastError sql.NullString
+
+		if err := rows.Scan(&entry.Position, &data, &createdAt, &entry.Attempts, &lastError); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal([]byte(data), &entry.Event); err != nil {
+			return nil, fmt.Errorf("outbox entry %d: %s", entry.Position, err)
+		}
+
+		entry.CreatedAt = time.Unix(0, createdAt)
+		entry.LastError = lastError.String
+
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func (o *Outbox) markPublished(ctx context.Context, position int64) error {
+	_, err := o.db.ExecContext(ctx,
+		o.sql("UPDATE %s SET published_at = ?, attempts = attempts + 1, last_error = NULL WHERE position = ?"),
+		time.Now().UnixNano(), position,
+	)
+	return err
+}
+
+func (o *Outbox) markFailed(ctx context.Context, position int64, publishErr error) error {
+	_, err := o.db.ExecContext(ctx,
+		o.sql("UPDATE %s SET attempts = attempts + 1, last_error = ? WHERE position = ?"),
+		publishErr.Error(), position,
+	)
+	return err
+}
+
+// Cleanup deletes the entries published before t
+func (o *Outbox) Cleanup(ctx context.Context, t time.Time) (int64, error) {
+	result, err := o.db.ExecContext(ctx,
+		o.sql("DELETE FROM %s WHERE published_at IS NOT NULL AND published_at < ?"),
+		t.UnixNano(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,259 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	events "github.com/GuiaBolso/Go-Events"
+	_ "modernc.org/sqlite"
+)
+
+func newOutbox(t *testing.T) (*sql.DB, *Outbox) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "outbox.db"))
+	if err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+	t.Cleanup(func() { db.Close() })
+
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec("CREATE TABLE users (name TEXT NOT NULL)"); err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+
+	o := New(db, "outbox", SQLite)
+
+	if err := o.CreateTable(context.Background()); err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+
+	return db, o
+}
+
+func createUser(t *testing.T, db *sql.DB, o *Outbox, name, flowID string, commit bool) {
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+
+	tx.ExecContext(ctx, "INSERT INTO users (name) VALUES (?)", name)
+
+	err = o.Add(ctx, tx, events.Event{
+		Name:    "user.created",
+		Version: 1,
+		FlowID:  flowID,
+		Payload: []byte(`{"name":"` + name + `"}`),
+	})
+	if err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+
+	if commit {
+		tx.Commit()
+	} else {
+		tx.Rollback()
+	}
+}
+
+type mockPublisher struct {
+	mu        sync.Mutex
+	published []events.Event
+	fail      map[string]bool
+}
+
+func (p *mockPublisher) Publish(_ context.Context, event events.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.fail[string(event.Payload)] {
+		return errors.New("broker unavailable")
+	}
+
+	p.published = append(p.published, event)
+	return nil
+}
+
+func Test_Outbox_transaction(t *testing.T) {
+	db, o := newOutbox(t)
+
+	createUser(t, db, o, "committed", "flow-1", true)
+	createUser(t, db, o, "rolled back", "flow-2", false)
+
+	pending, err := o.Pending(context.Background(), 10)
+
+	if err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+
+	if len(pending) != 1 || pending[0].Event.FlowID != "flow-1" {
+		t.Fatalf("Only committed events must be pending, got: %#v", pending)
+	}
+
+	if pending[0].Event.ID == "" {
+		t.Error("Events without ID must get one")
+	}
+}
+
+func Test_Relay_Flush(t *testing.T) {
+	db, o := newOutbox(t)
+
+	createUser(t, db, o, "a1", "flow-a", true)
+	createUser(t, db, o, "b1", "flow-b", true)
+	createUser(t, db, o, "a2", "flow-a", true)
+	createUser(t, db, o, "b2", "flow-b", true)
+
+	publisher := &mockPublisher{fail: map[string]bool{`{"name":"a1"}`: true}}
+	errs := []error{}
+
+	relay := NewRelay(o, publisher)
+	relay.OnError = func(err error) { errs = append(errs, err) }
+
+	published, err := relay.Flush(context.Background())
+
+	if err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+
+	if published != 2 || len(errs) != 1 {
+		t.Errorf("published == %d, len(errs) == %d, wants: 2 and 1", published, len(errs))
+	}
+
+	pending, _ := o.Pending(context.Background(), 10)
+
+	if len(pending) != 2 || string(pending[0].Event.Payload) != `{"name":"a1"}` {
+		t.Fatalf("a2 must wait for a1, pending: %#v", pending)
+	}
+
+	if pending[0].Attempts != 1 || pending[0].LastError != "broker unavailable" {
+		t.Errorf("Unexpected failed entry: %#v", pending[0])
+	}
+
+	publisher.fail = nil
+	relay.Flush(context.Background())
+
+	order := []string{}
+	for _, event := range publisher.published {
+		order = append(order, string(event.Payload))
+	}
+
+	expected := []string{`{"name":"b1"}`, `{"name":"b2"}`, `{"name":"a1"}`, `{"name":"a2"}`}
+
+	if len(order) != len(expected) {
+		t.Fatalf("order == %v, wants: %v", order, expected)
+	}
+
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Errorf("order == %v, wants: %v", order, expected)
+			break
+		}
+	}
+
+	removed, err := o.Cleanup(context.Background(), time.Now().Add(time.Second))
+
+	if err != nil || removed != 4 {
+		t.Errorf("Cleanup removed %d entries (%v), wants: 4", removed, err)
+	}
+}
+
+func Test_Relay_Run(t *testing.T) {
+	db, o := newOutbox(t)
+	broker := events.NewMemoryBroker()
+
+	relay := NewRelay(o, TransportPublisher(broker, "users"))
+	relay.Interval = 5 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- relay.Run(ctx) }()
+
+	createUser(t, db, o, "john", "flow-1", true)
+
+	for i := 0; i < 100 && len(broker.Pending("users")) == 0; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel()
+
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Expecting context.Canceled, got: %v", err)
+	}
+
+	if pending := broker.Pending("users"); len(pending) != 1 || pending[0].FlowID != "flow-1" {
+		t.Errorf("Expecting the event in the broker, got: %#v", pending)
+	}
+}
+
+func Test_Relay_MaxAttempts(t *testing.T) {
+	db, o := newOutbox(t)
+
+	createUser(t, db, o, "poison", "flow-a", true)
+	createUser(t, db, o, "a2", "flow-a", true)
+	createUser(t, db, o, "b1", "flow-b", true)
+
+	publisher := &mockPublisher{fail: map[string]bool{`{"name":"poison"}`: true}}
+
+	relay := NewRelay(o, publisher)
+	relay.BatchSize = 1
+	relay.MaxAttempts = 2
+
+	for i := 0; i < 5; i++ {
+		relay.Flush(context.Background())
+	}
+
+	if len(publisher.published) != 2 {
+		t.Fatalf("len(published) == %d, wants: %d", len(publisher.published), 2)
+	}
+
+	failed, err := o.Failed(context.Background(), relay.MaxAttempts)
+	if err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+
+	if len(failed) != 1 || failed[0].Attempts != 2 || string(failed[0].Event.Payload) != `{"name":"poison"}` {
+		t.Fatalf("Unexpected failed entries: %#v", failed)
+	}
+
+	publisher.fail = nil
+
+	if err := o.Retry(context.Background(), failed[0].Position); err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+
+	relay.Flush(context.Background())
+
+	if pending, _ := o.Pending(context.Background(), 10); len(pending) != 0 {
+		t.Errorf("len(pending) == %d, wants: %d", len(pending), 0)
+	}
+}
+
+func Test_Relay_Run_defaults(t *testing.T) {
+	db, _ := newOutbox(t)
+
+	// Neither Interval nor OnError set, and a table that does not exist
+	relay := &Relay{Outbox: New(db, "missing", SQLite), Publisher: &mockPublisher{}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := relay.Run(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expecting context.DeadlineExceeded, got: %v", err)
+	}
+}
+
+func Test_Dialect_placeholders(t *testing.T) {
+	o := New(nil, "outbox", Postgres)
+
+	statement := o.sql("UPDATE %s SET a = ? WHERE b = ?")
+
+	if statement != "UPDATE outbox SET a = $1 WHERE b = $2" {
+		t.Errorf(`statement == "%s"`, statement)
+	}
+}
@@ -0,0 +1,129 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	events "github.com/GuiaBolso/Go-Events"
+)
+
+// Publisher sends the events of the outbox
+type Publisher interface {
+	Publish(context.Context, events.Event) error
+}
+
+// PublisherFunc a function implementing Publisher
+type PublisherFunc func(context.Context, events.Event) error
+
+// Publish implements Publisher
+func (f PublisherFunc) Publish(ctx context.Context, event events.Event) error {
+	return f(ctx, event)
+}
+
+// TransportPublisher publishes the events to topic of transport
+func TransportPublisher(transport events.Transport, topic string) Publisher {
+	return PublisherFunc(func(ctx context.Context, event events.Event) error {
+		return transport.Publish(ctx, topic, event)
+	})
+}
+
+// Relay publishes the pending events of an Outbox. Delivery is at least
+// once: an event published right before a crash is published again. Events
+// of the same flowId are published in insertion order, a failure holds the
+// following events of its flow until the next poll. Entries failing
+// MaxAttempts times are skipped, releasing their flow, and left in the
+// outbox for Outbox.Failed and Outbox.Retry. Only one Relay must run per
+// outbox table.
+type Relay struct {
+	Outbox    *Outbox
+	Publisher Publisher
+
+	Interval  time.Duration
+	BatchSize int
+
+	// MaxAttempts is the number of publications of an entry before it is
+	// skipped, values below 1 retry forever
+	MaxAttempts int
+
+	// OnError is called with the publication and database errors
+	OnError func(error)
+}
+
+// NewRelay returns a Relay polling every second in batches of 100, giving
+// up on entries after 10 attempts
+func NewRelay(outbox *Outbox, publisher Publisher) *Relay {
+	return &Relay{
+		Outbox:      outbox,
+		Publisher:   publisher,
+		Interval:    time.Second,
+		BatchSize:   100,
+		MaxAttempts: 10,
+		OnError:     func(error) {},
+	}
+}
+
+// Run publishes pending events until ctx ends, polling every second when
+// Interval is not set
+func (r *Relay) Run(ctx context.Context) error {
+	interval := r.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.Flush(ctx); err != nil && ctx.Err() == nil && r.OnError != nil {
+			r.OnError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush publishes one batch of pending events and returns how many were
+// published
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	entries, err := r.Outbox.pending(ctx, r.BatchSize, r.MaxAttempts)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	blocked := map[string]bool{}
+
+	for _, entry := range entries {
+		flowID := entry.Event.FlowID
+
+		if flowID != "" && blocked[flowID] {
+			continue
+		}
+
+		if err := r.Publisher.Publish(ctx, entry.Event); err != nil {
+			if flowID != "" && (r.MaxAttempts < 1 || entry.Attempts+1 < r.MaxAttempts) {
+				blocked[flowID] = true
+			}
+
+			if r.OnError != nil {
+				r.OnError(err)
+			}
+
+			if err := r.Outbox.markFailed(ctx, entry.Position, err); err != nil {
+				return published, err
+			}
+			continue
+		}
+
+		if err := r.Outbox.markPublished(ctx, entry.Position); err != nil {
+			return published, err
+		}
+		published++
+	}
+
+	return published, nil
+}
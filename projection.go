@@ -0,0 +1,289 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// CheckpointStore - Where projections keep the position of the last event
+// they processed
+type CheckpointStore interface {
+	Load(ctx context.Context, name string) (int64, error)
+	Save(ctx context.Context, name string, position int64) error
+}
+
+// MemoryCheckpointStore - A CheckpointStore kept in memory
+type MemoryCheckpointStore struct {
+	mu          sync.Mutex
+	checkpoints map[string]int64
+}
+
+// NewMemoryCheckpointStore returns an empty MemoryCheckpointStore
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{checkpoints: map[string]int64{}}
+}
+
+// Load implements CheckpointStore
+func (s *MemoryCheckpointStore) Load(_ context.Context, name string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.checkpoints[name], nil
+}
+
+// Save implements CheckpointStore
+func (s *MemoryCheckpointStore) Save(_ context.Context, name string, position int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.checkpoints[name] = position
+	return nil
+}
+
+// Projection updates a read model with the events of an EventStore. Events
+// are dispatched to the handlers added for their name and version, the
+// others are skipped. A Projection is also a Handler, so it can be
+// subscribed to a Bus, in that case no checkpoint is kept.
+type Projection struct {
+	Name        string
+	Store       EventStore
+	Checkpoints CheckpointStore
+
+	// Reset clears the read model before a rebuild
+	Reset func(context.Context) error
+
+	handlers map[eventKey]Handler
+
+	// applying is held while an event is processed by Run
+	applying sync.Mutex
+
+	mu       sync.Mutex
+	position int64
+	paused   bool
+	resume   chan struct{}
+	restart  context.CancelFunc
+}
+
+// NewProjection returns a Projection named name, the name identifies its
+// checkpoint
+func NewProjection(name string, store EventStore, checkpoints CheckpointStore) *Projection {
+	return &Projection{
+		Name:        name,
+		Store:       store,
+		Checkpoints: checkpoints,
+		Reset:       func(context.Context) error { return nil },
+		handlers:    map[eventKey]Handler{},
+		resume:      make(chan struct{}),
+	}
+}
+
+// Add adds the handler of an event into the Projection. Handlers of a Mux
+// can be reused, their responses are ignored.
+func (p *Projection) Add(name string, version int, handler Handler) {
+	key := eventKey{name, version}
+	p.handlers[key] = handler
+}
+
+// Serve implements Handler, dispatching event to the projection handlers
+func (p *Projection) Serve(ctx context.Context, event Event) (Event, error) {
+	handler, ok := p.handlers[eventKey{event.Name, event.Version}]
+	if !ok {
+		return Event{}, nil
+	}
+
+	return handler.Serve(ctx, event)
+}
+
+// Run processes the events after the checkpoint, and then the new ones,
+// until ctx ends or a handler fails
+func (p *Projection) Run(ctx context.Context) error {
+	for {
+		if err := p.waitResume(ctx); err != nil {
+			return err
+		}
+
+		runCtx, cancel := context.WithCancel(ctx)
+
+		position, paused, err := p.start(ctx, cancel)
+		if err != nil {
+			cancel()
+			return err
+		}
+
+		if paused {
+			// Paused while loading the checkpoint
+			cancel()
+			continue
+		}
+
+		err = p.Store.SubscribeAll(runCtx, position, func(recorded RecordedEvent) error {
+			return p.apply(runCtx, recorded)
+		})
+		interrupted := runCtx.Err() != nil
+		cancel()
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if !interrupted {
+			return err
+		}
+		// Interrupted by Pause or Rebuild
+	}
+}
+
+// start loads the checkpoint and installs the cancel func of the
+// subscription while holding the lock of Rebuild, so the checkpoint cannot
+// be reset in between. It reports whether the Projection was paused.
+func (p *Projection) start(ctx context.Context, cancel context.CancelFunc) (int64, bool, error) {
+	p.applying.Lock()
+	defer p.applying.Unlock()
+
+	position, err := p.Checkpoints.Load(ctx, p.Name)
+	if err != nil {
+		return 0, false, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.paused {
+		return 0, true, nil
+	}
+
+	p.position = position
+	p.restart = cancel
+
+	return position, false, nil
+}
+
+func (p *Projection) apply(ctx context.Context, recorded RecordedEvent) error {
+	p.applying.Lock()
+	defer p.applying.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if _, err := p.Serve(ctx, recorded.Event); err != nil {
+		return err
+	}
+
+	if err := p.Checkpoints.Save(ctx, p.Name, recorded.Position); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.position = recorded.Position
+	p.mu.Unlock()
+
+	return nil
+}
+
+func (p *Projection) waitResume(ctx context.Context) error {
+	for {
+		p.mu.Lock()
+		paused, resume := p.paused, p.resume
+		p.mu.Unlock()
+
+		if !paused {
+			return nil
+		}
+
+		select {
+		case <-resume:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Pause stops processing events until Resume
+func (p *Projection) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.paused = true
+
+	if p.restart != nil {
+		p.restart()
+	}
+}
+
+// Resume continues processing events from the checkpoint
+func (p *Projection) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.paused {
+		return
+	}
+
+	p.paused = false
+	close(p.resume)
+	p.resume = make(chan struct{})
+}
+
+// Paused reports whether the Projection is paused
+func (p *Projection) Paused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.paused
+}
+
+// Rebuild clears the read model with Reset and processes every event again
+// from the beginning of the store
+func (p *Projection) Rebuild(ctx context.Context) error {
+	p.mu.Lock()
+	wasPaused := p.paused
+	p.mu.Unlock()
+
+	p.Pause()
+
+	// Waits for the event being processed by Run
+	p.applying.Lock()
+	defer p.applying.Unlock()
+
+	if err := p.Reset(ctx); err != nil {
+		return err
+	}
+
+	if err := p.Checkpoints.Save(ctx, p.Name, 0); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.position = 0
+	p.mu.Unlock()
+
+	if !wasPaused {
+		p.Resume()
+	}
+
+	return nil
+}
+
+// Position returns the position of the last processed event
+func (p *Projection) Position() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.position
+}
+
+// Lag returns how many events of the store were not processed yet
+func (p *Projection) Lag(ctx context.Context) (int64, error) {
+	last, err := p.Store.LastPosition(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	position, err := p.Checkpoints.Load(ctx, p.Name)
+	if err != nil {
+		return 0, err
+	}
+
+	return last - position, nil
+}
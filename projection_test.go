@@ -0,0 +1,209 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockReadModel struct {
+	mu    sync.Mutex
+	users map[string]bool
+}
+
+func (m *mockReadModel) set(id string, active bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.users[id] = active
+}
+
+func (m *mockReadModel) count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.users)
+}
+
+func newMockProjection(store EventStore) (*Projection, *mockReadModel) {
+	model := &mockReadModel{users: map[string]bool{}}
+
+	projection := NewProjection("users", store, NewMemoryCheckpointStore())
+
+	projection.Reset = func(context.Context) error {
+		model.mu.Lock()
+		defer model.mu.Unlock()
+
+		model.users = map[string]bool{}
+		return nil
+	}
+
+	projection.Add("user.created", 1, HandlerFunc(func(_ context.Context, event Event) (Event, error) {
+		model.set(event.ID, true)
+		return Event{}, nil
+	}))
+
+	return projection, model
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	for i := 0; i < 200; i++ {
+		if condition() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("Condition not met in time")
+}
+
+func Test_Projection_Run(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryEventStore()
+
+	store.Append(ctx, "u1", NoStream, Event{Name: "user.created", Version: 1, ID: "u1"})
+	store.Append(ctx, "u1", 1, Event{Name: "user.renamed", Version: 1, ID: "r1"})
+
+	projection, model := newMockProjection(store)
+
+	lag, _ := projection.Lag(ctx)
+	if lag != 2 {
+		t.Errorf("lag == %d, wants: %d", lag, 2)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() { done <- projection.Run(runCtx) }()
+
+	waitFor(t, func() bool { return projection.Position() == 2 })
+
+	store.Append(ctx, "u2", NoStream, Event{Name: "user.created", Version: 1, ID: "u2"})
+	waitFor(t, func() bool { return model.count() == 2 })
+
+	projection.Pause()
+	waitFor(t, func() bool { return projection.Paused() })
+
+	store.Append(ctx, "u3", NoStream, Event{Name: "user.created", Version: 1, ID: "u3"})
+	time.Sleep(20 * time.Millisecond)
+
+	if model.count() != 2 {
+		t.Error("A paused projection must not process events")
+	}
+
+	lag, _ = projection.Lag(ctx)
+	if lag != 1 {
+		t.Errorf("lag == %d, wants: %d", lag, 1)
+	}
+
+	projection.Resume()
+	waitFor(t, func() bool { return model.count() == 3 })
+
+	model.set("stale", true)
+
+	if err := projection.Rebuild(ctx); err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+
+	waitFor(t, func() bool { return projection.Position() == 4 })
+
+	if model.count() != 3 {
+		t.Errorf("model.count() == %d, wants: %d", model.count(), 3)
+	}
+
+	cancel()
+
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Expecting context.Canceled, got: %v", err)
+	}
+}
+
+type mockBlockingCheckpoints struct {
+	*MemoryCheckpointStore
+
+	once    sync.Once
+	loading chan struct{}
+	release chan struct{}
+}
+
+func (s *mockBlockingCheckpoints) Load(ctx context.Context, name string) (int64, error) {
+	position, err := s.MemoryCheckpointStore.Load(ctx, name)
+
+	s.once.Do(func() {
+		close(s.loading)
+		<-s.release
+	})
+
+	return position, err
+}
+
+func Test_Projection_Rebuild_while_loading(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryEventStore()
+
+	store.Append(ctx, "u1", NoStream, Event{Name: "user.created", Version: 1, ID: "u1"})
+	store.Append(ctx, "u2", NoStream, Event{Name: "user.created", Version: 1, ID: "u2"})
+
+	checkpoints := &mockBlockingCheckpoints{
+		MemoryCheckpointStore: NewMemoryCheckpointStore(),
+		loading:               make(chan struct{}),
+		release:               make(chan struct{}),
+	}
+	checkpoints.Save(ctx, "users", 2)
+
+	projection, model := newMockProjection(store)
+	projection.Checkpoints = checkpoints
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go projection.Run(runCtx)
+	<-checkpoints.loading
+
+	rebuilt := make(chan error)
+	go func() { rebuilt <- projection.Rebuild(ctx) }()
+
+	// The checkpoint loaded before the rebuild must not be used
+	waitFor(t, func() bool { return projection.Paused() })
+	close(checkpoints.release)
+
+	if err := <-rebuilt; err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+
+	waitFor(t, func() bool { return model.count() == 2 })
+}
+
+func Test_Projection_handler_error(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryEventStore()
+
+	store.Append(ctx, "u1", NoStream, Event{Name: "user.created", Version: 1})
+
+	projection := NewProjection("failing", store, NewMemoryCheckpointStore())
+	projection.Add("user.created", 1, HandlerFunc(mockEventError))
+
+	err := projection.Run(ctx)
+
+	if err == nil || err.Error() != "some error" {
+		t.Errorf("Expecting the handler error, got: %v", err)
+	}
+
+	if projection.Position() != 0 {
+		t.Error("The checkpoint must not move after a failure")
+	}
+}
+
+func Test_Projection_Bus(t *testing.T) {
+	bus := NewBus()
+	projection, model := newMockProjection(nil)
+
+	bus.SubscribeAll(projection)
+
+	bus.Publish(context.Background(), Event{Name: "user.created", Version: 1, ID: "u1"})
+	bus.Publish(context.Background(), Event{Name: "user.deleted", Version: 1, ID: "u1"})
+
+	if model.count() != 1 {
+		t.Errorf("model.count() == %d, wants: %d", model.count(), 1)
+	}
+
+}
@@ -0,0 +1,198 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RedactedValue replaces the redacted values of payloads
+const RedactedValue = "[REDACTED]"
+
+// Redactor - Rules of the payload fields kept out of trackers, logs and
+// dead letters, by event name and version. Rules are JSON pointers, "*"
+// standing for every element of arrays and objects.
+type Redactor struct {
+	mu    sync.RWMutex
+	tags  map[eventKey][]string
+	paths map[eventKey][]string
+}
+
+// NewRedactor returns a Redactor without rules
+func NewRedactor() *Redactor {
+	return &Redactor{
+		tags:  map[eventKey][]string{},
+		paths: map[eventKey][]string{},
+	}
+}
+
+// RedactedFields returns the JSON pointers of the fields of input tagged
+// `events:"redact"`, "*" standing for every element of slices and maps
+func RedactedFields(input interface{}) []string {
+	return taggedPaths(input, "redact")
+}
+
+// SetInput sets the rules of the event to the fields of input tagged
+// `events:"redact"`. Mux.Add calls it with the Input() of EventDoc
+// handlers.
+func (r *Redactor) SetInput(name string, version int, input interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tags[eventKey{name, version}] = RedactedFields(input)
+}
+
+// AddPaths adds JSON pointers of the payload of the event to its rules
+func (r *Redactor) AddPaths(name string, version int, paths ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := eventKey{name, version}
+	r.paths[key] = append(r.paths[key], paths...)
+}
+
+// Paths returns the rules of the event, sorted
+func (r *Redactor) Paths(name string, version int) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key := eventKey{name, version}
+
+	paths := append([]string{}, r.tags[key]...)
+	paths = append(paths, r.paths[key]...)
+	sort.Strings(paths)
+
+	return paths
+}
+
+// Redact returns a copy of event with the values at its rules replaced by
+// RedactedValue. Payloads that are not JSON are replaced entirely. The
+// sub-events of a batch event are redacted by the rules of their own name
+// and version.
+func (r *Redactor) Redact(event Event) Event {
+	if event.Name == batchEventName && len(event.Payload) > 0 {
+		payload, err := r.redactBatch(event.Payload)
+		if err != nil {
+			event.Payload, _ = json.Marshal(RedactedValue)
+			return event
+		}
+
+		event.Payload = payload
+	}
+
+	paths := r.Paths(event.Name, event.Version)
+	if len(paths) == 0 || len(event.Payload) == 0 {
+		return event
+	}
+
+	payload, err := decodeJSON(event.Payload)
+	if err != nil {
+		event.Payload, _ = json.Marshal(RedactedValue)
+		return event
+	}
+
+	for _, path := range paths {
+		payload, _ = replacePath(payload, path, true, func(_ string, value interface{}) (interface{}, error) {
+			if value == nil {
+				return nil, nil
+			}
+
+			return RedactedValue, nil
+		})
+	}
+
+	event.Payload, _ = json.Marshal(payload)
+
+	return event
+}
+
+// redactBatch redacts the sub-events of a batch payload, keeping the other
+// fields of the payload and of its items as they are. Field names match
+// case-insensitively, as they do when the batch is decoded.
+func (r *Redactor) redactBatch(data json.RawMessage) (json.RawMessage, error) {
+	payload := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+
+	for key, value := range payload {
+		if !strings.EqualFold(key, "events") {
+			continue
+		}
+
+		items := []map[string]json.RawMessage{}
+		if err := json.Unmarshal(value, &items); err != nil {
+			return nil, err
+		}
+
+		for _, item := range items {
+			event := Event{}
+			for field, value := range item {
+				switch {
+				case strings.EqualFold(field, "name"):
+					json.Unmarshal(value, &event.Name)
+				case strings.EqualFold(field, "version"):
+					json.Unmarshal(value, &event.Version)
+				}
+			}
+
+			for field, value := range item {
+				if strings.EqualFold(field, "payload") && len(value) > 0 {
+					event.Payload = value
+					item[field] = r.Redact(event).Payload
+				}
+			}
+		}
+
+		payload[key], _ = json.Marshal(items)
+	}
+
+	return json.Marshal(payload)
+}
+
+// NewRedactingTracker returns an HTTPTracker passing the events redacted by
+// redactor to tracker. The trackers of Muxes are wrapped with it. The result
+// is an EventTracker when tracker is one.
+func NewRedactingTracker(tracker HTTPTracker, redactor *Redactor) HTTPTracker {
+	redacting := &redactingTracker{tracker: tracker, redactor: redactor}
+
+	if events, ok := tracker.(EventTracker); ok {
+		return &redactingEventTracker{redacting, events}
+	}
+
+	return redacting
+}
+
+type redactingTracker struct {
+	tracker  HTTPTracker
+	redactor *Redactor
+}
+
+func (t *redactingTracker) Start(ctx context.Context, event Event, w http.ResponseWriter, r *http.Request) context.Context {
+	return t.tracker.Start(ctx, t.redactor.Redact(event), w, r)
+}
+
+func (t *redactingTracker) End(ctx context.Context, event Event, err error) context.Context {
+	return t.tracker.End(ctx, t.redactor.Redact(event), err)
+}
+
+func (t *redactingTracker) NoticeError(ctx context.Context, err error) context.Context {
+	return t.tracker.NoticeError(ctx, err)
+}
+
+func (t *redactingTracker) NoticeEventError(ctx context.Context, event Event, err error) context.Context {
+	return t.tracker.NoticeEventError(ctx, t.redactor.Redact(event), err)
+}
+
+type redactingEventTracker struct {
+	*redactingTracker
+
+	events EventTracker
+}
+
+func (t *redactingEventTracker) StartEvent(ctx context.Context, event Event) context.Context {
+	return t.events.StartEvent(ctx, t.redactor.Redact(event))
+}
@@ -0,0 +1,250 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockSignup struct {
+	Name    string           `json:"name"`
+	Email   string           `json:"email" events:"redact"`
+	CPF     string           `json:"cpf" events:"encrypted,redact"`
+	Devices []mockSignupItem `json:"devices"`
+}
+
+type mockSignupItem struct {
+	Model string `json:"model"`
+	IMEI  string `json:"imei" events:"redact"`
+}
+
+type mockSignupHandler struct {
+	mu    sync.Mutex
+	input Event
+}
+
+func (h *mockSignupHandler) Serve(_ context.Context, event Event) (Event, error) {
+	h.mu.Lock()
+	h.input = event
+	h.mu.Unlock()
+
+	return NewError(event.FlowID, "failed"), errors.New("failed")
+}
+
+func (h *mockSignupHandler) Example() (interface{}, interface{}) {
+	return mockSignup{}, nil
+}
+
+func (h *mockSignupHandler) Input() interface{} {
+	return mockSignup{}
+}
+
+func (h *mockSignupHandler) Output() interface{} {
+	return nil
+}
+
+func (h *mockSignupHandler) Doc() string {
+	return ""
+}
+
+var mockSignupPayload = json.RawMessage(`{"name":"Maria","email":"maria@example.com","cpf":"123.456.789-00","devices":[{"model":"X","imei":"490154203237518"}],"phone":"+55 11 99999-0000"}`)
+
+type mockRecordingTracker struct {
+	noOpTracker
+
+	mu     sync.Mutex
+	events []Event
+}
+
+func (t *mockRecordingTracker) Start(ctx context.Context, event Event, w http.ResponseWriter, r *http.Request) context.Context {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.events = append(t.events, event)
+	return ctx
+}
+
+func (t *mockRecordingTracker) NoticeEventError(ctx context.Context, event Event, err error) context.Context {
+	return t.Start(ctx, event, nil, nil)
+}
+
+func assertRedacted(t *testing.T, payload json.RawMessage) {
+	for _, secret := range []string{"maria@example.com", "123.456.789-00", "490154203237518", "+55 11 99999-0000"} {
+		if strings.Contains(string(payload), secret) {
+			t.Errorf("Payload == %s, wants %s redacted", payload, secret)
+		}
+	}
+
+	if !strings.Contains(string(payload), `"name":"Maria"`) || !strings.Contains(string(payload), `"model":"X"`) {
+		t.Errorf("Payload == %s, wants the fields without rules kept", payload)
+	}
+}
+
+func Test_Redactor(t *testing.T) {
+	redactor := NewRedactor()
+	redactor.SetInput("signup", 1, mockSignup{})
+	redactor.AddPaths("signup", 1, "/phone", "/missing/field")
+
+	wants := "/cpf,/devices/*/imei,/email,/missing/field,/phone"
+	if paths := strings.Join(redactor.Paths("signup", 1), ","); paths != wants {
+		t.Errorf("Paths == %s, wants: %s", paths, wants)
+	}
+
+	event := Event{Name: "signup", Version: 1, Payload: mockSignupPayload}
+	redacted := redactor.Redact(event)
+
+	assertRedacted(t, redacted.Payload)
+
+	if string(event.Payload) != string(mockSignupPayload) {
+		t.Error("Redact must not change the event")
+	}
+
+	if other := redactor.Redact(Event{Name: "signup", Version: 2, Payload: mockSignupPayload}); string(other.Payload) != string(mockSignupPayload) {
+		t.Errorf("Payload == %s, wants it unchanged for events without rules", other.Payload)
+	}
+
+	if invalid := redactor.Redact(Event{Name: "signup", Version: 1, Payload: json.RawMessage(`maria@example.com`)}); string(invalid.Payload) != `"[REDACTED]"` {
+		t.Errorf("Payload == %s, wants: \"[REDACTED]\"", invalid.Payload)
+	}
+}
+
+func Test_Redactor_case_insensitive(t *testing.T) {
+	redactor := NewRedactor()
+	redactor.SetInput("signup", 1, mockSignup{})
+	redactor.AddPaths("signup", 1, "/phone")
+
+	payload := json.RawMessage(`{"name":"Maria","EMAIL":"maria@example.com","Cpf":"123.456.789-00","Devices":[{"model":"X","Imei":"490154203237518"}],"Phone":"+55 11 99999-0000"}`)
+
+	// encoding/json decodes the keys of any case into the tagged fields
+	decoded := mockSignup{}
+	json.Unmarshal(payload, &decoded)
+
+	if decoded.Email != "maria@example.com" || decoded.Devices[0].IMEI != "490154203237518" {
+		t.Fatalf("decoded == %+v, wants the mixed case keys decoded", decoded)
+	}
+
+	assertRedacted(t, redactor.Redact(Event{Name: "signup", Version: 1, Payload: payload}).Payload)
+}
+
+func Test_Redactor_batch(t *testing.T) {
+	redactor := NewRedactor()
+	redactor.SetInput("signup", 1, mockSignup{})
+	redactor.AddPaths("signup", 1, "/phone")
+
+	payload := `{"parallel":true,"events":[{"name":"signup","version":1,"id":"a","payload":` + string(mockSignupPayload) + `,"dependsOn":["b"]},` +
+		`{"name":"batch","version":1,"id":"b","payload":{"Events":[{"Name":"signup","Version":1,"Payload":` + string(mockSignupPayload) + `}]}}]}`
+
+	redacted := redactor.Redact(Event{Name: "batch", Version: 1, Payload: json.RawMessage(payload)})
+
+	assertRedacted(t, redacted.Payload)
+
+	for _, kept := range []string{`"parallel":true`, `"dependsOn":["b"]`, `"id":"a"`} {
+		if !strings.Contains(string(redacted.Payload), kept) {
+			t.Errorf("Payload == %s, wants %s kept", redacted.Payload, kept)
+		}
+	}
+
+	if invalid := redactor.Redact(Event{Name: "batch", Version: 1, Payload: json.RawMessage(`{"events":"maria@example.com"}`)}); string(invalid.Payload) != `"[REDACTED]"` {
+		t.Errorf("Payload == %s, wants: \"[REDACTED]\"", invalid.Payload)
+	}
+}
+
+func Test_Mux_Redact(t *testing.T) {
+	tracker := &mockRecordingTracker{}
+	handler := &mockSignupHandler{}
+
+	mux := NewMuxWithTracker(tracker)
+	mux.Add("signup", 1, handler)
+	mux.Redactor().AddPaths("signup", 1, "/phone")
+
+	event := Event{Name: "signup", Version: 1, Payload: mockSignupPayload}
+	mux.Dispatch(context.Background(), event)
+
+	if string(handler.input.Payload) != string(mockSignupPayload) {
+		t.Errorf("Payload == %s, wants the handler to get the event unchanged", handler.input.Payload)
+	}
+
+	if len(tracker.events) != 1 {
+		t.Fatalf("len(tracker.events) == %d, wants: %d", len(tracker.events), 1)
+	}
+
+	assertRedacted(t, tracker.events[0].Payload)
+	assertRedacted(t, mux.Redact(event).Payload)
+
+	// Replacing the handler replaces its tag rules
+	mux.Add("signup", 1, HandlerFunc(mockHandlerFunc))
+
+	if paths := mux.Redactor().Paths("signup", 1); len(paths) != 1 || paths[0] != "/phone" {
+		t.Errorf("Paths == %v, wants: [/phone]", paths)
+	}
+}
+
+func Test_Consumer_RedactDeadLetters(t *testing.T) {
+	broker := NewMemoryBroker()
+	deadLetters := NewMemoryDeadLetterStore()
+	handler := &mockSignupHandler{}
+
+	mux := NewMux()
+	mux.Add("signup", 1, handler)
+	mux.Redactor().AddPaths("signup", 1, "/phone")
+
+	consumer := NewConsumer(mux, broker, "requests")
+	consumer.DeadLetters = deadLetters
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go consumer.Run(ctx)
+
+	broker.Publish(ctx, "requests", Event{Name: "signup", Version: 1, ID: "event-1", Payload: mockSignupPayload})
+
+	var stored []DeadLetter
+	for i := 0; i < 100; i++ {
+		stored, _ = deadLetters.List(ctx)
+		if len(stored) == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if len(stored) != 1 {
+		t.Fatalf("len(deadLetters) == %d, wants: %d", len(stored), 1)
+	}
+
+	// The store keeps the original event to be redriven
+	if string(stored[0].Event.Payload) != string(mockSignupPayload) {
+		t.Errorf("Payload == %s, wants: %s", stored[0].Event.Payload, mockSignupPayload)
+	}
+
+	list, err := ListDeadLetters(ctx, mux, deadLetters)
+	if err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	assertRedacted(t, list[0].Event.Payload)
+
+	results, _ := Redrive(ctx, mux, deadLetters)
+	if len(results) != 1 {
+		t.Fatalf("len(results) == %d, wants: %d", len(results), 1)
+	}
+
+	assertRedacted(t, results[0].DeadLetter.Event.Payload)
+
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+
+	if string(handler.input.Payload) != string(mockSignupPayload) {
+		t.Errorf("Payload == %s, wants the redriven event unchanged", handler.input.Payload)
+	}
+}
+
+func Test_NewMux_redacting_tracker(t *testing.T) {
+	if _, ok := NewMux().tracer.(*redactingTracker); !ok {
+		t.Errorf("tracer == %T, wants: *redactingTracker", NewMux().tracer)
+	}
+}
@@ -0,0 +1,272 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codes of the error events of requests ServeHTTP rejects
+const (
+	ErrorCodeMalformedEvent      = 1
+	ErrorCodeMethodNotAllowed    = 2
+	ErrorCodeBodyTooLarge        = 3
+	ErrorCodeUnknownField        = 4
+	ErrorCodeTrailingData        = 5
+	ErrorCodeUnsupportedEncoding = 6
+)
+
+// HTTPOptions - How ServeHTTP reads requests and writes responses
+type HTTPOptions struct {
+	// MaxBodyBytes limits the request body, zero is unlimited
+	MaxBodyBytes int64
+
+	// Methods are the HTTP methods accepted, only POST when empty
+	Methods []string
+
+	// DisallowUnknownFields rejects JSON events with envelope fields
+	// unknown to Event, payload and metadata are not checked
+	DisallowUnknownFields bool
+
+	// RejectTrailingData rejects JSON bodies with data after the event
+	RejectTrailingData bool
+
+	// CompressionThreshold is the size from which responses are compressed
+	// with the encoding accepted by the client, gzip or zstd. Zero
+	// disables compression. Requests are decoded following their
+	// Content-Encoding, MaxBodyBytes applies to the decoded body.
+	CompressionThreshold int
+}
+
+// DefaultHTTPOptions are the HTTPOptions of new Muxes
+var DefaultHTTPOptions = HTTPOptions{
+	MaxBodyBytes:         10 << 20,
+	Methods:              []string{http.MethodPost},
+	CompressionThreshold: 1024,
+}
+
+// SetHTTPOptions changes how ServeHTTP reads requests
+func (m *Mux) SetHTTPOptions(options HTTPOptions) {
+	m.httpOptions = options
+}
+
+// requestError - A request rejected by ServeHTTP, answered with an error
+// event with its code
+type requestError struct {
+	code    int
+	message string
+}
+
+// errTrailingData rejects the data after the event with RejectTrailingData
+var errTrailingData = requestError{
+	code:    ErrorCodeTrailingData,
+	message: "Unexpected data after the event",
+}
+
+func (e requestError) Error() string {
+	return e.message
+}
+
+// NewErrorWithCode returns a error event with a code
+func NewErrorWithCode(flowID, message string, code int) Event {
+	payload, _ := json.Marshal(errorPayload{
+		Message: message,
+		Code:    code,
+	})
+
+	return Event{
+		Name:    "error",
+		Version: 1,
+		ID:      RandomID(),
+		FlowID:  flowID,
+		Payload: payload,
+	}
+}
+
+// decodeRequest reads the event of a request following the HTTPOptions
+func (m *Mux) decodeRequest(w http.ResponseWriter, r *http.Request) (Event, error) {
+	event := Event{}
+	options := m.httpOptions
+
+	if !options.allows(r.Method) {
+		w.Header().Set("Allow", strings.Join(options.methods(), ", "))
+
+		return event, requestError{
+			code:    ErrorCodeMethodNotAllowed,
+			message: fmt.Sprintf("Method %s not allowed", r.Method),
+		}
+	}
+
+	body := r.Body
+	if options.MaxBodyBytes > 0 {
+		body = http.MaxBytesReader(w, body, options.MaxBodyBytes)
+	}
+
+	body, err := decompress(r.Header.Get("Content-Encoding"), body, options.MaxBodyBytes)
+	if err != nil {
+		code := ErrorCodeMalformedEvent
+		if _, ok := err.(unsupportedEncodingError); ok {
+			code = ErrorCodeUnsupportedEncoding
+		}
+		return event, requestError{code: code, message: err.Error()}
+	}
+	defer body.Close()
+
+	// Limits the decoded body too, against compression bombs
+	if options.MaxBodyBytes > 0 {
+		body = http.MaxBytesReader(w, body, options.MaxBodyBytes)
+	}
+
+	if codec := m.requestCodec(r.Header.Get("Content-Type")); codec != nil {
+		data, err := ioutil.ReadAll(body)
+		if err != nil {
+			return event, options.decodeError(err)
+		}
+
+		if strict, ok := codec.(strictCodec); ok {
+			err = strict.unmarshalStrict(data, &event, options)
+		} else {
+			err = codec.Unmarshal(data, &event)
+		}
+
+		if _, ok := err.(requestError); ok {
+			return event, err
+		}
+
+		if err != nil {
+			return event, requestError{code: ErrorCodeMalformedEvent, message: err.Error()}
+		}
+
+		return event, nil
+	}
+
+	decoder := json.NewDecoder(body)
+
+	raw := json.RawMessage{}
+	if err := decoder.Decode(&raw); err != nil {
+		return event, options.decodeError(err)
+	}
+
+	if options.DisallowUnknownFields {
+		if field, ok := unknownEventField(raw); ok {
+			return event, requestError{
+				code:    ErrorCodeUnknownField,
+				message: fmt.Sprintf("unknown field %q", field),
+			}
+		}
+	}
+
+	if err := json.Unmarshal(raw, &event); err != nil {
+		return event, options.decodeError(err)
+	}
+
+	if options.RejectTrailingData {
+		// Anything but the end of the body is trailing data, even when it
+		// is not valid JSON, unless the body is too large
+		if _, err := decoder.Token(); err != io.EOF {
+			if isBodyTooLarge(err) {
+				return event, options.decodeError(err)
+			}
+
+			return event, errTrailingData
+		}
+	}
+
+	return event, nil
+}
+
+func (o HTTPOptions) decodeError(err error) error {
+	switch {
+	case isBodyTooLarge(err):
+		return requestError{
+			code:    ErrorCodeBodyTooLarge,
+			message: fmt.Sprintf("Request body larger than %d bytes", o.MaxBodyBytes),
+		}
+	default:
+		return requestError{code: ErrorCodeMalformedEvent, message: err.Error()}
+	}
+}
+
+// isBodyTooLarge reports whether err comes from the MaxBodyBytes limits of
+// the body or of its decompression
+func isBodyTooLarge(err error) bool {
+	var maxBytes *http.MaxBytesError
+
+	return errors.As(err, &maxBytes) || errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded)
+}
+
+// eventFields are the JSON names of the Event fields
+var eventFields = func() []string {
+	t := reflect.TypeOf(Event{})
+	names := []string{}
+
+	for i := 0; i < t.NumField(); i++ {
+		if name, ok := jsonFieldName(t.Field(i)); ok {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}()
+
+// unknownEventField returns the first field of the JSON object data that is
+// not an Event field. Names match case-insensitively, like encoding/json.
+func unknownEventField(data json.RawMessage) (string, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return "", false
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", false
+		}
+
+		name, _ := token.(string)
+		known := false
+
+		for _, field := range eventFields {
+			if strings.EqualFold(field, name) {
+				known = true
+				break
+			}
+		}
+
+		if !known {
+			return name, true
+		}
+
+		value := json.RawMessage{}
+		if err := decoder.Decode(&value); err != nil {
+			return "", false
+		}
+	}
+
+	return "", false
+}
+
+func (o HTTPOptions) methods() []string {
+	if len(o.Methods) == 0 {
+		return []string{http.MethodPost}
+	}
+	return o.Methods
+}
+
+func (o HTTPOptions) allows(method string) bool {
+	for _, allowed := range o.methods() {
+		if strings.EqualFold(allowed, method) {
+			return true
+		}
+	}
+	return false
+}
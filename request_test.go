@@ -0,0 +1,91 @@
+package events
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serveRequest(mux *Mux, method, body string) (*httptest.ResponseRecorder, errorPayload) {
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(method, "/events/", strings.NewReader(body))
+
+	mux.ServeHTTP(w, r)
+
+	response := Event{}
+	json.NewDecoder(w.Body).Decode(&response)
+
+	payload := errorPayload{}
+	if response.Name == "error" {
+		json.Unmarshal(response.Payload, &payload)
+	}
+
+	return w, payload
+}
+
+func Test_ServeHTTP_Options(t *testing.T) {
+	mux := NewMux()
+	mux.Add("some event", 1, HandlerFunc(mockHandlerFunc))
+
+	event := `{"name": "some event", "version": 1, "payload": {"a": 1}}`
+
+	w, payload := serveRequest(mux, "GET", event)
+	if payload.Code != ErrorCodeMethodNotAllowed {
+		t.Errorf("payload.Code == %d, wants: %d", payload.Code, ErrorCodeMethodNotAllowed)
+	}
+	if w.Header().Get("Allow") != "POST" {
+		t.Errorf("Allow == %s, wants: %s", w.Header().Get("Allow"), "POST")
+	}
+
+	// Lenient by default
+	_, payload = serveRequest(mux, "POST", `{"name": "some event", "version": 1, "extra": true} garbage`)
+	if payload.Code != 0 {
+		t.Errorf("payload.Code == %d, wants: %d", payload.Code, 0)
+	}
+
+	_, payload = serveRequest(mux, "POST", `{"name": `)
+	if payload.Code != ErrorCodeMalformedEvent {
+		t.Errorf("payload.Code == %d, wants: %d", payload.Code, ErrorCodeMalformedEvent)
+	}
+
+	mux.SetHTTPOptions(HTTPOptions{
+		MaxBodyBytes:          64,
+		Methods:               []string{"POST", "PUT"},
+		DisallowUnknownFields: true,
+		RejectTrailingData:    true,
+	})
+
+	tests := []struct {
+		method string
+		body   string
+		code   int
+	}{
+		{"PUT", event, 0},
+		{"PUT", event + "\n\n", 0},
+		{"DELETE", event, ErrorCodeMethodNotAllowed},
+		{"POST", `{"name": "some event", "version": 1, "payload": "` + strings.Repeat("x", 64) + `"}`, ErrorCodeBodyTooLarge},
+		{"POST", `{"name": "some event", "version": 1, "extra": true}`, ErrorCodeUnknownField},
+		{"POST", `{"name": "some event", "version": 1, "payload": {"extra": true}}`, 0},
+		{"POST", `{"Name": "some event", "VERSION": 1}`, 0},
+		{"POST", `{"name": "some event", "version": "1"}`, ErrorCodeMalformedEvent},
+		{"POST", event + ` {}`, ErrorCodeTrailingData},
+		{"POST", event + ` garbage`, ErrorCodeTrailingData},
+		{"POST", event + ` ]`, ErrorCodeTrailingData},
+		{"POST", event + ` "` + strings.Repeat("x", 64) + `"`, ErrorCodeBodyTooLarge},
+	}
+
+	for _, test := range tests {
+		_, payload := serveRequest(mux, test.method, test.body)
+
+		if payload.Code != test.code {
+			t.Errorf("%s %s: payload.Code == %d (%s), wants: %d", test.method, test.body, payload.Code, payload.Message, test.code)
+		}
+	}
+
+	_, payload = serveRequest(mux, "POST", `{"name": "some event", "version": 1, "extra": true}`)
+	if payload.Message != `unknown field "extra"` {
+		t.Errorf("payload.Message == %s, wants: %s", payload.Message, `unknown field "extra"`)
+	}
+}
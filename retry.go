@@ -0,0 +1,115 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// MetadataAttempts is the metadata field with the number of times an event
+// was dispatched by a Consumer
+const MetadataAttempts = "attempts"
+
+// RetryPolicy - How a Consumer retries the events whose handlers fail
+type RetryPolicy struct {
+	// MaxAttempts includes the first attempt, values below 1 mean 1
+	MaxAttempts int
+
+	// InitialBackoff is the wait after the first failure, it grows by
+	// Multiplier (default 2) up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+
+	// Jitter randomizes the backoff by up to this fraction of it (0 to 1)
+	Jitter float64
+
+	// Retryable classifies errors, nil retries every error except the
+	// ones wrapped by Permanent
+	Retryable func(error) bool
+}
+
+// NoRetry is the RetryPolicy of a Consumer without retries
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks an error as not retryable
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// IsPermanent reports whether err, or an error it wraps, was marked by
+// Permanent
+func IsPermanent(err error) bool {
+	return errors.As(err, &permanentError{})
+}
+
+// ShouldRetry reports whether an event failing with err after attempts
+// dispatches must be dispatched again
+func (p RetryPolicy) ShouldRetry(attempts int, err error) bool {
+	if attempts >= p.MaxAttempts || IsPermanent(err) {
+		return false
+	}
+
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+
+	return true
+}
+
+// Backoff returns the wait before the next dispatch of an event that
+// failed attempts times
+func (p RetryPolicy) Backoff(attempts int) time.Duration {
+	if p.InitialBackoff <= 0 || attempts < 1 {
+		return 0
+	}
+
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempts-1))
+
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		backoff = backoff * (1 - jitter + 2*jitter*rand.Float64())
+	}
+
+	return time.Duration(backoff)
+}
+
+// sleep waits for d or for ctx to end
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
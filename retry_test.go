@@ -0,0 +1,156 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_RetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     300 * time.Millisecond,
+	}
+
+	expected := []time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}
+
+	for attempts, wants := range expected {
+		if backoff := policy.Backoff(attempts); backoff != wants {
+			t.Errorf("Backoff(%d) == %s, wants: %s", attempts, backoff, wants)
+		}
+	}
+
+	policy.Jitter = 0.5
+
+	for i := 0; i < 100; i++ {
+		backoff := policy.Backoff(1)
+		if backoff < 50*time.Millisecond || backoff > 150*time.Millisecond {
+			t.Fatalf("Backoff(1) == %s, wants between 50ms and 150ms", backoff)
+		}
+	}
+}
+
+func Test_RetryPolicy_ShouldRetry(t *testing.T) {
+	temporary := errors.New("temporary")
+	policy := RetryPolicy{MaxAttempts: 3}
+
+	if !policy.ShouldRetry(1, temporary) || !policy.ShouldRetry(2, temporary) {
+		t.Error("Expecting retries before MaxAttempts")
+	}
+
+	if policy.ShouldRetry(3, temporary) {
+		t.Error("Not expecting retries after MaxAttempts")
+	}
+
+	if policy.ShouldRetry(1, Permanent(temporary)) {
+		t.Error("Not expecting retries of permanent errors")
+	}
+
+	policy.Retryable = func(err error) bool { return err != temporary }
+
+	if policy.ShouldRetry(1, temporary) {
+		t.Error("Retryable must classify the errors")
+	}
+
+	if NoRetry.ShouldRetry(1, temporary) {
+		t.Error("NoRetry must not retry")
+	}
+
+	if !IsPermanent(fmt.Errorf("wrapped: %w", Permanent(temporary))) {
+		t.Error("IsPermanent must find wrapped permanent errors")
+	}
+}
+
+func Test_Consumer_retry_and_dead_letter(t *testing.T) {
+	broker := NewMemoryBroker()
+	deadLetters := NewMemoryDeadLetterStore()
+
+	var mu sync.Mutex
+	attempts := []int{}
+	fail := true
+
+	mux := NewMux()
+	mux.Add("flaky", 1, HandlerFunc(func(_ context.Context, event Event) (Event, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		attempt := 0
+		MetadataField(event, MetadataAttempts, &attempt)
+		attempts = append(attempts, attempt)
+
+		if fail {
+			return NewError(event.FlowID, "failed"), errors.New("failed")
+		}
+		return NewResponse(event, nil)
+	}))
+
+	consumer := NewConsumer(mux, broker, "requests")
+	consumer.Retry = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+	consumer.DeadLetters = deadLetters
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go consumer.Run(ctx)
+
+	broker.Publish(ctx, "requests", Event{Name: "flaky", Version: 1, ID: "event-1"})
+	broker.Publish(ctx, "requests", Event{Name: "unknown", Version: 1, ID: "event-2"})
+
+	var list []DeadLetter
+	for i := 0; i < 100; i++ {
+		list, _ = deadLetters.List(ctx)
+		if len(list) == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if len(list) != 2 {
+		t.Fatalf("len(deadLetters) == %d, wants: %d", len(list), 2)
+	}
+
+	mu.Lock()
+	if len(attempts) != 3 || attempts[0] != 1 || attempts[2] != 3 {
+		t.Errorf("attempts == %v, wants: [1 2 3]", attempts)
+	}
+	fail = false
+	mu.Unlock()
+
+	if list[0].Attempts != 3 || list[0].Event.ID != "event-1" || list[0].Topic != "requests" {
+		t.Errorf("Unexpected dead letter: %#v", list[0])
+	}
+
+	if list[1].Attempts != 1 {
+		t.Errorf("Unknown events must not be retried, attempts == %d", list[1].Attempts)
+	}
+
+	results, err := Redrive(ctx, mux, deadLetters)
+
+	if err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+
+	if len(results) != 2 || results[0].Err != nil || results[1].Err == nil {
+		t.Errorf("Unexpected redrive results: %#v", results)
+	}
+
+	mu.Lock()
+	if last := attempts[len(attempts)-1]; last != 0 {
+		t.Errorf("attempts == %d, wants the redriven event without attempts", last)
+	}
+	mu.Unlock()
+
+	list, _ = deadLetters.List(ctx)
+
+	if len(list) != 1 || list[0].Event.ID != "event-2" {
+		t.Errorf("Only the failed redrive must stay in the store, got: %#v", list)
+	}
+
+	if _, err := Redrive(ctx, mux, deadLetters, "missing"); err == nil {
+		t.Error("Expecting an error for unknown dead letter IDs")
+	}
+}
@@ -0,0 +1,437 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrSagaNotFound is returned by SagaStore for unknown flow IDs
+var ErrSagaNotFound = errors.New("events: saga not found")
+
+// SagaStatus - The stage of a saga
+type SagaStatus string
+
+// Saga statuses
+const (
+	SagaRunning      SagaStatus = "running"
+	SagaCompleted    SagaStatus = "completed"
+	SagaCompensating SagaStatus = "compensating"
+	SagaCompensated  SagaStatus = "compensated"
+	SagaFailed       SagaStatus = "failed"
+)
+
+// SagaStep - A step of a saga. The step event is sent with the saga data as
+// payload, unless Payload is set, and the step completes with its
+// "<name>:response" event. An "error" event fails the step. Responses and
+// errors answer the step event with its ID in MetadataRequestID, as the
+// replies of a Consumer do; the ones answering other events are ignored.
+type SagaStep struct {
+	Name    string
+	Version int
+
+	// Compensation is the event that undoes the step, empty for steps
+	// that need no compensation
+	Compensation        string
+	CompensationVersion int
+
+	// Timeout of the step, zero waits forever
+	Timeout time.Duration
+
+	// Payload builds the payload of the step event
+	Payload func(SagaState) (json.RawMessage, error)
+}
+
+// SagaDefinition - The steps of a saga
+type SagaDefinition struct {
+	Name  string
+	Steps []SagaStep
+}
+
+// SagaState - A running saga, keyed by FlowID
+type SagaState struct {
+	FlowID string     `json:"flowId"`
+	Saga   string     `json:"saga"`
+	Status SagaStatus `json:"status"`
+
+	// Step is the index of the current step, during compensation it is
+	// the step being compensated
+	Step int `json:"step"`
+
+	// EventID is the ID of the step or compensation event waiting for its
+	// response
+	EventID string `json:"eventId,omitempty"`
+
+	Data      json.RawMessage   `json:"data"`
+	Responses []json.RawMessage `json:"responses"`
+	Error     string            `json:"error,omitempty"`
+
+	// Deadline of the current step, zero without timeout
+	Deadline  time.Time `json:"deadline,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// SagaStore - Where the saga states are kept
+type SagaStore interface {
+	Load(ctx context.Context, flowID string) (SagaState, error)
+	Save(ctx context.Context, state SagaState) error
+	// Expired returns the sagas waiting for a step past its deadline
+	Expired(ctx context.Context, now time.Time) ([]SagaState, error)
+}
+
+// Saga drives the sagas of a definition from the response and error
+// events of their steps. A Saga is a Handler, so it can be subscribed to a
+// Bus or added to a Mux to receive the responses.
+type Saga struct {
+	Definition SagaDefinition
+	Store      SagaStore
+
+	// Send delivers the step and compensation events
+	Send func(context.Context, Event) error
+
+	// Now is the clock of the step deadlines
+	Now func() time.Time
+
+	mu sync.Mutex
+}
+
+// NewSaga returns a Saga of definition
+func NewSaga(definition SagaDefinition, store SagaStore, send func(context.Context, Event) error) *Saga {
+	return &Saga{
+		Definition: definition,
+		Store:      store,
+		Send:       send,
+		Now:        time.Now,
+	}
+}
+
+// Start begins a saga for flowID sending the event of its first step
+func (s *Saga) Start(ctx context.Context, flowID string, data interface{}) error {
+	s.mu.Lock()
+	next, err := s.start(ctx, flowID, data)
+	s.mu.Unlock()
+
+	return s.send(ctx, next, err)
+}
+
+func (s *Saga) start(ctx context.Context, flowID string, data interface{}) ([]Event, error) {
+	if len(s.Definition.Steps) == 0 {
+		return nil, fmt.Errorf("events: saga %q has no steps", s.Definition.Name)
+	}
+
+	if _, err := s.Store.Load(ctx, flowID); err == nil {
+		return nil, fmt.Errorf("events: saga for flow %q already exists", flowID)
+	} else if err != ErrSagaNotFound {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	state := SagaState{
+		FlowID:    flowID,
+		Saga:      s.Definition.Name,
+		Status:    SagaRunning,
+		Data:      payload,
+		Responses: []json.RawMessage{},
+	}
+
+	return s.runStep(ctx, state)
+}
+
+// Serve implements Handler, see Handle
+func (s *Saga) Serve(ctx context.Context, event Event) (Event, error) {
+	return Event{}, s.Handle(ctx, event)
+}
+
+// Handle advances the saga of the event FlowID. Events of other flows,
+// sagas or steps are ignored.
+func (s *Saga) Handle(ctx context.Context, event Event) error {
+	s.mu.Lock()
+	next, err := s.handle(ctx, event)
+	s.mu.Unlock()
+
+	return s.send(ctx, next, err)
+}
+
+func (s *Saga) handle(ctx context.Context, event Event) ([]Event, error) {
+	state, err := s.Store.Load(ctx, event.FlowID)
+	if err == ErrSagaNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if state.Saga != s.Definition.Name || !state.Status.active() {
+		return nil, nil
+	}
+
+	// Late answers of timed out steps must not fail the current one
+	requestID := ""
+	if !MetadataField(event, MetadataRequestID, &requestID) || requestID != state.EventID {
+		return nil, nil
+	}
+
+	step := s.Definition.Steps[state.Step]
+
+	switch state.Status {
+	case SagaRunning:
+		switch event.Name {
+		case step.Name + ":response":
+			state.Responses = append(state.Responses, event.Payload)
+			state.Step++
+
+			if state.Step == len(s.Definition.Steps) {
+				state.Status = SagaCompleted
+				state.Deadline = time.Time{}
+				return nil, s.save(ctx, state)
+			}
+
+			return s.runStep(ctx, state)
+		case "error":
+			return s.compensate(ctx, state, errorMessage(event))
+		}
+	case SagaCompensating:
+		switch event.Name {
+		case step.Compensation + ":response":
+			state.Step--
+			return s.runCompensation(ctx, state)
+		case "error":
+			state.Status = SagaFailed
+			state.Error = fmt.Sprintf("%s; compensation of %q failed: %s", state.Error, step.Name, errorMessage(event))
+			state.Deadline = time.Time{}
+			return nil, s.save(ctx, state)
+		}
+	}
+
+	return nil, nil
+}
+
+// CheckTimeouts fails the steps past their deadline, compensating the
+// running sagas and marking the compensating ones as failed
+func (s *Saga) CheckTimeouts(ctx context.Context) error {
+	s.mu.Lock()
+	next, err := s.checkTimeouts(ctx)
+	s.mu.Unlock()
+
+	return s.send(ctx, next, err)
+}
+
+func (s *Saga) checkTimeouts(ctx context.Context) ([]Event, error) {
+	expired, err := s.Store.Expired(ctx, s.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []Event{}
+
+	for _, state := range expired {
+		if state.Saga != s.Definition.Name || !state.Status.active() {
+			continue
+		}
+
+		step := s.Definition.Steps[state.Step]
+		var next []Event
+
+		switch state.Status {
+		case SagaRunning:
+			next, err = s.compensate(ctx, state, fmt.Sprintf("step %q timed out", step.Name))
+		case SagaCompensating:
+			state.Status = SagaFailed
+			state.Error = fmt.Sprintf("%s; compensation of %q timed out", state.Error, step.Name)
+			state.Deadline = time.Time{}
+			err = s.save(ctx, state)
+		}
+
+		if err != nil {
+			return pending, err
+		}
+
+		pending = append(pending, next...)
+	}
+
+	return pending, nil
+}
+
+// send delivers the events produced while holding the lock, so handlers
+// answering synchronously can call Handle
+func (s *Saga) send(ctx context.Context, events []Event, err error) error {
+	for _, event := range events {
+		if sendErr := s.Send(ctx, event); sendErr != nil && err == nil {
+			err = sendErr
+		}
+	}
+
+	return err
+}
+
+func (status SagaStatus) active() bool {
+	return status == SagaRunning || status == SagaCompensating
+}
+
+// Run calls CheckTimeouts every interval until ctx ends
+func (s *Saga) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := s.CheckTimeouts(ctx); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Saga) runStep(ctx context.Context, state SagaState) ([]Event, error) {
+	step := s.Definition.Steps[state.Step]
+
+	payload := state.Data
+	if step.Payload != nil {
+		var err error
+		if payload, err = step.Payload(state); err != nil {
+			return s.compensate(ctx, state, err.Error())
+		}
+	}
+
+	state.EventID = RandomID()
+	state.Deadline = s.deadline(step.Timeout)
+
+	if err := s.save(ctx, state); err != nil {
+		return nil, err
+	}
+
+	return []Event{{
+		Name:    step.Name,
+		Version: step.Version,
+		ID:      state.EventID,
+		FlowID:  state.FlowID,
+		Payload: payload,
+	}}, nil
+}
+
+// compensate starts compensating the steps completed before the current one
+func (s *Saga) compensate(ctx context.Context, state SagaState, reason string) ([]Event, error) {
+	state.Status = SagaCompensating
+	state.Error = reason
+	state.Step--
+
+	return s.runCompensation(ctx, state)
+}
+
+// runCompensation sends the compensation of the current step, skipping the
+// steps without one
+func (s *Saga) runCompensation(ctx context.Context, state SagaState) ([]Event, error) {
+	for state.Step >= 0 && s.Definition.Steps[state.Step].Compensation == "" {
+		state.Step--
+	}
+
+	if state.Step < 0 {
+		state.Step = 0
+		state.Status = SagaCompensated
+		state.Deadline = time.Time{}
+		return nil, s.save(ctx, state)
+	}
+
+	step := s.Definition.Steps[state.Step]
+	state.EventID = RandomID()
+	state.Deadline = s.deadline(step.Timeout)
+
+	if err := s.save(ctx, state); err != nil {
+		return nil, err
+	}
+
+	return []Event{{
+		Name:    step.Compensation,
+		Version: step.CompensationVersion,
+		ID:      state.EventID,
+		FlowID:  state.FlowID,
+		Payload: state.Responses[state.Step],
+	}}, nil
+}
+
+func (s *Saga) deadline(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	return s.Now().Add(timeout)
+}
+
+func (s *Saga) save(ctx context.Context, state SagaState) error {
+	state.UpdatedAt = s.Now()
+	return s.Store.Save(ctx, state)
+}
+
+func errorMessage(event Event) string {
+	payload := errorPayload{}
+
+	if err := json.Unmarshal(event.Payload, &payload); err != nil || payload.Message == "" {
+		return string(event.Payload)
+	}
+
+	return payload.Message
+}
+
+// MemorySagaStore - A SagaStore kept in memory
+type MemorySagaStore struct {
+	mu     sync.Mutex
+	states map[string]SagaState
+}
+
+// NewMemorySagaStore returns an empty MemorySagaStore
+func NewMemorySagaStore() *MemorySagaStore {
+	return &MemorySagaStore{states: map[string]SagaState{}}
+}
+
+// Load implements SagaStore
+func (s *MemorySagaStore) Load(_ context.Context, flowID string) (SagaState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.states[flowID]
+	if !ok {
+		return state, ErrSagaNotFound
+	}
+
+	return state, nil
+}
+
+// Save implements SagaStore
+func (s *MemorySagaStore) Save(_ context.Context, state SagaState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state.Responses = append([]json.RawMessage{}, state.Responses...)
+	s.states[state.FlowID] = state
+
+	return nil
+}
+
+// Expired implements SagaStore
+func (s *MemorySagaStore) Expired(_ context.Context, now time.Time) ([]SagaState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expired := []SagaState{}
+
+	for _, state := range s.states {
+		if state.Deadline.IsZero() || state.Deadline.After(now) {
+			continue
+		}
+
+		if state.Status == SagaRunning || state.Status == SagaCompensating {
+			expired = append(expired, state)
+		}
+	}
+
+	return expired, nil
+}
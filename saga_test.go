@@ -0,0 +1,283 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type mockSagaSender struct {
+	sent []Event
+}
+
+func (s *mockSagaSender) Send(_ context.Context, event Event) error {
+	s.sent = append(s.sent, event)
+	return nil
+}
+
+func (s *mockSagaSender) names() []string {
+	names := []string{}
+	for _, event := range s.sent {
+		names = append(names, event.Name)
+	}
+	return names
+}
+
+var orderSaga = SagaDefinition{
+	Name: "order",
+	Steps: []SagaStep{
+		{Name: "stock.reserve", Version: 1, Compensation: "stock.release", CompensationVersion: 1, Timeout: time.Minute},
+		{Name: "order.notify", Version: 1},
+		{Name: "payment.charge", Version: 1, Compensation: "payment.refund", CompensationVersion: 1, Timeout: time.Minute},
+		{Name: "order.ship", Version: 1},
+	},
+}
+
+func respond(t *testing.T, saga *Saga, request Event, payload interface{}) {
+	response, err := NewResponse(request, payload)
+	if err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	if err := saga.Handle(context.Background(), WithMetadataField(response, MetadataRequestID, request.ID)); err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+}
+
+func fail(t *testing.T, saga *Saga, request Event, message string) {
+	response := WithMetadataField(NewError(request.FlowID, message), MetadataRequestID, request.ID)
+
+	if err := saga.Handle(context.Background(), response); err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+}
+
+func assertSagaStatus(t *testing.T, store SagaStore, flowID string, status SagaStatus) SagaState {
+	state, err := store.Load(context.Background(), flowID)
+	if err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	if state.Status != status {
+		t.Errorf("state.Status == %s, wants: %s", state.Status, status)
+	}
+
+	return state
+}
+
+func assertNames(t *testing.T, names []string, wants ...string) {
+	if len(names) != len(wants) {
+		t.Fatalf("names == %v, wants: %v", names, wants)
+	}
+
+	for i := range wants {
+		if names[i] != wants[i] {
+			t.Errorf("names[%d] == %s, wants: %s", i, names[i], wants[i])
+		}
+	}
+}
+
+func Test_Saga_Completed(t *testing.T) {
+	sender := &mockSagaSender{}
+	store := NewMemorySagaStore()
+	saga := NewSaga(orderSaga, store, sender.Send)
+	ctx := context.Background()
+
+	if err := saga.Start(ctx, "flow-1", map[string]string{"order": "42"}); err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	if err := saga.Start(ctx, "flow-1", nil); err == nil {
+		t.Error("Error expected starting a saga twice")
+	}
+
+	for i := range orderSaga.Steps {
+		assertSagaStatus(t, store, "flow-1", SagaRunning)
+		respond(t, saga, sender.sent[i], map[string]int{"step": i})
+	}
+
+	state := assertSagaStatus(t, store, "flow-1", SagaCompleted)
+	assertNames(t, sender.names(), "stock.reserve", "order.notify", "payment.charge", "order.ship")
+
+	if len(state.Responses) != 4 {
+		t.Errorf("len(state.Responses) == %d, wants: %d", len(state.Responses), 4)
+	}
+
+	for _, event := range sender.sent {
+		if event.FlowID != "flow-1" {
+			t.Errorf("event.FlowID == %s, wants: %s", event.FlowID, "flow-1")
+		}
+		if string(event.Payload) != `{"order":"42"}` {
+			t.Errorf("event.Payload == %s, wants: %s", event.Payload, `{"order":"42"}`)
+		}
+	}
+}
+
+func Test_Saga_Compensation(t *testing.T) {
+	sender := &mockSagaSender{}
+	store := NewMemorySagaStore()
+	saga := NewSaga(orderSaga, store, sender.Send)
+	ctx := context.Background()
+
+	saga.Start(ctx, "flow-1", nil)
+	respond(t, saga, sender.sent[0], "reserved")
+	respond(t, saga, sender.sent[1], "notified")
+
+	// Errors not answering the pending step are ignored
+	saga.Handle(ctx, NewError("flow-1", "unrelated"))
+	fail(t, saga, sender.sent[0], "late")
+	assertSagaStatus(t, store, "flow-1", SagaRunning)
+
+	fail(t, saga, sender.sent[2], "card declined")
+
+	state := assertSagaStatus(t, store, "flow-1", SagaCompensating)
+	if state.Error != "card declined" {
+		t.Errorf("state.Error == %s, wants: %s", state.Error, "card declined")
+	}
+
+	// order.notify has no compensation, stock.release receives the reserve response
+	assertNames(t, sender.names(), "stock.reserve", "order.notify", "payment.charge", "stock.release")
+
+	release := sender.sent[3]
+	if string(release.Payload) != `"reserved"` {
+		t.Errorf("release.Payload == %s, wants: %s", release.Payload, `"reserved"`)
+	}
+
+	respond(t, saga, release, nil)
+	assertSagaStatus(t, store, "flow-1", SagaCompensated)
+
+	// Late events of a finished saga are ignored
+	fail(t, saga, release, "late")
+	if len(sender.sent) != 4 {
+		t.Errorf("len(sender.sent) == %d, wants: %d", len(sender.sent), 4)
+	}
+}
+
+func Test_Saga_CompensationFailed(t *testing.T) {
+	sender := &mockSagaSender{}
+	store := NewMemorySagaStore()
+	saga := NewSaga(orderSaga, store, sender.Send)
+	ctx := context.Background()
+
+	saga.Start(ctx, "flow-1", nil)
+	respond(t, saga, sender.sent[0], nil)
+	respond(t, saga, sender.sent[1], nil)
+	fail(t, saga, sender.sent[2], "card declined")
+	fail(t, saga, sender.sent[3], "stock offline")
+
+	state := assertSagaStatus(t, store, "flow-1", SagaFailed)
+	wants := `card declined; compensation of "stock.reserve" failed: stock offline`
+	if state.Error != wants {
+		t.Errorf("state.Error == %s, wants: %s", state.Error, wants)
+	}
+}
+
+func Test_Saga_Timeout(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	sender := &mockSagaSender{}
+	store := NewMemorySagaStore()
+	saga := NewSaga(orderSaga, store, sender.Send)
+	saga.Now = func() time.Time { return now }
+	ctx := context.Background()
+
+	saga.Start(ctx, "flow-1", nil)
+	respond(t, saga, sender.sent[0], nil)
+	respond(t, saga, sender.sent[1], nil)
+
+	now = now.Add(30 * time.Second)
+	if err := saga.CheckTimeouts(ctx); err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+	assertSagaStatus(t, store, "flow-1", SagaRunning)
+
+	now = now.Add(time.Minute)
+	if err := saga.CheckTimeouts(ctx); err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	state := assertSagaStatus(t, store, "flow-1", SagaCompensating)
+	if state.Error != `step "payment.charge" timed out` {
+		t.Errorf("state.Error == %s, wants: %s", state.Error, `step "payment.charge" timed out`)
+	}
+	assertNames(t, sender.names(), "stock.reserve", "order.notify", "payment.charge", "stock.release")
+
+	// A late error of the timed out step does not stop the compensation
+	fail(t, saga, sender.sent[2], "card declined")
+	assertSagaStatus(t, store, "flow-1", SagaCompensating)
+
+	respond(t, saga, sender.sent[3], nil)
+	assertSagaStatus(t, store, "flow-1", SagaCompensated)
+}
+
+func Test_Saga_CompensationTimeout(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	sender := &mockSagaSender{}
+	store := NewMemorySagaStore()
+	saga := NewSaga(orderSaga, store, sender.Send)
+	saga.Now = func() time.Time { return now }
+	ctx := context.Background()
+
+	saga.Start(ctx, "flow-1", nil)
+	respond(t, saga, sender.sent[0], nil)
+	respond(t, saga, sender.sent[1], nil)
+
+	now = now.Add(2 * time.Minute)
+	saga.CheckTimeouts(ctx)
+	assertSagaStatus(t, store, "flow-1", SagaCompensating)
+
+	now = now.Add(2 * time.Minute)
+	saga.CheckTimeouts(ctx)
+	assertSagaStatus(t, store, "flow-1", SagaFailed)
+}
+
+func Test_Saga_IgnoresUnknownFlows(t *testing.T) {
+	sender := &mockSagaSender{}
+	store := NewMemorySagaStore()
+	saga := NewSaga(orderSaga, store, sender.Send)
+	ctx := context.Background()
+
+	if err := saga.Handle(ctx, NewError("unknown", "boom")); err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	other := NewSaga(SagaDefinition{Name: "other", Steps: orderSaga.Steps}, store, sender.Send)
+	other.Start(ctx, "flow-2", nil)
+
+	if err := saga.Handle(ctx, NewError("flow-2", "boom")); err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+	assertSagaStatus(t, store, "flow-2", SagaRunning)
+}
+
+func Test_Saga_Bus(t *testing.T) {
+	bus := NewBus()
+	store := NewMemorySagaStore()
+	saga := NewSaga(orderSaga, store, bus.Publish)
+
+	for _, step := range orderSaga.Steps {
+		bus.Subscribe(step.Name, HandlerFunc(func(ctx context.Context, event Event) (Event, error) {
+			response, err := NewResponse(event, nil)
+			if err != nil {
+				return Event{}, err
+			}
+			return Event{}, bus.Publish(ctx, WithMetadataField(response, MetadataRequestID, event.ID))
+		}))
+		bus.Subscribe(step.Name+":response", saga)
+	}
+
+	done := make(chan error)
+	go func() { done <- saga.Start(context.Background(), "flow-1", nil) }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Error not expected: \"%s\"", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Saga blocked delivering events synchronously")
+	}
+
+	assertSagaStatus(t, store, "flow-1", SagaCompleted)
+}
@@ -0,0 +1,451 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MetadataScheduledAt is the metadata field with the time a scheduled event
+// was due. With the event ID it identifies an occurrence of a recurring
+// schedule.
+const MetadataScheduledAt = "scheduledAt"
+
+// ErrScheduleNotFound is returned for unknown scheduled event IDs
+var ErrScheduleNotFound = errors.New("events: scheduled event not found")
+
+// ScheduledEvent - An event waiting for delivery, keyed by the event ID.
+// Recurring events have a Cron expression and At is their next occurrence.
+type ScheduledEvent struct {
+	ID    string    `json:"id"`
+	Event Event     `json:"event"`
+	At    time.Time `json:"at"`
+	Cron  string    `json:"cron,omitempty"`
+
+	// Attempts and LastError describe the failed deliveries of the
+	// current occurrence
+	Attempts  int    `json:"attempts,omitempty"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+// ScheduleStore - Where a Scheduler keeps the scheduled events
+type ScheduleStore interface {
+	// Save adds or replaces the scheduled event with the same ID
+	Save(context.Context, ScheduledEvent) error
+	Remove(ctx context.Context, id string) error
+	// Due returns the scheduled events at or before now, oldest first
+	Due(ctx context.Context, now time.Time) ([]ScheduledEvent, error)
+	List(context.Context) ([]ScheduledEvent, error)
+}
+
+// Scheduler delivers scheduled events to the handlers of a Mux. Events are
+// removed from the Store only after their handlers succeed, so delivery is
+// at least once. Failed deliveries are retried following Retry and, when
+// every attempt fails, added to DeadLetters.
+type Scheduler struct {
+	Mux   *Mux
+	Store ScheduleStore
+
+	// Now is the clock of the schedules
+	Now func() time.Time
+
+	// Interval between the checks for due events of Run
+	Interval time.Duration
+
+	Retry       RetryPolicy
+	DeadLetters DeadLetterStore
+
+	// OnError is called with the failed deliveries, it may be nil
+	OnError func(ScheduledEvent, error)
+
+	mu sync.Mutex
+}
+
+// NewScheduler returns a Scheduler checking store every second. Failed
+// deliveries are retried up to 5 times, from 1 second up to 1 minute apart.
+func NewScheduler(mux *Mux, store ScheduleStore) *Scheduler {
+	return &Scheduler{
+		Mux:      mux,
+		Store:    store,
+		Now:      time.Now,
+		Interval: time.Second,
+		Retry: RetryPolicy{
+			MaxAttempts:    5,
+			InitialBackoff: time.Second,
+			MaxBackoff:     time.Minute,
+		},
+	}
+}
+
+// Schedule delivers event at the given time and returns its ID, a random ID
+// is set on events without one
+func (s *Scheduler) Schedule(ctx context.Context, event Event, at time.Time) (string, error) {
+	if event.ID == "" {
+		event.ID = RandomID()
+	}
+
+	return event.ID, s.Store.Save(ctx, ScheduledEvent{ID: event.ID, Event: event, At: at})
+}
+
+// ScheduleAfter delivers event after delay
+func (s *Scheduler) ScheduleAfter(ctx context.Context, event Event, delay time.Duration) (string, error) {
+	return s.Schedule(ctx, event, s.Now().Add(delay))
+}
+
+// ScheduleCron delivers event at every time matching the cron expression,
+// see Cron
+func (s *Scheduler) ScheduleCron(ctx context.Context, event Event, expr string) (string, error) {
+	cron, err := ParseCron(expr)
+	if err != nil {
+		return "", err
+	}
+
+	at := cron.Next(s.Now())
+	if at.IsZero() {
+		return "", fmt.Errorf("events: cron %q never matches", expr)
+	}
+
+	if event.ID == "" {
+		event.ID = RandomID()
+	}
+
+	return event.ID, s.Store.Save(ctx, ScheduledEvent{ID: event.ID, Event: event, At: at, Cron: expr})
+}
+
+// Cancel removes a scheduled event. It waits for a running delivery, so a
+// recurring event is not scheduled again after being cancelled. Handlers of
+// scheduled events must not call it.
+func (s *Scheduler) Cancel(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.Store.Remove(ctx, id)
+}
+
+// Run delivers the due events every Interval until ctx ends
+func (s *Scheduler) Run(ctx context.Context) error {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Fire(ctx); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Fire delivers the events due now and returns how many were delivered.
+// Handler failures are not returned, only store failures.
+func (s *Scheduler) Fire(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.Now()
+
+	due, err := s.Store.Due(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+
+	for _, scheduled := range due {
+		if err := ctx.Err(); err != nil {
+			return delivered, err
+		}
+
+		ok, err := s.deliver(ctx, scheduled, now)
+		if err != nil {
+			return delivered, err
+		}
+		if ok {
+			delivered++
+		}
+	}
+
+	return delivered, nil
+}
+
+func (s *Scheduler) deliver(ctx context.Context, scheduled ScheduledEvent, now time.Time) (bool, error) {
+	event := WithMetadataField(scheduled.Event, MetadataScheduledAt, scheduled.At)
+
+	err := s.dispatch(ctx, event)
+	if err == nil {
+		return true, s.next(ctx, scheduled, now)
+	}
+
+	s.Mux.tracer.NoticeEventError(ctx, event, err)
+
+	scheduled.Attempts++
+	scheduled.LastError = err.Error()
+
+	if s.OnError != nil {
+		s.OnError(scheduled, err)
+	}
+
+	if s.Retry.ShouldRetry(scheduled.Attempts, err) {
+		scheduled.At = now.Add(s.Retry.Backoff(scheduled.Attempts))
+		return false, s.Store.Save(ctx, scheduled)
+	}
+
+	if s.DeadLetters != nil {
+		if err := s.DeadLetters.Add(ctx, NewDeadLetter("schedule", event, err, scheduled.Attempts)); err != nil {
+			return false, err
+		}
+	}
+
+	return false, s.next(ctx, scheduled, now)
+}
+
+func (s *Scheduler) dispatch(ctx context.Context, event Event) error {
+	handler, ok := s.Mux.get(event.Name, event.Version)
+	if !ok {
+		return Permanent(fmt.Errorf("%w: %q version %d", ErrEventNotFound, event.Name, event.Version))
+	}
+
+	_, err := handler.Serve(ctx, event)
+	return err
+}
+
+// next removes a delivered event or moves a recurring one to its next
+// occurrence after now, skipping the ones missed while not running
+func (s *Scheduler) next(ctx context.Context, scheduled ScheduledEvent, now time.Time) error {
+	if scheduled.Cron == "" {
+		return s.Store.Remove(ctx, scheduled.ID)
+	}
+
+	cron, err := ParseCron(scheduled.Cron)
+	if err != nil {
+		return err
+	}
+
+	scheduled.At = cron.Next(now)
+	scheduled.Attempts = 0
+	scheduled.LastError = ""
+
+	if scheduled.At.IsZero() {
+		return s.Store.Remove(ctx, scheduled.ID)
+	}
+
+	return s.Store.Save(ctx, scheduled)
+}
+
+// MemoryScheduleStore - A ScheduleStore kept in memory
+type MemoryScheduleStore struct {
+	mu        sync.Mutex
+	scheduled map[string]ScheduledEvent
+}
+
+// NewMemoryScheduleStore returns an empty MemoryScheduleStore
+func NewMemoryScheduleStore() *MemoryScheduleStore {
+	return &MemoryScheduleStore{scheduled: map[string]ScheduledEvent{}}
+}
+
+// Save implements ScheduleStore
+func (s *MemoryScheduleStore) Save(_ context.Context, scheduled ScheduledEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.scheduled[scheduled.ID] = scheduled
+	return nil
+}
+
+// Remove implements ScheduleStore
+func (s *MemoryScheduleStore) Remove(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.scheduled[id]; !ok {
+		return ErrScheduleNotFound
+	}
+
+	delete(s.scheduled, id)
+	return nil
+}
+
+// Due implements ScheduleStore
+func (s *MemoryScheduleStore) Due(_ context.Context, now time.Time) ([]ScheduledEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return dueEvents(s.scheduled, now), nil
+}
+
+// List implements ScheduleStore
+func (s *MemoryScheduleStore) List(context.Context) ([]ScheduledEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return sortedSchedule(s.scheduled), nil
+}
+
+// FileScheduleStore - A ScheduleStore in a JSON file, rewritten on every
+// change
+type FileScheduleStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileScheduleStore returns a FileScheduleStore using path, the file is
+// created on the first Save
+func NewFileScheduleStore(path string) *FileScheduleStore {
+	return &FileScheduleStore{path: path}
+}
+
+// Save implements ScheduleStore
+func (s *FileScheduleStore) Save(_ context.Context, scheduled ScheduledEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	all[scheduled.ID] = scheduled
+	return s.write(all)
+}
+
+// Remove implements ScheduleStore
+func (s *FileScheduleStore) Remove(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := all[id]; !ok {
+		return ErrScheduleNotFound
+	}
+
+	delete(all, id)
+	return s.write(all)
+}
+
+// Due implements ScheduleStore
+func (s *FileScheduleStore) Due(_ context.Context, now time.Time) ([]ScheduledEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	return dueEvents(all, now), nil
+}
+
+// List implements ScheduleStore
+func (s *FileScheduleStore) List(context.Context) ([]ScheduledEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	return sortedSchedule(all), nil
+}
+
+func (s *FileScheduleStore) read() (map[string]ScheduledEvent, error) {
+	all := map[string]ScheduledEvent{}
+
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list := []ScheduledEvent{}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("%s: %s", s.path, err)
+	}
+
+	for _, scheduled := range list {
+		all[scheduled.ID] = scheduled
+	}
+
+	return all, nil
+}
+
+// write replaces the file atomically, so a crash keeps the previous version
+func (s *FileScheduleStore) write(all map[string]ScheduledEvent) error {
+	data, err := json.MarshalIndent(sortedSchedule(all), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.Create(s.path + ".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func dueEvents(all map[string]ScheduledEvent, now time.Time) []ScheduledEvent {
+	due := []ScheduledEvent{}
+
+	for _, scheduled := range sortedSchedule(all) {
+		if !scheduled.At.After(now) {
+			due = append(due, scheduled)
+		}
+	}
+
+	return due
+}
+
+func sortedSchedule(all map[string]ScheduledEvent) []ScheduledEvent {
+	list := make([]ScheduledEvent, 0, len(all))
+	for _, scheduled := range all {
+		list = append(list, scheduled)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].At.Equal(list[j].At) {
+			return list[i].ID < list[j].ID
+		}
+		return list[i].At.Before(list[j].At)
+	})
+
+	return list
+}
@@ -0,0 +1,229 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_ParseCron_Next(t *testing.T) {
+	// Sunday
+	from := time.Date(2026, 10, 18, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2026, 10, 18, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 10, 18, 10, 15, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)},
+		{"30 8,20 * * *", time.Date(2026, 10, 18, 20, 30, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 13 * 5", time.Date(2026, 10, 23, 12, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 10, 25, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 10, 18, 11, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+
+	for _, test := range tests {
+		cron, err := ParseCron(test.expr)
+		if err != nil {
+			t.Errorf("Error not expected for %q: \"%s\"", test.expr, err)
+			continue
+		}
+
+		if next := cron.Next(from); !next.Equal(test.next) {
+			t.Errorf("Next(%q) == %s, wants: %s", test.expr, next, test.next)
+		}
+	}
+
+	for _, expr := range []string{"* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "* * * foo *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("Error expected for %q", expr)
+		}
+	}
+}
+
+type mockClock struct {
+	now time.Time
+}
+
+func (c *mockClock) Now() time.Time {
+	return c.now
+}
+
+func newMockScheduler(store ScheduleStore) (*Scheduler, *mockClock, *mockSubscriber) {
+	clock := &mockClock{time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)}
+	handler := &mockSubscriber{}
+
+	mux := NewMux()
+	mux.Add("invoice.remind", 1, handler)
+
+	scheduler := NewScheduler(mux, store)
+	scheduler.Now = clock.Now
+
+	return scheduler, clock, handler
+}
+
+func Test_Scheduler_Delayed(t *testing.T) {
+	for name, store := range map[string]ScheduleStore{
+		"memory": NewMemoryScheduleStore(),
+		"file":   NewFileScheduleStore(filepath.Join(t.TempDir(), "schedule.json")),
+	} {
+		t.Run(name, func(t *testing.T) {
+			scheduler, clock, handler := newMockScheduler(store)
+			ctx := context.Background()
+
+			id, err := scheduler.ScheduleAfter(ctx, Event{Name: "invoice.remind", Version: 1, FlowID: "flow"}, 72*time.Hour)
+			if err != nil {
+				t.Fatalf("Error not expected: \"%s\"", err)
+			}
+			if id == "" {
+				t.Error("Scheduled event without ID")
+			}
+
+			clock.now = clock.now.Add(71 * time.Hour)
+			if n, _ := scheduler.Fire(ctx); n != 0 {
+				t.Errorf("n == %d, wants: %d", n, 0)
+			}
+
+			clock.now = clock.now.Add(time.Hour)
+			if n, err := scheduler.Fire(ctx); n != 1 || err != nil {
+				t.Fatalf("n == %d, wants: %d, err: %v", n, 1, err)
+			}
+
+			if handler.count() != 1 {
+				t.Fatalf("handler.count() == %d, wants: %d", handler.count(), 1)
+			}
+
+			event := handler.received[0]
+			if event.ID != id {
+				t.Errorf("event.ID == %s, wants: %s", event.ID, id)
+			}
+
+			scheduledAt := time.Time{}
+			MetadataField(event, MetadataScheduledAt, &scheduledAt)
+			if !scheduledAt.Equal(clock.now) {
+				t.Errorf("scheduledAt == %s, wants: %s", scheduledAt, clock.now)
+			}
+
+			if list, _ := store.List(ctx); len(list) != 0 {
+				t.Errorf("len(list) == %d, wants: %d", len(list), 0)
+			}
+		})
+	}
+}
+
+func Test_Scheduler_Cron(t *testing.T) {
+	scheduler, clock, handler := newMockScheduler(NewMemoryScheduleStore())
+	ctx := context.Background()
+
+	id, err := scheduler.ScheduleCron(ctx, Event{Name: "invoice.remind", Version: 1}, "0 * * * *")
+	if err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	if _, err := scheduler.ScheduleCron(ctx, Event{Name: "invoice.remind", Version: 1}, "bad"); err == nil {
+		t.Error("Error expected with an invalid expression")
+	}
+
+	for i := 0; i < 3; i++ {
+		clock.now = clock.now.Add(time.Hour)
+		scheduler.Fire(ctx)
+	}
+
+	// Missed occurrences are not delivered
+	clock.now = clock.now.Add(5 * time.Hour)
+	scheduler.Fire(ctx)
+
+	if handler.count() != 4 {
+		t.Errorf("handler.count() == %d, wants: %d", handler.count(), 4)
+	}
+
+	list, _ := scheduler.Store.List(ctx)
+	if len(list) != 1 || !list[0].At.Equal(clock.now.Add(time.Hour)) {
+		t.Fatalf("list == %v, wants the next hour", list)
+	}
+
+	if err := scheduler.Cancel(ctx, id); err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	if err := scheduler.Cancel(ctx, id); err != ErrScheduleNotFound {
+		t.Errorf("err == %v, wants: %v", err, ErrScheduleNotFound)
+	}
+
+	clock.now = clock.now.Add(time.Hour)
+	scheduler.Fire(ctx)
+
+	if handler.count() != 4 {
+		t.Errorf("handler.count() == %d, wants: %d", handler.count(), 4)
+	}
+}
+
+func Test_Scheduler_Retry(t *testing.T) {
+	scheduler, clock, handler := newMockScheduler(NewMemoryScheduleStore())
+	scheduler.Retry = RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Minute}
+	scheduler.DeadLetters = NewMemoryDeadLetterStore()
+	handler.err = errors.New("smtp down")
+
+	failures := 0
+	scheduler.OnError = func(ScheduledEvent, error) { failures++ }
+
+	ctx := context.Background()
+	scheduler.Schedule(ctx, Event{Name: "invoice.remind", Version: 1}, clock.now)
+
+	scheduler.Fire(ctx)
+
+	list, _ := scheduler.Store.List(ctx)
+	if len(list) != 1 || list[0].Attempts != 1 || list[0].LastError != "smtp down" {
+		t.Fatalf("list == %v, wants one failed attempt", list)
+	}
+
+	// Waiting for the backoff
+	scheduler.Fire(ctx)
+	clock.now = clock.now.Add(time.Minute)
+	scheduler.Fire(ctx)
+
+	if failures != 2 {
+		t.Errorf("failures == %d, wants: %d", failures, 2)
+	}
+
+	if list, _ := scheduler.Store.List(ctx); len(list) != 0 {
+		t.Errorf("len(list) == %d, wants: %d", len(list), 0)
+	}
+
+	deadLetters, _ := scheduler.DeadLetters.List(ctx)
+	if len(deadLetters) != 1 || deadLetters[0].Attempts != 2 {
+		t.Errorf("deadLetters == %v, wants one with 2 attempts", deadLetters)
+	}
+
+	// Unknown events are not retried
+	scheduler.Schedule(ctx, Event{Name: "unknown", Version: 1}, clock.now)
+	scheduler.Fire(ctx)
+
+	if list, _ := scheduler.Store.List(ctx); len(list) != 0 {
+		t.Errorf("len(list) == %d, wants: %d", len(list), 0)
+	}
+}
+
+func Test_FileScheduleStore_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedule.json")
+	ctx := context.Background()
+	at := time.Date(2026, 10, 21, 10, 0, 0, 0, time.UTC)
+
+	scheduler, _, _ := newMockScheduler(NewFileScheduleStore(path))
+	scheduler.Schedule(ctx, Event{Name: "invoice.remind", Version: 1, ID: "remind-1"}, at)
+
+	list, err := NewFileScheduleStore(path).List(ctx)
+	if err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	if len(list) != 1 || list[0].ID != "remind-1" || !list[0].At.Equal(at) {
+		t.Errorf("list == %v, wants remind-1 at %s", list, at)
+	}
+}
@@ -0,0 +1,74 @@
+package events
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/alecthomas/jsonschema"
+)
+
+// SchemaDocument - A machine readable description of the events of a Mux
+type SchemaDocument struct {
+	Events []EventSchema `json:"events"`
+}
+
+// EventSchema - Description of a single event. Input, Output and the
+// examples are only filled for handlers implementing EventDoc
+type EventSchema struct {
+	Name    string `json:"name"`
+	Version int    `json:"version"`
+	Doc     string `json:"doc,omitempty"`
+
+	Input  json.RawMessage `json:"input,omitempty"`
+	Output json.RawMessage `json:"output,omitempty"`
+
+	InputExample  json.RawMessage `json:"inputExample,omitempty"`
+	OutputExample json.RawMessage `json:"outputExample,omitempty"`
+
+	// Encrypted are the JSON pointers of the input fields encrypted by
+	// EncryptFields
+	Encrypted []string `json:"encrypted,omitempty"`
+}
+
+// Schema returns the description of every registered event, sorted by
+// name and version
+func (m *Mux) Schema() SchemaDocument {
+	document := SchemaDocument{Events: []EventSchema{}}
+
+	for key, handler := range m.events {
+		eventSchema := EventSchema{
+			Name:    key.Name,
+			Version: key.Version,
+		}
+
+		if eventWithDoc, ok := handler.(EventDoc); ok {
+			eventSchema.Doc = eventWithDoc.Doc()
+			eventSchema.Input, _ = json.Marshal(jsonschema.Reflect(eventWithDoc.Input()))
+			eventSchema.Output, _ = json.Marshal(jsonschema.Reflect(eventWithDoc.Output()))
+			eventSchema.Encrypted = EncryptedFields(eventWithDoc.Input())
+
+			inputExample, outputExample := eventWithDoc.Example()
+			eventSchema.InputExample, _ = json.Marshal(inputExample)
+			eventSchema.OutputExample, _ = json.Marshal(outputExample)
+		}
+
+		document.Events = append(document.Events, eventSchema)
+	}
+
+	sort.Slice(document.Events, func(i, j int) bool {
+		if document.Events[i].Name == document.Events[j].Name {
+			return document.Events[i].Version < document.Events[j].Version
+		}
+		return document.Events[i].Name < document.Events[j].Name
+	})
+
+	return document
+}
+
+// ServeSchema - Serves the SchemaDocument as JSON
+func (m *Mux) ServeSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	json.NewEncoder(w).Encode(m.Schema())
+}
@@ -0,0 +1,300 @@
+package events
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// MetadataSignature is the metadata field with the Signature of an event
+const MetadataSignature = "signature"
+
+// ErrorCodeInvalidSignature is the code of the error events of events
+// rejected by VerifySignatures
+const ErrorCodeInvalidSignature = 7
+
+// Signature algorithms
+const (
+	SignatureHMACSHA256 = "HS256"
+	SignatureEd25519    = "EdDSA"
+)
+
+// Signature verification errors
+var (
+	ErrUnsignedEvent    = errors.New("events: event is not signed")
+	ErrUnknownSignKey   = errors.New("events: unknown signature key")
+	ErrInvalidSignature = errors.New("events: invalid signature")
+)
+
+// Signature - The signature of an event, stored in its metadata. Fields
+// are the metadata fields signed along with the name, version, id, flowId
+// and payload of the event.
+type Signature struct {
+	Algorithm string   `json:"alg"`
+	KeyID     string   `json:"kid"`
+	Fields    []string `json:"fields,omitempty"`
+	Value     []byte   `json:"value"`
+}
+
+// SigningKey - A key signing events
+type SigningKey interface {
+	KeyID() string
+	Algorithm() string
+	Sign(data []byte) ([]byte, error)
+}
+
+// VerifyingKey - A key checking the signatures of a SigningKey with the
+// same ID
+type VerifyingKey interface {
+	KeyID() string
+	Algorithm() string
+	Verify(data, signature []byte) bool
+}
+
+// HMACKey - A shared secret signing and verifying with HMAC-SHA256
+type HMACKey struct {
+	ID     string
+	Secret []byte
+}
+
+// KeyID implements SigningKey and VerifyingKey
+func (k HMACKey) KeyID() string { return k.ID }
+
+// Algorithm implements SigningKey and VerifyingKey
+func (k HMACKey) Algorithm() string { return SignatureHMACSHA256 }
+
+// Sign implements SigningKey
+func (k HMACKey) Sign(data []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, k.Secret)
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+// Verify implements VerifyingKey
+func (k HMACKey) Verify(data, signature []byte) bool {
+	expected, _ := k.Sign(data)
+	return hmac.Equal(expected, signature)
+}
+
+// Ed25519PrivateKey - An Ed25519 key signing events
+type Ed25519PrivateKey struct {
+	ID  string
+	Key ed25519.PrivateKey
+}
+
+// KeyID implements SigningKey
+func (k Ed25519PrivateKey) KeyID() string { return k.ID }
+
+// Algorithm implements SigningKey
+func (k Ed25519PrivateKey) Algorithm() string { return SignatureEd25519 }
+
+// Sign implements SigningKey
+func (k Ed25519PrivateKey) Sign(data []byte) ([]byte, error) {
+	if len(k.Key) != ed25519.PrivateKeySize {
+		return nil, errors.New("events: invalid Ed25519 private key")
+	}
+
+	return ed25519.Sign(k.Key, data), nil
+}
+
+// Public returns the key verifying the signatures of k
+func (k Ed25519PrivateKey) Public() Ed25519PublicKey {
+	return Ed25519PublicKey{
+		ID:  k.ID,
+		Key: k.Key.Public().(ed25519.PublicKey),
+	}
+}
+
+// Ed25519PublicKey - An Ed25519 key verifying events
+type Ed25519PublicKey struct {
+	ID  string
+	Key ed25519.PublicKey
+}
+
+// KeyID implements VerifyingKey
+func (k Ed25519PublicKey) KeyID() string { return k.ID }
+
+// Algorithm implements VerifyingKey
+func (k Ed25519PublicKey) Algorithm() string { return SignatureEd25519 }
+
+// Verify implements VerifyingKey
+func (k Ed25519PublicKey) Verify(data, signature []byte) bool {
+	if len(k.Key) != ed25519.PublicKeySize {
+		return false
+	}
+
+	return ed25519.Verify(k.Key, data, signature)
+}
+
+// KeyRing - The VerifyingKeys accepted, by key ID. Keys are rotated by
+// adding the new key, signing with it, and removing the old key once no
+// event signed with it is in flight.
+type KeyRing struct {
+	mu   sync.RWMutex
+	keys map[string]VerifyingKey
+}
+
+// NewKeyRing returns a KeyRing with keys
+func NewKeyRing(keys ...VerifyingKey) *KeyRing {
+	ring := &KeyRing{keys: map[string]VerifyingKey{}}
+
+	for _, key := range keys {
+		ring.Add(key)
+	}
+
+	return ring
+}
+
+// Add adds key, replacing the key with the same ID
+func (r *KeyRing) Add(key VerifyingKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.keys[key.KeyID()] = key
+}
+
+// Remove removes the key with the ID
+func (r *KeyRing) Remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.keys, id)
+}
+
+// Key returns the key with the ID
+func (r *KeyRing) Key(id string) (VerifyingKey, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, ok := r.keys[id]
+	return key, ok
+}
+
+// Sign returns a copy of event with its Signature by key in the metadata.
+// The metadata fields are signed too, fields missing from the metadata are
+// signed as missing.
+func Sign(event Event, key SigningKey, fields ...string) (Event, error) {
+	signature := Signature{
+		Algorithm: key.Algorithm(),
+		KeyID:     key.KeyID(),
+	}
+
+	for _, field := range fields {
+		if field != MetadataSignature {
+			signature.Fields = append(signature.Fields, field)
+		}
+	}
+
+	data, err := canonicalEvent(event, signature)
+	if err != nil {
+		return event, err
+	}
+
+	if signature.Value, err = key.Sign(data); err != nil {
+		return event, err
+	}
+
+	return WithMetadataField(event, MetadataSignature, signature), nil
+}
+
+// VerifySignature checks the Signature of event with the key of its ID in
+// keys
+func VerifySignature(event Event, keys *KeyRing) error {
+	signature := Signature{}
+	if !MetadataField(event, MetadataSignature, &signature) || len(signature.Value) == 0 {
+		return ErrUnsignedEvent
+	}
+
+	key, ok := keys.Key(signature.KeyID)
+	if !ok {
+		return ErrUnknownSignKey
+	}
+
+	if key.Algorithm() != signature.Algorithm {
+		return ErrInvalidSignature
+	}
+
+	data, err := canonicalEvent(event, signature)
+	if err != nil || !key.Verify(data, signature.Value) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+type signatureVerifiedKey struct{}
+
+// VerifySignatures is a Middleware rejecting the events without a valid
+// Signature by keys with an ErrorCodeInvalidSignature error event. Events
+// served within a verified event, like the events of a Batch, are covered
+// by its signature and not verified again.
+func VerifySignatures(keys *KeyRing) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, event Event) (Event, error) {
+			if ctx.Value(signatureVerifiedKey{}) != nil {
+				return next.Serve(ctx, event)
+			}
+
+			if err := VerifySignature(event, keys); err != nil {
+				return NewErrorWithCode(event.FlowID, err.Error(), ErrorCodeInvalidSignature), err
+			}
+
+			return next.Serve(context.WithValue(ctx, signatureVerifiedKey{}, true), event)
+		})
+	}
+}
+
+// canonicalEvent is the JSON of the signed parts of event, with object
+// keys sorted and no insignificant whitespace. The algorithm, key ID and
+// sorted field list of signature are signed too, so none of them can be
+// changed without breaking the signature.
+func canonicalEvent(event Event, signature Signature) ([]byte, error) {
+	payload, err := canonicalJSON(event.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	all := map[string]json.RawMessage{}
+	json.Unmarshal(event.Metadata, &all)
+
+	fields := append([]string{}, signature.Fields...)
+	sort.Strings(fields)
+
+	metadata := map[string]json.RawMessage{}
+	for _, field := range fields {
+		raw, ok := all[field]
+		if !ok {
+			continue
+		}
+
+		if metadata[field], err = canonicalJSON(raw); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(struct {
+		Name      string                     `json:"name"`
+		Version   int                        `json:"version"`
+		ID        string                     `json:"id"`
+		FlowID    string                     `json:"flowId"`
+		Payload   json.RawMessage            `json:"payload"`
+		Metadata  map[string]json.RawMessage `json:"metadata"`
+		Algorithm string                     `json:"alg"`
+		KeyID     string                     `json:"kid"`
+		Fields    []string                   `json:"fields"`
+	}{event.Name, event.Version, event.ID, event.FlowID, payload, metadata, signature.Algorithm, signature.KeyID, fields})
+}
+
+func canonicalJSON(data json.RawMessage) (json.RawMessage, error) {
+	value, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(value)
+}
@@ -0,0 +1,216 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_Sign_HMAC(t *testing.T) {
+	key := HMACKey{ID: "k1", Secret: []byte("secret")}
+	keys := NewKeyRing(key)
+
+	event := Event{
+		Name:     "transfer",
+		Version:  1,
+		ID:       "id",
+		FlowID:   "flow",
+		Payload:  json.RawMessage(`{"amount":10.50,"to":"123"}`),
+		Metadata: json.RawMessage(`{"origin":"app","userId":7}`),
+	}
+
+	signed, err := Sign(event, key, "userId")
+	if err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	if err := VerifySignature(signed, keys); err != nil {
+		t.Errorf("Error not expected: \"%s\"", err)
+	}
+
+	// Formatting and unsigned metadata fields are not covered
+	reformatted := signed
+	reformatted.Payload = json.RawMessage(`{ "to": "123", "amount": 10.50 }`)
+	reformatted = WithMetadataField(reformatted, "origin", "web")
+
+	if err := VerifySignature(reformatted, keys); err != nil {
+		t.Errorf("Error not expected: \"%s\"", err)
+	}
+
+	tampered := []Event{signed, signed, signed, signed}
+	tampered[0].Payload = json.RawMessage(`{"amount":1050,"to":"123"}`)
+	tampered[1].FlowID = "other"
+	tampered[2].Version = 2
+	tampered[3] = WithMetadataField(signed, "userId", 8)
+
+	for i, event := range tampered {
+		if err := VerifySignature(event, keys); err != ErrInvalidSignature {
+			t.Errorf("tampered[%d]: err == %v, wants: %v", i, err, ErrInvalidSignature)
+		}
+	}
+
+	// The list of signed fields and the key ID are signed too, a field
+	// missing when signed can not be unlisted and then added
+	withReplyTo, _ := Sign(event, key, "userId", MetadataReplyTo)
+
+	signature := Signature{}
+	MetadataField(withReplyTo, MetadataSignature, &signature)
+
+	unlisted := signature
+	unlisted.Fields = []string{"userId"}
+
+	rekeyed := signature
+	rekeyed.KeyID = "k2"
+
+	sameSecret := NewKeyRing(key, HMACKey{ID: "k2", Secret: []byte("secret")})
+
+	for name, event := range map[string]Event{
+		"fields": WithMetadataField(WithMetadataField(withReplyTo, MetadataSignature, unlisted), MetadataReplyTo, "attacker"),
+		"kid":    WithMetadataField(withReplyTo, MetadataSignature, rekeyed),
+	} {
+		if err := VerifySignature(event, sameSecret); err != ErrInvalidSignature {
+			t.Errorf("%s: err == %v, wants: %v", name, err, ErrInvalidSignature)
+		}
+	}
+
+	if err := VerifySignature(event, keys); err != ErrUnsignedEvent {
+		t.Errorf("err == %v, wants: %v", err, ErrUnsignedEvent)
+	}
+
+	if err := VerifySignature(signed, NewKeyRing(HMACKey{ID: "k1", Secret: []byte("other")})); err != ErrInvalidSignature {
+		t.Errorf("err == %v, wants: %v", err, ErrInvalidSignature)
+	}
+}
+
+func Test_Sign_Ed25519_rotation(t *testing.T) {
+	_, oldPrivate, _ := ed25519.GenerateKey(nil)
+	_, newPrivate, _ := ed25519.GenerateKey(nil)
+
+	oldKey := Ed25519PrivateKey{ID: "2026-01", Key: oldPrivate}
+	newKey := Ed25519PrivateKey{ID: "2026-10", Key: newPrivate}
+
+	keys := NewKeyRing(oldKey.Public())
+
+	event := Event{Name: "some event", Version: 1, ID: "id", Payload: json.RawMessage(`[1,2]`)}
+
+	oldSigned, _ := Sign(event, oldKey)
+	newSigned, _ := Sign(event, newKey)
+
+	if err := VerifySignature(newSigned, keys); err != ErrUnknownSignKey {
+		t.Errorf("err == %v, wants: %v", err, ErrUnknownSignKey)
+	}
+
+	keys.Add(newKey.Public())
+
+	for _, event := range []Event{oldSigned, newSigned} {
+		if err := VerifySignature(event, keys); err != nil {
+			t.Errorf("Error not expected: \"%s\"", err)
+		}
+	}
+
+	keys.Remove(oldKey.ID)
+
+	if err := VerifySignature(oldSigned, keys); err != ErrUnknownSignKey {
+		t.Errorf("err == %v, wants: %v", err, ErrUnknownSignKey)
+	}
+
+	// The algorithm comes from the key, not from the signature
+	keys.Add(HMACKey{ID: newKey.ID, Secret: []byte("secret")})
+
+	if err := VerifySignature(newSigned, keys); err != ErrInvalidSignature {
+		t.Errorf("err == %v, wants: %v", err, ErrInvalidSignature)
+	}
+}
+
+func Test_VerifySignatures(t *testing.T) {
+	key := HMACKey{ID: "k1", Secret: []byte("secret")}
+
+	mux := NewMux()
+	mux.Add("echo", 1, HandlerFunc(mockHandlerFunc))
+	mux.Add("batch", 1, Batch(mux))
+	mux.Use(VerifySignatures(NewKeyRing(key)))
+
+	ctx := context.Background()
+	event := Event{Name: "echo", Version: 1, ID: "id", FlowID: "flow", Payload: json.RawMessage(`"hi"`)}
+
+	response, err := mux.Dispatch(ctx, event)
+	if err != ErrUnsignedEvent {
+		t.Errorf("err == %v, wants: %v", err, ErrUnsignedEvent)
+	}
+
+	payload := errorPayload{}
+	json.Unmarshal(response.Payload, &payload)
+
+	if response.Name != "error" || payload.Code != ErrorCodeInvalidSignature {
+		t.Errorf("response == %s %s, wants an error with code %d", response.Name, response.Payload, ErrorCodeInvalidSignature)
+	}
+
+	signed, _ := Sign(event, key)
+
+	response, err = mux.Dispatch(ctx, signed)
+	if err != nil || response.Name != "echo:response" {
+		t.Errorf("response.Name == %s, err == %v, wants: echo:response", response.Name, err)
+	}
+
+	// The events of a signed batch are covered by its signature
+	batch, _ := Sign(Event{
+		Name:    "batch",
+		Version: 1,
+		ID:      "batch",
+		Payload: json.RawMessage(`{"events":[{"name":"echo","version":1,"id":"a","payload":1}]}`),
+	}, key)
+
+	response, err = mux.Dispatch(ctx, batch)
+	if err != nil {
+		t.Fatalf("Error not expected: \"%s\"", err)
+	}
+
+	responses := []BatchResponse{}
+	json.Unmarshal(response.Payload, &responses)
+
+	if len(responses) != 1 || responses[0].Name != "echo:response" {
+		t.Errorf("responses == %+v, wants one echo:response", responses)
+	}
+}
+
+func Test_VerifySignatures_stream(t *testing.T) {
+	key := HMACKey{ID: "k1", Secret: []byte("secret")}
+
+	served := 0
+	mux := NewMux()
+	mux.AddStream("export", 1, StreamHandlerFunc(func(ctx context.Context, event Event, w StreamWriter) error {
+		served++
+		return w.Send(Event{Name: "page", Version: 1})
+	}))
+	mux.Use(VerifySignatures(NewKeyRing(key)))
+
+	signed, _ := Sign(Event{Name: "export", Version: 1, ID: "id", Payload: json.RawMessage(`{"account":1}`)}, key)
+
+	tampered := signed
+	tampered.Payload = json.RawMessage(`{"account":2}`)
+
+	w := httptest.NewRecorder()
+	body, _ := json.Marshal(tampered)
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/events/", bytes.NewReader(body)))
+
+	response := Event{}
+	json.NewDecoder(w.Body).Decode(&response)
+
+	payload := errorPayload{}
+	json.Unmarshal(response.Payload, &payload)
+
+	if served != 0 || response.Name != "error" || payload.Code != ErrorCodeInvalidSignature {
+		t.Errorf("served == %d, response == %s %s, wants the stream rejected with code %d", served, response.Name, response.Payload, ErrorCodeInvalidSignature)
+	}
+
+	w = httptest.NewRecorder()
+	body, _ = json.Marshal(signed)
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/events/", bytes.NewReader(body)))
+
+	if served != 1 || w.Header().Get("Content-Type") != "text/event-stream" {
+		t.Errorf("served == %d, Content-Type == %s, wants the signed event streamed", served, w.Header().Get("Content-Type"))
+	}
+}
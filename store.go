@@ -0,0 +1,230 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Expected versions of EventStore.Append with special meaning
+const (
+	// AnyVersion appends without checking the stream version
+	AnyVersion = -1
+	// NoStream expects a stream without events
+	NoStream = 0
+)
+
+// ErrWrongExpectedVersion is returned by Append when the stream changed
+var ErrWrongExpectedVersion = errors.New("events: wrong expected stream version")
+
+// RecordedEvent - An event stored in a stream. StreamVersion is the
+// position in the stream and Position the position in the whole store,
+// both start at 1.
+type RecordedEvent struct {
+	StreamID      string    `json:"streamId"`
+	StreamVersion int       `json:"streamVersion"`
+	Position      int64     `json:"position"`
+	RecordedAt    time.Time `json:"recordedAt"`
+	Event         Event     `json:"event"`
+}
+
+// EventStore - Streams of events used as the source of truth
+type EventStore interface {
+	// Append adds events to the end of a stream when its version is
+	// expectedVersion, it returns the new version. ErrWrongExpectedVersion
+	// is returned with the current version when the stream changed.
+	Append(ctx context.Context, streamID string, expectedVersion int, events ...Event) (int, error)
+	// ReadForward reads up to count events of a stream starting at
+	// fromVersion, count <= 0 reads to the end
+	ReadForward(ctx context.Context, streamID string, fromVersion, count int) ([]RecordedEvent, error)
+	// ReadBackward reads up to count events of a stream from fromVersion
+	// towards its beginning, fromVersion < 1 starts at the last event
+	ReadBackward(ctx context.Context, streamID string, fromVersion, count int) ([]RecordedEvent, error)
+	// ReadAll reads up to count events of every stream after position
+	ReadAll(ctx context.Context, afterPosition int64, count int) ([]RecordedEvent, error)
+	// LastPosition returns the position of the last stored event
+	LastPosition(ctx context.Context) (int64, error)
+	// SubscribeAll calls handler with every event after position, first the
+	// stored ones and then the new ones, until ctx ends or handler fails
+	SubscribeAll(ctx context.Context, afterPosition int64, handler func(RecordedEvent) error) error
+}
+
+// MemoryEventStore - An EventStore kept in memory
+type MemoryEventStore struct {
+	mu      sync.RWMutex
+	all     []RecordedEvent
+	streams map[string][]int
+	notify  chan struct{}
+
+	// persist is called with the new events before they become visible
+	persist func([]RecordedEvent) error
+}
+
+// NewMemoryEventStore returns an empty MemoryEventStore
+func NewMemoryEventStore() *MemoryEventStore {
+	return &MemoryEventStore{
+		streams: map[string][]int{},
+		notify:  make(chan struct{}),
+	}
+}
+
+// Append implements EventStore
+func (s *MemoryEventStore) Append(_ context.Context, streamID string, expectedVersion int, events ...Event) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	version := len(s.streams[streamID])
+
+	if expectedVersion != AnyVersion && expectedVersion != version {
+		return version, ErrWrongExpectedVersion
+	}
+
+	if len(events) == 0 {
+		return version, nil
+	}
+
+	now := time.Now().UTC()
+	recorded := make([]RecordedEvent, 0, len(events))
+
+	for i, event := range events {
+		if event.ID == "" {
+			event.ID = RandomID()
+		}
+
+		recorded = append(recorded, RecordedEvent{
+			StreamID:      streamID,
+			StreamVersion: version + i + 1,
+			Position:      int64(len(s.all) + i + 1),
+			RecordedAt:    now,
+			Event:         event,
+		})
+	}
+
+	if s.persist != nil {
+		if err := s.persist(recorded); err != nil {
+			return version, err
+		}
+	}
+
+	s.add(recorded...)
+
+	return version + len(events), nil
+}
+
+// add indexes events already recorded. Must be called with the lock held.
+func (s *MemoryEventStore) add(recorded ...RecordedEvent) {
+	for _, event := range recorded {
+		s.streams[event.StreamID] = append(s.streams[event.StreamID], len(s.all))
+		s.all = append(s.all, event)
+	}
+
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
+
+// ReadForward implements EventStore
+func (s *MemoryEventStore) ReadForward(_ context.Context, streamID string, fromVersion, count int) ([]RecordedEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stream := s.streams[streamID]
+	events := []RecordedEvent{}
+
+	if fromVersion < 1 {
+		fromVersion = 1
+	}
+
+	for i := fromVersion - 1; i < len(stream); i++ {
+		if count > 0 && len(events) == count {
+			break
+		}
+		events = append(events, s.all[stream[i]])
+	}
+
+	return events, nil
+}
+
+// ReadBackward implements EventStore
+func (s *MemoryEventStore) ReadBackward(_ context.Context, streamID string, fromVersion, count int) ([]RecordedEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stream := s.streams[streamID]
+	events := []RecordedEvent{}
+
+	if fromVersion < 1 || fromVersion > len(stream) {
+		fromVersion = len(stream)
+	}
+
+	for i := fromVersion - 1; i >= 0; i-- {
+		if count > 0 && len(events) == count {
+			break
+		}
+		events = append(events, s.all[stream[i]])
+	}
+
+	return events, nil
+}
+
+// ReadAll implements EventStore
+func (s *MemoryEventStore) ReadAll(_ context.Context, afterPosition int64, count int) ([]RecordedEvent, error) {
+	events, _ := s.readAll(afterPosition, count)
+	return events, nil
+}
+
+// LastPosition implements EventStore
+func (s *MemoryEventStore) LastPosition(context.Context) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return int64(len(s.all)), nil
+}
+
+func (s *MemoryEventStore) readAll(afterPosition int64, count int) ([]RecordedEvent, <-chan struct{}) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := []RecordedEvent{}
+
+	if afterPosition < 0 {
+		afterPosition = 0
+	}
+
+	for i := afterPosition; i < int64(len(s.all)); i++ {
+		if count > 0 && len(events) == count {
+			break
+		}
+		events = append(events, s.all[i])
+	}
+
+	return events, s.notify
+}
+
+// SubscribeAll implements EventStore
+func (s *MemoryEventStore) SubscribeAll(ctx context.Context, afterPosition int64, handler func(RecordedEvent) error) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		events, notify := s.readAll(afterPosition, 1000)
+
+		for _, event := range events {
+			if err := handler(event); err != nil {
+				return err
+			}
+			afterPosition = event.Position
+		}
+
+		if len(events) > 0 {
+			continue
+		}
+
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
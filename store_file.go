@@ -0,0 +1,111 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// FileEventStore - An EventStore persisted to a JSONL file, one recorded
+// event per line. The events are also kept in memory for reading.
+type FileEventStore struct {
+	*MemoryEventStore
+
+	file *os.File
+	// size is the length of the complete lines of file
+	size int64
+	// err is the write error that could not be rolled back, the file is
+	// no longer appended
+	err error
+}
+
+// OpenFileEventStore loads the events of path, creating it when it does not
+// exist, and appends the new events to it. A trailing line without newline,
+// left by an interrupted write, is truncated.
+func OpenFileEventStore(path string) (*FileEventStore, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &FileEventStore{
+		MemoryEventStore: NewMemoryEventStore(),
+		file:             file,
+	}
+
+	reader := bufio.NewReaderSize(file, 64*1024)
+
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		if len(bytes.TrimSpace(data)) > 0 {
+			recorded := RecordedEvent{}
+			if err := json.Unmarshal(data, &recorded); err != nil {
+				file.Close()
+				return nil, fmt.Errorf("%s:%d: %s", path, line, err)
+			}
+
+			store.add(recorded)
+		}
+
+		store.size += int64(len(data))
+	}
+
+	if err := file.Truncate(store.size); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	store.persist = store.write
+
+	return store, nil
+}
+
+func (s *FileEventStore) write(recorded []RecordedEvent) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	var buf []byte
+
+	for _, event := range recorded {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+
+	_, err := s.file.Write(buf)
+	if err == nil {
+		err = s.file.Sync()
+	}
+
+	if err != nil {
+		// The events are not appended, their partial lines must not be
+		// loaded by the next open
+		if truncateErr := s.file.Truncate(s.size); truncateErr != nil {
+			s.err = fmt.Errorf("events: event store file not rolled back after %q: %s", err, truncateErr)
+		}
+		return err
+	}
+
+	s.size += int64(len(buf))
+
+	return nil
+}
+
+// Close closes the file
+func (s *FileEventStore) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,254 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func storeEvents(names ...string) []Event {
+	events := []Event{}
+	for _, name := range names {
+		events = append(events, Event{Name: name, Version: 1, Payload: json.RawMessage(`{}`)})
+	}
+	return events
+}
+
+func testEventStore(t *testing.T, store EventStore) {
+	ctx := context.Background()
+
+	version, err := store.Append(ctx, "account-1", NoStream, storeEvents("opened", "deposited")...)
+	if err != nil || version != 2 {
+		t.Fatalf("Append == %d, %v, wants: 2, nil", version, err)
+	}
+
+	if _, err := store.Append(ctx, "account-1", NoStream, storeEvents("opened")...); err != ErrWrongExpectedVersion {
+		t.Errorf("Expecting ErrWrongExpectedVersion, got: %v", err)
+	}
+
+	store.Append(ctx, "account-2", NoStream, storeEvents("opened")...)
+
+	version, err = store.Append(ctx, "account-1", AnyVersion, storeEvents("withdrawn")...)
+	if err != nil || version != 3 {
+		t.Fatalf("Append == %d, %v, wants: 3, nil", version, err)
+	}
+
+	forward, _ := store.ReadForward(ctx, "account-1", 2, 0)
+	if len(forward) != 2 || forward[0].Event.Name != "deposited" || forward[1].StreamVersion != 3 {
+		t.Errorf("Unexpected ReadForward: %#v", forward)
+	}
+
+	if forward[1].Position != 4 || forward[1].Event.ID == "" {
+		t.Errorf("Unexpected recorded event: %#v", forward[1])
+	}
+
+	backward, _ := store.ReadBackward(ctx, "account-1", 0, 2)
+	if len(backward) != 2 || backward[0].Event.Name != "withdrawn" || backward[1].Event.Name != "deposited" {
+		t.Errorf("Unexpected ReadBackward: %#v", backward)
+	}
+
+	all, _ := store.ReadAll(ctx, 1, 2)
+	if len(all) != 2 || all[0].Position != 2 || all[1].StreamID != "account-2" {
+		t.Errorf("Unexpected ReadAll: %#v", all)
+	}
+
+	empty, _ := store.ReadForward(ctx, "missing", 1, 0)
+	if len(empty) != 0 {
+		t.Errorf("len(empty) == %d, wants: 0", len(empty))
+	}
+
+	subscribeCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	received := make(chan RecordedEvent, 10)
+	done := make(chan error)
+
+	go func() {
+		done <- store.SubscribeAll(subscribeCtx, 3, func(event RecordedEvent) error {
+			received <- event
+			if event.Position == 5 {
+				return errors.New("stop")
+			}
+			return nil
+		})
+	}()
+
+	if event := <-received; event.Position != 4 {
+		t.Errorf("First event Position == %d, wants: 4", event.Position)
+	}
+
+	store.Append(ctx, "account-2", 1, storeEvents("closed")...)
+
+	if event := <-received; event.Event.Name != "closed" {
+		t.Errorf(`Live event Name == "%s", wants: "closed"`, event.Event.Name)
+	}
+
+	if err := <-done; err == nil || err.Error() != "stop" {
+		t.Errorf("SubscribeAll must return the handler error, got: %v", err)
+	}
+}
+
+func Test_MemoryEventStore(t *testing.T) {
+	testEventStore(t, NewMemoryEventStore())
+}
+
+func Test_FileEventStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	store, err := OpenFileEventStore(path)
+	if err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+
+	testEventStore(t, store)
+	store.Close()
+
+	reopened, err := OpenFileEventStore(path)
+	if err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+	defer reopened.Close()
+
+	events, _ := reopened.ReadForward(context.Background(), "account-2", 1, 0)
+
+	if len(events) != 2 || events[1].Event.Name != "closed" {
+		t.Errorf("Events must be persisted, got: %#v", events)
+	}
+
+	version, err := reopened.Append(context.Background(), "account-2", 2, storeEvents("reopened")...)
+	if err != nil || version != 3 {
+		t.Errorf("Append == %d, %v, wants: 3, nil", version, err)
+	}
+
+	all, _ := reopened.ReadAll(context.Background(), 0, 0)
+	if all[len(all)-1].Position != 6 {
+		t.Errorf("Position == %d, wants: 6", all[len(all)-1].Position)
+	}
+}
+
+func Test_FileEventStore_torn_write(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	store, err := OpenFileEventStore(path)
+	if err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+
+	store.Append(context.Background(), "account-1", 0, storeEvents("opened")...)
+	store.Close()
+
+	// A write interrupted in the middle of a line
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	file.Write([]byte(`{"streamId":"account-1","streamVer`))
+	file.Close()
+
+	store, err = OpenFileEventStore(path)
+	if err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+
+	version, err := store.Append(context.Background(), "account-1", 1, storeEvents("deposited")...)
+	if err != nil || version != 2 {
+		t.Errorf("Append == %d, %v, wants: 2, nil", version, err)
+	}
+
+	// A failed write that cannot be rolled back stops the appends
+	size := store.size
+	writable := store.file
+	store.file, _ = os.Open(path)
+
+	if _, err := store.Append(context.Background(), "account-1", 2, storeEvents("withdrawn")...); err == nil {
+		t.Error("Error expected writing to a read-only file")
+	}
+
+	store.file.Close()
+	store.file = writable
+
+	if _, err := store.Append(context.Background(), "account-1", 2, storeEvents("withdrawn")...); err == nil {
+		t.Error("Error expected appending after a write that was not rolled back")
+	}
+
+	store.Close()
+
+	if info, _ := os.Stat(path); info.Size() != size {
+		t.Errorf("Size == %d, wants: %d", info.Size(), size)
+	}
+
+	reopened, err := OpenFileEventStore(path)
+	if err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+	defer reopened.Close()
+
+	if events, _ := reopened.ReadForward(context.Background(), "account-1", 1, 0); len(events) != 2 {
+		t.Errorf("len(events) == %d, wants: %d", len(events), 2)
+	}
+}
+
+type mockAccount struct {
+	Balance int
+	Events  int
+}
+
+func Test_Aggregate(t *testing.T) {
+	aggregate := NewAggregate()
+
+	aggregate.Add("deposited", 1, func(state interface{}, event Event) error {
+		payload := struct{ Amount int }{}
+		json.Unmarshal(event.Payload, &payload)
+
+		account := state.(*mockAccount)
+		account.Balance += payload.Amount
+		account.Events++
+		return nil
+	})
+
+	aggregate.Add("withdrawn", 1, func(state interface{}, event Event) error {
+		payload := struct{ Amount int }{}
+		json.Unmarshal(event.Payload, &payload)
+
+		account := state.(*mockAccount)
+		if account.Balance < payload.Amount {
+			return fmt.Errorf("insufficient funds")
+		}
+		account.Balance -= payload.Amount
+		account.Events++
+		return nil
+	})
+
+	ctx := context.Background()
+	store := NewMemoryEventStore()
+
+	for i := 0; i < 1500; i++ {
+		store.Append(ctx, "account-1", AnyVersion, Event{Name: "deposited", Version: 1, Payload: json.RawMessage(`{"Amount": 2}`)})
+	}
+	store.Append(ctx, "account-1", AnyVersion, Event{Name: "withdrawn", Version: 1, Payload: json.RawMessage(`{"Amount": 1000}`)})
+
+	account := &mockAccount{}
+	version, err := aggregate.Load(ctx, store, "account-1", account)
+
+	if err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+
+	if version != 1501 || account.Balance != 2000 || account.Events != 1501 {
+		t.Errorf("version == %d, account == %#v", version, account)
+	}
+
+	unknown := Event{Name: "renamed", Version: 1}
+
+	if err := aggregate.Fold(account, unknown); err == nil {
+		t.Error("Expecting an error for events without apply function")
+	}
+
+	aggregate.IgnoreUnknown = true
+
+	if err := aggregate.Fold(account, unknown); err != nil {
+		t.Errorf(`Error not expected: "%s"`, err.Error())
+	}
+}
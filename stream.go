@@ -0,0 +1,279 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Metadata fields of the events of a stream
+const (
+	MetadataSequence = "sequence"
+	MetadataTerminal = "terminal"
+)
+
+// StreamHandler Interface for event handlers responding with a stream
+// of events
+type StreamHandler interface {
+	ServeStream(context.Context, Event, StreamWriter) error
+}
+
+// StreamHandlerFunc a streaming event handler
+type StreamHandlerFunc func(context.Context, Event, StreamWriter) error
+
+// ServeStream implements StreamHandler interface
+func (h StreamHandlerFunc) ServeStream(ctx context.Context, event Event, w StreamWriter) error {
+	return h(ctx, event, w)
+}
+
+// StreamWriter sends the events of a stream. The FlowID and the sequence
+// number of every event are set by the writer.
+type StreamWriter interface {
+	Send(Event) error
+}
+
+// AddStream adds a StreamHandler into the Mux. Streams are served as
+// Server-Sent Events by ServeHTTP.
+func (m *Mux) AddStream(name string, version int, handler StreamHandler) {
+	key := eventKey{name, version}
+	m.streams[key] = handler
+	m.streamChains[key] = m.chain(streamChainHandler(handler))
+}
+
+type streamWriterKey struct{}
+
+// streamChainHandler adapts handler to the middlewares of a Mux. It starts
+// the stream of the sseWriter in the context, its response is ignored.
+func streamChainHandler(handler StreamHandler) Handler {
+	return HandlerFunc(func(ctx context.Context, event Event) (Event, error) {
+		stream := ctx.Value(streamWriterKey{}).(*sseWriter)
+		stream.start(event)
+
+		return Event{}, handler.ServeStream(ctx, event, stream)
+	})
+}
+
+type sseWriter struct {
+	w        http.ResponseWriter
+	request  Event
+	sequence int
+	started  bool
+}
+
+// start writes the headers of the stream of request
+func (s *sseWriter) start(request Event) {
+	s.w.Header().Set("Content-Type", "text/event-stream")
+	s.w.Header().Set("Cache-Control", "no-cache")
+	s.w.WriteHeader(http.StatusOK)
+
+	s.request = request
+	s.started = true
+}
+
+func (s *sseWriter) Send(event Event) error {
+	s.sequence++
+
+	if event.ID == "" {
+		event.ID = RandomID()
+	}
+	event.FlowID = s.request.FlowID
+	event = WithMetadataField(event, MetadataSequence, s.sequence)
+
+	return s.write(event)
+}
+
+func (s *sseWriter) end(err error) error {
+	var event Event
+
+	if err != nil {
+		event = NewError(s.request.FlowID, err.Error())
+	} else {
+		payload, _ := json.Marshal(map[string]int{"count": s.sequence})
+
+		event = Event{
+			Name:    fmt.Sprintf("%s:end", s.request.Name),
+			Version: s.request.Version,
+			ID:      RandomID(),
+			FlowID:  s.request.FlowID,
+			Payload: payload,
+		}
+	}
+
+	s.sequence++
+	event = WithMetadataField(event, MetadataSequence, s.sequence)
+	event = WithMetadataField(event, MetadataTerminal, true)
+
+	return s.write(event)
+}
+
+func (s *sseWriter) write(event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(s.w, "id: %d\nevent: %s\ndata: %s\n\n", s.sequence, strings.Replace(event.Name, "\n", " ", -1), data)
+	if err != nil {
+		return err
+	}
+
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+
+	return nil
+}
+
+// serveStream serves event with handler, a stream wrapped by the middlewares
+func (m *Mux) serveStream(ctx context.Context, w http.ResponseWriter, r *http.Request, event Event, handler Handler) {
+	stream := &sseWriter{w: w, request: event}
+
+	ctx = m.tracer.Start(ctx, event, w, r)
+	response, err := handler.Serve(context.WithValue(ctx, streamWriterKey{}, stream), event)
+	ctx = m.tracer.End(ctx, event, err)
+
+	if !stream.started {
+		// Stopped by a middleware
+		if err := m.writeEvent(w, r, response); err != nil {
+			m.tracer.NoticeEventError(ctx, event, err)
+		}
+		return
+	}
+
+	if err := stream.end(err); err != nil {
+		m.tracer.NoticeEventError(ctx, event, err)
+	}
+}
+
+// Stream sends an event to a StreamHandler and returns a reader of the
+// response events
+func (c *Client) Stream(ctx context.Context, event Event) (*StreamReader, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("events: %s", resp.Status)
+	}
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		// Not a stream: a single event, like an error for an unknown event
+		defer resp.Body.Close()
+
+		response := Event{}
+		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+			return nil, err
+		}
+
+		return &StreamReader{single: &response}, nil
+	}
+
+	return &StreamReader{scanner: newSSEScanner(resp.Body), body: resp.Body}, nil
+}
+
+// StreamReader reads the events of a Server-Sent Events stream
+type StreamReader struct {
+	scanner *bufio.Scanner
+	body    io.Closer
+	single  *Event
+	done    bool
+}
+
+// NewStreamReader returns a StreamReader of the Server-Sent Events in r
+func NewStreamReader(r io.Reader) *StreamReader {
+	return &StreamReader{scanner: newSSEScanner(r)}
+}
+
+func newSSEScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	return scanner
+}
+
+// Next returns the next event of the stream. The terminal event is
+// returned as any other event, after it Next returns io.EOF.
+func (s *StreamReader) Next() (Event, error) {
+	event := Event{}
+
+	if s.done {
+		return event, io.EOF
+	}
+
+	if s.single != nil {
+		s.done = true
+		return *s.single, nil
+	}
+
+	var data bytes.Buffer
+
+	for s.scanner.Scan() {
+		line := s.scanner.Text()
+
+		if line == "" {
+			if data.Len() == 0 {
+				continue
+			}
+
+			if err := json.Unmarshal(data.Bytes(), &event); err != nil {
+				return event, err
+			}
+
+			terminal := false
+			MetadataField(event, MetadataTerminal, &terminal)
+			s.done = terminal
+
+			return event, nil
+		}
+
+		if strings.HasPrefix(line, "data:") {
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+
+	if err := s.scanner.Err(); err != nil {
+		return event, err
+	}
+
+	s.done = true
+
+	return event, io.ErrUnexpectedEOF
+}
+
+// Close releases the connection of the stream
+func (s *StreamReader) Close() error {
+	if s.body == nil {
+		return nil
+	}
+	return s.body.Close()
+}
+
+// StreamSequence returns the sequence number of an event of a stream
+func StreamSequence(event Event) int {
+	sequence := 0
+	MetadataField(event, MetadataSequence, &sequence)
+	return sequence
+}
@@ -0,0 +1,142 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func mockStreamHandler(count int, err error) StreamHandlerFunc {
+	return func(ctx context.Context, event Event, w StreamWriter) error {
+		for i := 0; i < count; i++ {
+			payload, _ := json.Marshal(map[string]int{"page": i})
+
+			if err := w.Send(Event{Name: "page", Version: 1, Payload: payload}); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+}
+
+func Test_ServeHTTP_stream(t *testing.T) {
+	mockTracker := &MockTracker{
+		StartFn: func(ctx context.Context, _ Event, _ http.ResponseWriter, _ *http.Request) context.Context { return ctx },
+		EndFn:   func(ctx context.Context, _ Event, _ error) context.Context { return ctx },
+	}
+
+	mux := NewMuxWithTracker(mockTracker)
+	mux.AddStream("some event", 42, mockStreamHandler(3, nil))
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/events/", strings.NewReader(mockEvent))
+	mux.ServeHTTP(w, r)
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf(`Content-Type == "%s", wants: "text/event-stream"`, ct)
+	}
+
+	reader := NewStreamReader(w.Body)
+	received := []Event{}
+
+	for {
+		event, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf(`Error not expected: "%s"`, err.Error())
+		}
+		received = append(received, event)
+	}
+
+	if len(received) != 4 {
+		t.Fatalf("len(received) == %d, wants: %d", len(received), 4)
+	}
+
+	for i, event := range received {
+		if event.FlowID != "d00a5c99-ea0e-4b39-bfdc-bf1028a9c95f" {
+			t.Errorf("received[%d].FlowID == %s, expecting the request flowID", i, event.FlowID)
+		}
+
+		if StreamSequence(event) != i+1 {
+			t.Errorf("StreamSequence(received[%d]) == %d, wants: %d", i, StreamSequence(event), i+1)
+		}
+	}
+
+	last := received[3]
+	terminal := false
+	MetadataField(last, MetadataTerminal, &terminal)
+
+	if last.Name != "some event:end" || !terminal {
+		t.Errorf("Expecting a terminal event, got: %#v", last)
+	}
+
+	if mockTracker.StartCount != 1 || mockTracker.EndCount != 1 {
+		t.Error("Start and End must be called once")
+	}
+}
+
+func Test_Client_Stream(t *testing.T) {
+	mux := NewMux()
+	mux.AddStream("export", 1, mockStreamHandler(2, errors.New("export failed")))
+	mux.Add("single", 1, HandlerFunc(mockHandlerFunc))
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewClient(server.URL)
+
+	reader, err := client.Stream(context.Background(), Event{Name: "export", Version: 1, FlowID: "flow"})
+	if err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+	defer reader.Close()
+
+	names := []string{}
+	for {
+		event, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf(`Error not expected: "%s"`, err.Error())
+		}
+		names = append(names, event.Name)
+	}
+
+	if strings.Join(names, ",") != "page,page,error" {
+		t.Errorf("names == %v, wants: [page page error]", names)
+	}
+
+	reader, err = client.Stream(context.Background(), Event{Name: "single", Version: 1})
+	if err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+
+	event, _ := reader.Next()
+	if event.Name != "single:response" {
+		t.Errorf(`event.Name == "%s", wants: "single:response"`, event.Name)
+	}
+
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("Expecting io.EOF, got: %v", err)
+	}
+}
+
+func Test_StreamReader_unexpected_EOF(t *testing.T) {
+	reader := NewStreamReader(strings.NewReader("id: 1\ndata: {\"name\":\"page\"}\n\n"))
+
+	if _, err := reader.Next(); err != nil {
+		t.Fatalf(`Error not expected: "%s"`, err.Error())
+	}
+
+	if _, err := reader.Next(); err != io.ErrUnexpectedEOF {
+		t.Errorf("Expecting io.ErrUnexpectedEOF, got: %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package events
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// taggedPaths returns the JSON pointers of the fields of v, a struct or a
+// pointer to one, with option in their events tag, e.g.
+// `events:"encrypted"`. A "*" token stands for every element of a slice or
+// map.
+func taggedPaths(v interface{}, option string) []string {
+	paths := []string{}
+	collectTaggedPaths(reflect.TypeOf(v), "", option, &paths, map[reflect.Type]bool{})
+	sort.Strings(paths)
+	return paths
+}
+
+func collectTaggedPaths(t reflect.Type, prefix, option string, paths *[]string, visiting map[reflect.Type]bool) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == nil {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		collectTaggedPaths(t.Elem(), prefix+"/*", option, paths, visiting)
+		return
+	case reflect.Struct:
+	default:
+		return
+	}
+
+	if visiting[t] {
+		return
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		name, ok := jsonFieldName(field)
+		if !ok {
+			continue
+		}
+
+		if name == "" {
+			collectTaggedPaths(field.Type, prefix, option, paths, visiting)
+			continue
+		}
+
+		path := prefix + "/" + escapePointerToken(name)
+
+		if hasTagOption(field, option) {
+			*paths = append(*paths, path)
+			continue
+		}
+
+		collectTaggedPaths(field.Type, path, option, paths, visiting)
+	}
+}
+
+// jsonFieldName is the name of field in its struct JSON, empty for
+// embedded structs whose fields are promoted
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+
+	name := strings.Split(tag, ",")[0]
+	if name != "" {
+		return name, true
+	}
+
+	if field.Anonymous {
+		t := field.Type
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		if t.Kind() == reflect.Struct {
+			return "", true
+		}
+	}
+
+	return field.Name, true
+}
+
+func hasTagOption(field reflect.StructField, option string) bool {
+	for _, o := range strings.Split(field.Tag.Get("events"), ",") {
+		if strings.TrimSpace(o) == option {
+			return true
+		}
+	}
+
+	return false
+}
+
+func escapePointerToken(token string) string {
+	return strings.Replace(strings.Replace(token, "~", "~0", -1), "/", "~1", -1)
+}
+
+func unescapePointerToken(token string) string {
+	return strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+}
+
+// replacePath replaces the values of a decoded JSON document at path, a
+// JSON pointer, with the result of replace. With wildcard, "*" tokens
+// match every element of arrays and objects. Object keys match
+// case-insensitively, as encoding/json decodes them, and replace gets the
+// pointer of the key found. Missing values are skipped.
+func replacePath(value interface{}, path string, wildcard bool, replace func(pointer string, value interface{}) (interface{}, error)) (interface{}, error) {
+	if path == "" {
+		return replace("", value)
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		return value, nil
+	}
+
+	return replaceTokens(value, strings.Split(path[1:], "/"), "", wildcard, replace)
+}
+
+func replaceTokens(value interface{}, tokens []string, pointer string, wildcard bool, replace func(string, interface{}) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 0 {
+		return replace(pointer, value)
+	}
+
+	token, rest := tokens[0], tokens[1:]
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		name := unescapePointerToken(token)
+		all := wildcard && token == "*"
+
+		keys := []string{}
+		for key := range v {
+			if all || strings.EqualFold(key, name) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			item := v[key]
+
+			replaced, err := replaceTokens(item, rest, pointer+"/"+escapePointerToken(key), wildcard, replace)
+			if err != nil {
+				return value, err
+			}
+			v[key] = replaced
+		}
+	case []interface{}:
+		first, last := 0, len(v)
+
+		if !wildcard || token != "*" {
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) {
+				return value, nil
+			}
+			first, last = i, i+1
+		}
+
+		for i := first; i < last; i++ {
+			replaced, err := replaceTokens(v[i], rest, pointer+"/"+strconv.Itoa(i), wildcard, replace)
+			if err != nil {
+				return value, err
+			}
+			v[i] = replaced
+		}
+	}
+
+	return value, nil
+}
@@ -13,6 +13,14 @@ type HTTPTracker interface {
 	End(context.Context, Event, error) context.Context
 }
 
+// EventTracker - Optional interface of HTTPTrackers tracking the events
+// served outside of an HTTP request: the sub-events of batches, the events
+// of Consumers and Mux.Dispatch. StartEvent replaces Start, End is called
+// as usual. The errors of the events are noticed by every tracker.
+type EventTracker interface {
+	StartEvent(context.Context, Event) context.Context
+}
+
 // NewNoOpTracker - Returns a "No Operation Tracker"
 func NewNoOpTracker() HTTPTracker {
 	return &noOpTracker{}
@@ -0,0 +1,212 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// MetadataReplyTo is the metadata field with the topic where the response
+// of an event consumed from a Transport is published
+const MetadataReplyTo = "replyTo"
+
+// ErrTransportClosed is returned by closed transports and subscriptions
+var ErrTransportClosed = errors.New("events: transport closed")
+
+// Transport - A message broker adapter. Subscriptions to the same topic
+// compete for its events, each event is delivered to one of them.
+type Transport interface {
+	Publish(ctx context.Context, topic string, event Event) error
+	Subscribe(ctx context.Context, topic string) (Subscription, error)
+	Close() error
+}
+
+// Subscription - Receives the events of a topic
+type Subscription interface {
+	// Receive blocks until an event is available or ctx ends
+	Receive(ctx context.Context) (Delivery, error)
+	// Close stops the subscription, unacknowledged events are redelivered
+	Close() error
+}
+
+// Delivery - An event received from a Subscription. Every delivery must be
+// acknowledged with Ack or returned to the topic with Nack.
+type Delivery interface {
+	Event() Event
+	Ack() error
+	Nack() error
+}
+
+// Consumer dispatches the events of a topic to the handlers of a Mux and
+// publishes the responses to the topic in the replyTo metadata field.
+// Failed events are retried following Retry and, when every attempt fails,
+// added to DeadLetters. Responses that cannot be published are retried the
+// same way without running the handler again, then dead lettered and
+// acknowledged.
+type Consumer struct {
+	Mux         *Mux
+	Transport   Transport
+	Topic       string
+	Concurrency int
+
+	Retry       RetryPolicy
+	DeadLetters DeadLetterStore
+}
+
+// NewConsumer returns a Consumer of topic with a single worker, no retries
+// and no dead letter store
+func NewConsumer(mux *Mux, transport Transport, topic string) *Consumer {
+	return &Consumer{
+		Mux:         mux,
+		Transport:   transport,
+		Topic:       topic,
+		Concurrency: 1,
+		Retry:       NoRetry,
+	}
+}
+
+// Run consumes events until ctx ends or the subscription fails
+func (c *Consumer) Run(ctx context.Context) error {
+	subscription, err := c.Transport.Subscribe(ctx, c.Topic)
+	if err != nil {
+		return err
+	}
+	defer subscription.Close()
+
+	workers := c.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- c.work(ctx, subscription)
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil && err != ctx.Err() {
+			return err
+		}
+	}
+
+	return ctx.Err()
+}
+
+func (c *Consumer) work(ctx context.Context, subscription Subscription) error {
+	for {
+		delivery, err := subscription.Receive(ctx)
+		if err != nil {
+			return err
+		}
+
+		if err := c.handle(ctx, delivery); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *Consumer) handle(ctx context.Context, delivery Delivery) error {
+	event := delivery.Event()
+
+	attempts := 0
+	MetadataField(event, MetadataAttempts, &attempts)
+
+	var response Event
+	var err error
+
+	for {
+		attempts++
+		event = WithMetadataField(event, MetadataAttempts, attempts)
+
+		response, err = c.dispatch(ctx, event)
+		if err == nil {
+			break
+		}
+
+		ctx = c.Mux.tracer.NoticeEventError(ctx, event, err)
+
+		if !c.Retry.ShouldRetry(attempts, err) {
+			break
+		}
+
+		if sleepErr := sleep(ctx, c.Retry.Backoff(attempts)); sleepErr != nil {
+			// Shutting down, the transport redelivers the event
+			delivery.Nack()
+			return sleepErr
+		}
+	}
+
+	if err != nil {
+		if err := c.deadLetter(ctx, event, err, attempts); err != nil {
+			c.Mux.tracer.NoticeEventError(ctx, event, err)
+			return delivery.Nack()
+		}
+	}
+
+	if err := c.reply(ctx, event, response); err != nil {
+		// The handler already ran, redelivering would run it again
+		c.Mux.tracer.NoticeEventError(ctx, event, err)
+
+		if err := c.deadLetter(ctx, event, fmt.Errorf("reply: %w", err), attempts); err != nil {
+			c.Mux.tracer.NoticeEventError(ctx, event, err)
+		}
+	}
+
+	return delivery.Ack()
+}
+
+func (c *Consumer) dispatch(ctx context.Context, event Event) (Event, error) {
+	handler, ok := c.Mux.get(event.Name, event.Version)
+	if !ok {
+		err := fmt.Errorf("%w: %q version %d", ErrEventNotFound, event.Name, event.Version)
+		return NewError(event.FlowID, "Event not Found"), Permanent(err)
+	}
+
+	ctx, tracked := c.Mux.startEvent(ctx, event)
+	response, err := handler.Serve(ctx, event)
+
+	if tracked {
+		c.Mux.tracer.End(ctx, event, err)
+	}
+
+	return response, err
+}
+
+func (c *Consumer) deadLetter(ctx context.Context, event Event, err error, attempts int) error {
+	if c.DeadLetters == nil {
+		return nil
+	}
+
+	return c.DeadLetters.Add(ctx, NewDeadLetter(c.Topic, event, err, attempts))
+}
+
+func (c *Consumer) reply(ctx context.Context, event, response Event) error {
+	replyTo := ""
+	if !MetadataField(event, MetadataReplyTo, &replyTo) || replyTo == "" {
+		return nil
+	}
+
+	// The requester, like a Saga, matches the reply to its event
+	response = WithMetadataField(response, MetadataRequestID, event.ID)
+
+	for attempts := 1; ; attempts++ {
+		err := c.Transport.Publish(ctx, replyTo, response)
+		if err == nil || !c.Retry.ShouldRetry(attempts, err) {
+			return err
+		}
+
+		if sleep(ctx, c.Retry.Backoff(attempts)) != nil {
+			return err
+		}
+	}
+}
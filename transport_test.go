@@ -0,0 +1,160 @@
+package events_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	events "github.com/GuiaBolso/Go-Events"
+	"github.com/GuiaBolso/Go-Events/transporttest"
+)
+
+func Test_MemoryBroker_contract(t *testing.T) {
+	transporttest.Run(t, func(t *testing.T) events.Transport {
+		return events.NewMemoryBroker()
+	})
+}
+
+func Test_Consumer_errors(t *testing.T) {
+	broker := events.NewMemoryBroker()
+
+	noticed := make(chan events.Event, 10)
+	mux := events.NewMuxWithTracker(&events.MockTracker{
+		StartFn: func(ctx context.Context, _ events.Event, _ http.ResponseWriter, _ *http.Request) context.Context {
+			return ctx
+		},
+		NoticeEventErrorFn: func(ctx context.Context, event events.Event, _ error) context.Context {
+			noticed <- event
+			return ctx
+		},
+		EndFn: func(ctx context.Context, _ events.Event, _ error) context.Context {
+			return ctx
+		},
+	})
+
+	mux.Add("fail", 1, events.HandlerFunc(func(_ context.Context, event events.Event) (events.Event, error) {
+		return events.NewError(event.FlowID, "failed"), errors.New("failed")
+	}))
+
+	replies, _ := broker.Subscribe(context.Background(), "replies")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go events.NewConsumer(mux, broker, "requests").Run(ctx)
+
+	for _, name := range []string{"fail", "unknown"} {
+		event := events.WithMetadataField(events.Event{Name: name, Version: 1, FlowID: name}, events.MetadataReplyTo, "replies")
+		broker.Publish(context.Background(), "requests", event)
+	}
+
+	for i := 0; i < 2; i++ {
+		timeout, cancelReceive := context.WithTimeout(context.Background(), time.Second)
+		delivery, err := replies.Receive(timeout)
+		cancelReceive()
+
+		if err != nil {
+			t.Fatalf(`Error not expected: "%s"`, err.Error())
+		}
+
+		if delivery.Event().Name != "error" {
+			t.Errorf(`Name == "%s", wants: "error"`, delivery.Event().Name)
+		}
+		delivery.Ack()
+
+		select {
+		case <-noticed:
+		case <-time.After(time.Second):
+			t.Error("NoticeEventError must be called for every failure")
+		}
+	}
+
+	if pending := broker.Pending("requests"); len(pending) != 0 {
+		t.Errorf("len(pending) == %d, wants: %d", len(pending), 0)
+	}
+}
+
+type mockEventTracker struct {
+	*events.MockTracker
+
+	started chan events.Event
+}
+
+func (t *mockEventTracker) StartEvent(ctx context.Context, event events.Event) context.Context {
+	t.started <- event
+	return ctx
+}
+
+type failingReplyBroker struct {
+	*events.MemoryBroker
+}
+
+func (b failingReplyBroker) Publish(ctx context.Context, topic string, event events.Event) error {
+	if topic == "replies" {
+		return errors.New("reply topic unavailable")
+	}
+
+	return b.MemoryBroker.Publish(ctx, topic, event)
+}
+
+func Test_Consumer_reply_failure(t *testing.T) {
+	broker := failingReplyBroker{events.NewMemoryBroker()}
+	deadLetters := events.NewMemoryDeadLetterStore()
+
+	started := make(chan events.Event, 10)
+	mux := events.NewMuxWithTracker(&mockEventTracker{started: started, MockTracker: &events.MockTracker{
+		NoticeEventErrorFn: func(ctx context.Context, _ events.Event, _ error) context.Context {
+			return ctx
+		},
+		EndFn: func(ctx context.Context, _ events.Event, _ error) context.Context {
+			return ctx
+		},
+	}})
+
+	served := make(chan struct{}, 10)
+	mux.Add("charge", 1, events.HandlerFunc(func(_ context.Context, event events.Event) (events.Event, error) {
+		served <- struct{}{}
+		return events.NewResponse(event, nil)
+	}))
+
+	consumer := events.NewConsumer(mux, broker, "requests")
+	consumer.Retry = events.RetryPolicy{MaxAttempts: 3}
+	consumer.DeadLetters = deadLetters
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go consumer.Run(ctx)
+
+	event := events.WithMetadataField(events.Event{Name: "charge", Version: 1, ID: "charge-1"}, events.MetadataReplyTo, "replies")
+	broker.Publish(ctx, "requests", event)
+
+	var list []events.DeadLetter
+	for i := 0; i < 100; i++ {
+		list, _ = deadLetters.List(ctx)
+		if len(list) == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if len(list) != 1 {
+		t.Fatalf("len(deadLetters) == %d, wants: %d", len(list), 1)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	if len(served) != 1 {
+		t.Errorf("len(served) == %d, wants: %d", len(served), 1)
+	}
+
+	if len(started) != 1 {
+		t.Errorf("len(started) == %d, wants: %d", len(started), 1)
+	}
+
+	if pending := broker.Pending("requests"); len(pending) != 0 {
+		t.Errorf("len(pending) == %d, wants: %d", len(pending), 0)
+	}
+}
@@ -0,0 +1,347 @@
+// Package transporttest is the contract test suite of events.Transport.
+// Adapters of real brokers run it from their own tests:
+//
+//	func TestContract(t *testing.T) {
+//		transporttest.Run(t, func(t *testing.T) events.Transport {
+//			return newTestAdapter(t)
+//		})
+//	}
+package transporttest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	events "github.com/GuiaBolso/Go-Events"
+)
+
+// Timeout used when waiting for deliveries
+var Timeout = 5 * time.Second
+
+// Run runs the contract tests. newTransport must return a new transport
+// with no pending events, it is closed by the suite.
+func Run(t *testing.T, newTransport func(t *testing.T) events.Transport) {
+	tests := []struct {
+		name string
+		test func(*testing.T, events.Transport)
+	}{
+		{"PublishReceive", testPublishReceive},
+		{"TopicIsolation", testTopicIsolation},
+		{"Order", testOrder},
+		{"NackRedelivers", testNackRedelivers},
+		{"CloseRedeliversUnacked", testCloseRedeliversUnacked},
+		{"CompetingSubscriptions", testCompetingSubscriptions},
+		{"ReceiveCancel", testReceiveCancel},
+		{"Closed", testClosed},
+		{"ConsumerReply", testConsumerReply},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			transport := newTransport(t)
+			defer transport.Close()
+
+			tt.test(t, transport)
+		})
+	}
+}
+
+func newEvent(i int) events.Event {
+	payload, _ := json.Marshal(map[string]int{"n": i})
+
+	return events.Event{
+		Name:     "contract",
+		Version:  1,
+		ID:       events.RandomID(),
+		FlowID:   events.RandomID(),
+		Payload:  payload,
+		Metadata: json.RawMessage(fmt.Sprintf(`{"index":%d}`, i)),
+	}
+}
+
+func subscribe(t *testing.T, transport events.Transport, topic string) events.Subscription {
+	subscription, err := transport.Subscribe(context.Background(), topic)
+	if err != nil {
+		t.Fatalf("Subscribe: %s", err)
+	}
+	return subscription
+}
+
+func publish(t *testing.T, transport events.Transport, topic string, event events.Event) {
+	if err := transport.Publish(context.Background(), topic, event); err != nil {
+		t.Fatalf("Publish: %s", err)
+	}
+}
+
+func receive(t *testing.T, subscription events.Subscription) events.Delivery {
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+
+	delivery, err := subscription.Receive(ctx)
+	if err != nil {
+		t.Fatalf("Receive: %s", err)
+	}
+	return delivery
+}
+
+func assertEvent(t *testing.T, got, expected events.Event) {
+	if got.Name != expected.Name || got.Version != expected.Version || got.ID != expected.ID || got.FlowID != expected.FlowID {
+		t.Errorf("Received %#v, wants: %#v", got, expected)
+	}
+
+	if !jsonEqual(got.Payload, expected.Payload) {
+		t.Errorf("Payload == %s, wants: %s", got.Payload, expected.Payload)
+	}
+
+	if !jsonEqual(got.Metadata, expected.Metadata) {
+		t.Errorf("Metadata == %s, wants: %s", got.Metadata, expected.Metadata)
+	}
+}
+
+func jsonEqual(a, b json.RawMessage) bool {
+	var va, vb interface{}
+	json.Unmarshal(a, &va)
+	json.Unmarshal(b, &vb)
+
+	ja, _ := json.Marshal(va)
+	jb, _ := json.Marshal(vb)
+
+	return string(ja) == string(jb)
+}
+
+func testPublishReceive(t *testing.T, transport events.Transport) {
+	subscription := subscribe(t, transport, "contract.publish")
+	defer subscription.Close()
+
+	event := newEvent(1)
+	publish(t, transport, "contract.publish", event)
+
+	delivery := receive(t, subscription)
+	assertEvent(t, delivery.Event(), event)
+
+	if err := delivery.Ack(); err != nil {
+		t.Errorf("Ack: %s", err)
+	}
+}
+
+func testTopicIsolation(t *testing.T, transport events.Transport) {
+	a := subscribe(t, transport, "contract.a")
+	defer a.Close()
+	b := subscribe(t, transport, "contract.b")
+	defer b.Close()
+
+	eventA, eventB := newEvent(1), newEvent(2)
+	publish(t, transport, "contract.a", eventA)
+	publish(t, transport, "contract.b", eventB)
+
+	deliveryB := receive(t, b)
+	assertEvent(t, deliveryB.Event(), eventB)
+	deliveryB.Ack()
+
+	deliveryA := receive(t, a)
+	assertEvent(t, deliveryA.Event(), eventA)
+	deliveryA.Ack()
+}
+
+func testOrder(t *testing.T, transport events.Transport) {
+	subscription := subscribe(t, transport, "contract.order")
+	defer subscription.Close()
+
+	published := []events.Event{}
+	for i := 0; i < 5; i++ {
+		event := newEvent(i)
+		published = append(published, event)
+		publish(t, transport, "contract.order", event)
+	}
+
+	for _, event := range published {
+		delivery := receive(t, subscription)
+		assertEvent(t, delivery.Event(), event)
+		delivery.Ack()
+	}
+}
+
+func testNackRedelivers(t *testing.T, transport events.Transport) {
+	subscription := subscribe(t, transport, "contract.nack")
+	defer subscription.Close()
+
+	event := newEvent(1)
+	publish(t, transport, "contract.nack", event)
+
+	delivery := receive(t, subscription)
+	if err := delivery.Nack(); err != nil {
+		t.Fatalf("Nack: %s", err)
+	}
+
+	redelivery := receive(t, subscription)
+	assertEvent(t, redelivery.Event(), event)
+	redelivery.Ack()
+}
+
+func testCloseRedeliversUnacked(t *testing.T, transport events.Transport) {
+	first := subscribe(t, transport, "contract.close")
+
+	event := newEvent(1)
+	publish(t, transport, "contract.close", event)
+
+	receive(t, first)
+
+	if err := first.Close(); err != nil {
+		t.Fatalf("Close: %s", err)
+	}
+
+	second := subscribe(t, transport, "contract.close")
+	defer second.Close()
+
+	delivery := receive(t, second)
+	assertEvent(t, delivery.Event(), event)
+	delivery.Ack()
+}
+
+func testCompetingSubscriptions(t *testing.T, transport events.Transport) {
+	const total = 20
+
+	subscriptions := []events.Subscription{
+		subscribe(t, transport, "contract.compete"),
+		subscribe(t, transport, "contract.compete"),
+	}
+
+	for i := 0; i < total; i++ {
+		publish(t, transport, "contract.compete", newEvent(i))
+	}
+
+	var mu sync.Mutex
+	received := map[string]int{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, subscription := range subscriptions {
+		wg.Add(1)
+		go func(subscription events.Subscription) {
+			defer wg.Done()
+
+			for {
+				mu.Lock()
+				count := len(received)
+				mu.Unlock()
+
+				if count >= total {
+					return
+				}
+
+				delivery, err := subscription.Receive(ctx)
+				if err != nil {
+					return
+				}
+
+				mu.Lock()
+				received[delivery.Event().ID]++
+				count = len(received)
+				mu.Unlock()
+
+				delivery.Ack()
+
+				if count >= total {
+					cancel()
+				}
+			}
+		}(subscription)
+	}
+
+	wg.Wait()
+
+	for _, subscription := range subscriptions {
+		subscription.Close()
+	}
+
+	if len(received) != total {
+		t.Errorf("Received %d distinct events, wants: %d", len(received), total)
+	}
+
+	for id, count := range received {
+		if count != 1 {
+			t.Errorf("Event %s delivered %d times, wants: 1", id, count)
+		}
+	}
+}
+
+func testReceiveCancel(t *testing.T, transport events.Transport) {
+	subscription := subscribe(t, transport, "contract.cancel")
+	defer subscription.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := subscription.Receive(ctx); err == nil {
+		t.Error("Receive must fail when ctx ends without events")
+	}
+}
+
+func testClosed(t *testing.T, transport events.Transport) {
+	subscription := subscribe(t, transport, "contract.closed")
+	subscription.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+
+	if _, err := subscription.Receive(ctx); err == nil {
+		t.Error("Receive must fail on a closed subscription")
+	}
+
+	transport.Close()
+
+	if err := transport.Publish(context.Background(), "contract.closed", newEvent(1)); err == nil {
+		t.Error("Publish must fail on a closed transport")
+	}
+}
+
+func testConsumerReply(t *testing.T, transport events.Transport) {
+	mux := events.NewMux()
+	mux.Add("contract", 1, events.HandlerFunc(func(_ context.Context, event events.Event) (events.Event, error) {
+		return events.NewResponse(event, event.Payload)
+	}))
+
+	replies := subscribe(t, transport, "contract.replies")
+	defer replies.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	go func() { done <- events.NewConsumer(mux, transport, "contract.requests").Run(ctx) }()
+
+	event := events.WithMetadataField(newEvent(7), events.MetadataReplyTo, "contract.replies")
+	publish(t, transport, "contract.requests", event)
+
+	delivery := receive(t, replies)
+	delivery.Ack()
+
+	response := delivery.Event()
+
+	if response.Name != "contract:response" || response.FlowID != event.FlowID {
+		t.Errorf("Unexpected response: %#v", response)
+	}
+
+	if !jsonEqual(response.Payload, event.Payload) {
+		t.Errorf("Payload == %s, wants: %s", response.Payload, event.Payload)
+	}
+
+	requestID := ""
+	if !events.MetadataField(response, events.MetadataRequestID, &requestID) || requestID != event.ID {
+		t.Errorf("requestID == %s, wants: %s", requestID, event.ID)
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(Timeout):
+		t.Error("Consumer must stop when ctx ends")
+	}
+}
@@ -0,0 +1,394 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/jsonschema"
+)
+
+// DocError - An EventDoc example that does not match its documentation
+type DocError struct {
+	Name    string
+	Version int
+	Problem string
+}
+
+func (e DocError) Error() string {
+	return fmt.Sprintf("%s (version %d): %s", e.Name, e.Version, e.Problem)
+}
+
+// TestingT is the subset of *testing.T used by AssertDocs
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+}
+
+// VerifyDocs validates the examples of every EventDoc handler against the
+// schemas reflected from Input() and Output()
+func (m *Mux) VerifyDocs() []error {
+	return m.verifyDocs(context.Background(), false)
+}
+
+// VerifyDocsWithHandlers does the same checks as VerifyDocs and also runs
+// every input example through its handler, comparing the structure of the
+// response payload with the documented output example. ctx must not be nil.
+func (m *Mux) VerifyDocsWithHandlers(ctx context.Context) []error {
+	if ctx == nil {
+		panic("events: VerifyDocsWithHandlers with a nil context")
+	}
+
+	return m.verifyDocs(ctx, true)
+}
+
+// AssertDocs reports every documentation problem of mux as a test error.
+// When runHandlers is true the handlers are executed with the input examples.
+func AssertDocs(t TestingT, mux *Mux, runHandlers bool) {
+	var errs []error
+
+	if runHandlers {
+		errs = mux.VerifyDocsWithHandlers(context.Background())
+	} else {
+		errs = mux.VerifyDocs()
+	}
+
+	for _, err := range errs {
+		t.Errorf("%s", err.Error())
+	}
+}
+
+func (m *Mux) verifyDocs(ctx context.Context, runHandlers bool) []error {
+	keys := make([]eventKey, 0, len(m.events))
+	for key := range m.events {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Name == keys[j].Name {
+			return keys[i].Version < keys[j].Version
+		}
+		return keys[i].Name < keys[j].Name
+	})
+
+	errs := []error{}
+
+	for _, key := range keys {
+		eventWithDoc, ok := m.events[key].(EventDoc)
+		if !ok {
+			continue
+		}
+
+		problems := verifyEventDoc(ctx, key, m.events[key], eventWithDoc, runHandlers)
+
+		for _, problem := range problems {
+			errs = append(errs, DocError{
+				Name:    key.Name,
+				Version: key.Version,
+				Problem: problem,
+			})
+		}
+	}
+
+	return errs
+}
+
+func verifyEventDoc(ctx context.Context, key eventKey, handler Handler, eventWithDoc EventDoc, runHandlers bool) []string {
+	problems := []string{}
+
+	inputExample, outputExample := eventWithDoc.Example()
+
+	inputJSON, err := json.Marshal(inputExample)
+	if err != nil {
+		return append(problems, fmt.Sprintf("input example cannot be encoded: %s", err))
+	}
+
+	outputJSON, err := json.Marshal(outputExample)
+	if err != nil {
+		return append(problems, fmt.Sprintf("output example cannot be encoded: %s", err))
+	}
+
+	inputSchema := newSchemaValidator(eventWithDoc.Input())
+	for _, problem := range inputSchema.validate(inputJSON) {
+		problems = append(problems, "input example: "+problem)
+	}
+
+	outputSchema := newSchemaValidator(eventWithDoc.Output())
+	for _, problem := range outputSchema.validate(outputJSON) {
+		problems = append(problems, "output example: "+problem)
+	}
+
+	if !runHandlers {
+		return problems
+	}
+
+	response, err := handler.Serve(ctx, Event{
+		Name:    key.Name,
+		Version: key.Version,
+		ID:      RandomID(),
+		FlowID:  RandomID(),
+		Payload: inputJSON,
+	})
+
+	if err != nil {
+		return append(problems, fmt.Sprintf("handler failed with the input example: %s", err))
+	}
+
+	for _, problem := range outputSchema.validate(response.Payload) {
+		problems = append(problems, "handler output: "+problem)
+	}
+
+	var documented, actual interface{}
+
+	json.Unmarshal(outputJSON, &documented)
+
+	if err := json.Unmarshal(response.Payload, &actual); err != nil {
+		return append(problems, fmt.Sprintf("handler output is not valid JSON: %s", err))
+	}
+
+	for _, problem := range compareStructure("", documented, actual) {
+		problems = append(problems, "handler output differs from the output example: "+problem)
+	}
+
+	return problems
+}
+
+// schemaValidator checks JSON documents against the subset of JSON Schema
+// produced by jsonschema.Reflect
+type schemaValidator struct {
+	root        map[string]interface{}
+	definitions map[string]interface{}
+}
+
+func newSchemaValidator(v interface{}) *schemaValidator {
+	schemaJSON, _ := json.Marshal(jsonschema.Reflect(v))
+
+	root := map[string]interface{}{}
+	json.Unmarshal(schemaJSON, &root)
+
+	definitions := map[string]interface{}{}
+	for _, field := range []string{"definitions", "$defs"} {
+		if defs, ok := root[field].(map[string]interface{}); ok {
+			for name, def := range defs {
+				definitions[name] = def
+			}
+		}
+	}
+
+	return &schemaValidator{root: root, definitions: definitions}
+}
+
+func (s *schemaValidator) validate(data []byte) []string {
+	var value interface{}
+
+	if err := json.Unmarshal(data, &value); err != nil {
+		return []string{fmt.Sprintf("invalid JSON: %s", err)}
+	}
+
+	return s.validateValue("$", s.root, value)
+}
+
+func (s *schemaValidator) resolve(schema map[string]interface{}) map[string]interface{} {
+	for i := 0; i < 32; i++ {
+		ref, ok := schema["$ref"].(string)
+		if !ok {
+			return schema
+		}
+
+		name := ref[strings.LastIndex(ref, "/")+1:]
+
+		def, ok := s.definitions[name].(map[string]interface{})
+		if !ok {
+			return schema
+		}
+		schema = def
+	}
+
+	return schema
+}
+
+func (s *schemaValidator) validateValue(path string, schema map[string]interface{}, value interface{}) []string {
+	schema = s.resolve(schema)
+	problems := []string{}
+
+	if types := schemaTypes(schema["type"]); len(types) > 0 && !matchesType(types, value) {
+		return append(problems, fmt.Sprintf("%s is %s, wants %s", path, jsonType(value), strings.Join(types, " or ")))
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if fmt.Sprint(allowed) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			problems = append(problems, fmt.Sprintf("%s has a value outside of the enum", path))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, name := range required {
+				if _, ok := v[fmt.Sprint(name)]; !ok {
+					problems = append(problems, fmt.Sprintf("%s.%s is required", path, name))
+				}
+			}
+		}
+
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			property, ok := properties[name].(map[string]interface{})
+			if ok {
+				problems = append(problems, s.validateValue(path+"."+name, property, v[name])...)
+				continue
+			}
+
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					problems = append(problems, fmt.Sprintf("%s.%s is not in the schema", path, name))
+				}
+			case map[string]interface{}:
+				problems = append(problems, s.validateValue(path+"."+name, additional, v[name])...)
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				problems = append(problems, s.validateValue(fmt.Sprintf("%s[%d]", path, i), items, item)...)
+			}
+		}
+	}
+
+	return problems
+}
+
+func schemaTypes(t interface{}) []string {
+	switch v := t.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		types := []string{}
+		for _, item := range v {
+			types = append(types, fmt.Sprint(item))
+		}
+		return types
+	}
+	return nil
+}
+
+func matchesType(types []string, value interface{}) bool {
+	actual := jsonType(value)
+
+	for _, t := range types {
+		if t == actual {
+			return true
+		}
+
+		if t == "number" && actual == "integer" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "unknown"
+}
+
+// compareStructure reports the differences in shape (kinds and object keys)
+// between the documented and the actual values
+func compareStructure(path string, documented, actual interface{}) []string {
+	problems := []string{}
+	name := path
+	if name == "" {
+		name = "$"
+	}
+
+	if documented == nil || actual == nil {
+		return problems
+	}
+
+	documentedType, actualType := jsonType(documented), jsonType(actual)
+
+	if documentedType == "integer" {
+		documentedType = "number"
+	}
+	if actualType == "integer" {
+		actualType = "number"
+	}
+
+	if documentedType != actualType {
+		return append(problems, fmt.Sprintf("%s is %s, documented as %s", name, actualType, documentedType))
+	}
+
+	switch d := documented.(type) {
+	case map[string]interface{}:
+		a := actual.(map[string]interface{})
+
+		names := map[string]bool{}
+		for key := range d {
+			names[key] = true
+		}
+		for key := range a {
+			names[key] = true
+		}
+
+		sorted := make([]string, 0, len(names))
+		for key := range names {
+			sorted = append(sorted, key)
+		}
+		sort.Strings(sorted)
+
+		for _, key := range sorted {
+			dv, inDoc := d[key]
+			av, inActual := a[key]
+
+			switch {
+			case !inActual:
+				problems = append(problems, fmt.Sprintf("%s.%s is documented but missing", name, key))
+			case !inDoc:
+				problems = append(problems, fmt.Sprintf("%s.%s is not documented", name, key))
+			default:
+				problems = append(problems, compareStructure(name+"."+key, dv, av)...)
+			}
+		}
+	case []interface{}:
+		a := actual.([]interface{})
+
+		if len(d) > 0 && len(a) > 0 {
+			problems = append(problems, compareStructure(name+"[0]", d[0], a[0])...)
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,175 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type mockStaleDoc struct {
+	mockEventStruct
+}
+
+func (h *mockStaleDoc) Example() (interface{}, interface{}) {
+	in := map[string]interface{}{
+		"f1":      42,
+		"unknown": true,
+	}
+
+	out := mockEventStructOutput{Field3: "another string"}
+
+	return in, out
+}
+
+type mockServingDoc struct {
+	mockEventStruct
+	response interface{}
+}
+
+func (h *mockServingDoc) Serve(_ context.Context, event Event) (Event, error) {
+	return NewResponse(event, h.response)
+}
+
+type mockErrors struct {
+	messages []string
+}
+
+func (m *mockErrors) Errorf(format string, args ...interface{}) {
+	m.messages = append(m.messages, fmt.Sprintf(format, args...))
+}
+
+func Test_VerifyDocs(t *testing.T) {
+	mux := NewMux()
+
+	mux.Add("TestEvent", 42, &mockEventStruct{})
+	mux.Add("NoDocEvent", 1, HandlerFunc(mockHandlerFunc))
+
+	if errs := mux.VerifyDocs(); len(errs) != 0 {
+		t.Errorf("Not expecting errors, got: %v", errs)
+	}
+}
+
+func Test_VerifyDocs_stale_examples(t *testing.T) {
+	mux := NewMux()
+
+	mux.Add("StaleEvent", 1, &mockStaleDoc{})
+
+	errs := mux.VerifyDocs()
+
+	expected := []string{
+		"$.someNumber is required",
+		"$.f1 is integer, wants string",
+		"$.unknown is not in the schema",
+	}
+
+	if len(errs) != len(expected) {
+		t.Fatalf("len(errs) == %d, wants: %d. %v", len(errs), len(expected), errs)
+	}
+
+	for i, err := range errs {
+		if !strings.Contains(err.Error(), expected[i]) {
+			t.Errorf(`errs[%d] == "%s", wants: "%s"`, i, err.Error(), expected[i])
+		}
+
+		docErr, ok := err.(DocError)
+		if !ok || docErr.Name != "StaleEvent" || docErr.Version != 1 {
+			t.Errorf("Expecting a DocError for StaleEvent, got: %#v", err)
+		}
+	}
+}
+
+func Test_VerifyDocsWithHandlers(t *testing.T) {
+	mux := NewMux()
+
+	mux.Add("TestEvent", 1, &mockServingDoc{
+		response: mockEventStructOutput{Field3: "ok", Field4: 1.5},
+	})
+
+	if errs := mux.VerifyDocsWithHandlers(context.Background()); len(errs) != 0 {
+		t.Errorf("Not expecting errors, got: %v", errs)
+	}
+
+	mux.Add("TestEvent", 2, &mockServingDoc{
+		response: map[string]interface{}{"field3": 10},
+	})
+
+	errs := mux.VerifyDocsWithHandlers(context.Background())
+
+	if len(errs) == 0 {
+		t.Fatal("Expecting errors for a handler that does not follow its documentation")
+	}
+
+	found := false
+	for _, err := range errs {
+		if strings.Contains(err.Error(), "$.f4 is documented but missing") {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Errorf("Expecting a structure difference, got: %v", errs)
+	}
+
+	mux = NewMux()
+	mux.Add("FailingEvent", 1, &mockFailingDoc{})
+
+	errs = mux.VerifyDocsWithHandlers(context.Background())
+
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "handler failed") {
+		t.Errorf("Expecting a handler failure, got: %v", errs)
+	}
+
+	if errs := mux.VerifyDocs(); len(errs) != 0 {
+		t.Errorf("VerifyDocs must not run the handlers, got: %v", errs)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expecting a panic with a nil context")
+		}
+	}()
+
+	var ctx context.Context
+	mux.VerifyDocsWithHandlers(ctx)
+}
+
+type mockFailingDoc struct {
+	mockEventStruct
+}
+
+func (h *mockFailingDoc) Serve(ctx context.Context, event Event) (Event, error) {
+	return mockEventError(ctx, event)
+}
+
+func Test_AssertDocs(t *testing.T) {
+	mux := NewMux()
+	mux.Add("StaleEvent", 1, &mockStaleDoc{})
+
+	mockT := &mockErrors{}
+	AssertDocs(mockT, mux, false)
+
+	if len(mockT.messages) != 3 {
+		t.Errorf("len(messages) == %d, wants: %d", len(mockT.messages), 3)
+	}
+}
+
+func Test_compareStructure(t *testing.T) {
+	var documented, actual interface{}
+
+	json.Unmarshal([]byte(`{"a": 1, "b": [{"c": "x"}], "d": null}`), &documented)
+	json.Unmarshal([]byte(`{"a": 2.5, "b": [{"c": 1}], "e": true}`), &actual)
+
+	problems := compareStructure("", documented, actual)
+
+	expected := []string{
+		"$.b[0].c is number, documented as string",
+		"$.d is documented but missing",
+		"$.e is not documented",
+	}
+
+	if strings.Join(problems, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("problems == %v, wants: %v", problems, expected)
+	}
+}